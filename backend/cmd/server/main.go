@@ -3,11 +3,108 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"portfolio-simulator/backend/internal/api"
+	"portfolio-simulator/backend/internal/data"
+	"portfolio-simulator/backend/internal/data/cache"
+	"portfolio-simulator/backend/internal/data/csv"
+	"portfolio-simulator/backend/internal/data/registry"
 	"portfolio-simulator/backend/internal/data/tiingo"
 )
 
+// newFetcher registers the price providers and chains them as configured. "tiingo",
+// whose prices are cached on disk, is always available and "csv" when CSV_DIR is set.
+// PRICE_PROVIDER lists the providers to try in order (default "tiingo"), and
+// PRICE_ROUTES gives tickers matching a pattern their own chain, e.g. "*USD=csv,tiingo".
+func newFetcher() data.PriceFetcher {
+	reg := registry.New()
+	if err := reg.Register("tiingo", withCache(tiingo.NewService())); err != nil {
+		log.Fatalf("Failed to register Tiingo prices: %v", err)
+	}
+	if os.Getenv("CSV_DIR") != "" {
+		if err := reg.Register("csv", newCSVProvider()); err != nil {
+			log.Fatalf("Failed to register CSV prices: %v", err)
+		}
+	}
+
+	chain := registry.ParseChain(os.Getenv("PRICE_PROVIDER"))
+	if len(chain) == 0 {
+		chain = []string{"tiingo"}
+	}
+	routes, err := registry.ParseRoutes(os.Getenv("PRICE_ROUTES"))
+	if err != nil {
+		log.Fatalf("Invalid PRICE_ROUTES: %v", err)
+	}
+	fetcher, err := reg.Composite(chain, routes)
+	if err != nil {
+		log.Fatalf("Invalid price provider configuration: %v", err)
+	}
+	log.Printf("Fetching prices from %s", strings.Join(chain, ", then "))
+	return fetcher
+}
+
+// newCSVProvider reads prices from the files in CSV_DIR, with the columns and date
+// formats given by the CSV_* variables.
+func newCSVProvider() data.PriceFetcher {
+	cfg := csv.Config{
+		Dir: os.Getenv("CSV_DIR"),
+		Columns: csv.Columns{
+			Date:     os.Getenv("CSV_DATE_COLUMN"),
+			Close:    os.Getenv("CSV_CLOSE_COLUMN"),
+			AdjClose: os.Getenv("CSV_ADJ_CLOSE_COLUMN"),
+			Dividend: os.Getenv("CSV_DIVIDEND_COLUMN"),
+		},
+	}
+	if formats := os.Getenv("CSV_DATE_FORMATS"); formats != "" {
+		cfg.DateFormats = strings.Split(formats, ",")
+	}
+	fetcher, err := csv.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up CSV prices: %v", err)
+	}
+	log.Printf("Reading prices from CSV files in %s", cfg.Dir)
+	return fetcher
+}
+
+// defaultCacheTTL is how long fetched prices are reused when PRICE_CACHE_TTL is not set.
+const defaultCacheTTL = 24 * time.Hour
+
+// withCache wraps fetcher in the on-disk price cache. PRICE_CACHE_DIR sets its directory
+// ("off" disables it) and PRICE_CACHE_TTL how long entries are reused (e.g. "12h").
+func withCache(fetcher data.PriceFetcher) data.PriceFetcher {
+	dir := os.Getenv("PRICE_CACHE_DIR")
+	if dir == "off" {
+		return fetcher
+	}
+	if dir == "" {
+		userCache, err := os.UserCacheDir()
+		if err != nil {
+			log.Printf("Warning: no user cache directory, prices will not be cached: %v", err)
+			return fetcher
+		}
+		dir = filepath.Join(userCache, "portfolio-simulator", "prices")
+	}
+	ttl := defaultCacheTTL
+	if raw := os.Getenv("PRICE_CACHE_TTL"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			log.Fatalf("Invalid PRICE_CACHE_TTL %q: %v", raw, err)
+		}
+		ttl = parsed
+	}
+	cached, err := cache.New(fetcher, dir, ttl)
+	if err != nil {
+		log.Printf("Warning: prices will not be cached: %v", err)
+		return fetcher
+	}
+	log.Printf("Caching prices in %s for %s", dir, ttl)
+	return cached
+}
+
 // corsMiddleware applies development CORS headers.
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -25,14 +122,13 @@ func corsMiddleware(next http.Handler) http.Handler {
 }
 
 func main() {
-	priceFetcherSvc := tiingo.NewService()
-
 	apiHandler := &api.Handler{
-		Fetcher: priceFetcherSvc,
+		Fetcher: newFetcher(),
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/simulate", apiHandler.RunSimulation)
+	mux.HandleFunc("/api/sensitivity", apiHandler.RunSensitivity)
 
 	log.Println("Server starting on http://localhost:8085")
 	if err := http.ListenAndServe(":8085", corsMiddleware(mux)); err != nil {
@@ -1,25 +1,41 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"math"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"portfolio-simulator/backend/internal/data"
+	"portfolio-simulator/backend/internal/data/cache"
 	"portfolio-simulator/backend/internal/portfolio"
 	"portfolio-simulator/backend/internal/portfolio/model"
 	"portfolio-simulator/backend/internal/simulation"
 )
 
-// PriceFetcher defines the interface for fetching monthly returns for a ticker.
-type PriceFetcher interface {
-	GetMonthlyReturns(ticker string) ([]float64, error)
-}
+// defaultMaxSimulations caps adaptive runs that do not specify maxSimulations.
+const defaultMaxSimulations = 10000
+
+// defaultMaxConcurrentFetches and defaultFetchTimeout bound price fetching when the
+// Handler does not set its own limits.
+const (
+	defaultMaxConcurrentFetches = 4
+	defaultFetchTimeout         = time.Minute
+)
 
 // Handler holds dependencies for API handlers, such as data fetchers.
 type Handler struct {
-	Fetcher PriceFetcher // Consolidated to a single fetcher.
+	Fetcher              data.PriceFetcher // Consolidated to a single fetcher; wrap returns-only fetchers with data.AdaptReturns.
+	MaxConcurrentFetches int               // Tickers fetched at once; defaultMaxConcurrentFetches when zero.
+	FetchTimeout         time.Duration     // Limit on fetching one request's prices; defaultFetchTimeout when zero.
 }
 
 // RunSimulation handles requests to run a portfolio simulation.
@@ -41,35 +57,236 @@ func (h *Handler) RunSimulation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var p model.Portfolio
-	for _, ar := range req.Portfolio {
-		p.Assets = append(p.Assets, model.Asset{
-			Ticker: ar.Ticker,
-			Weight: ar.Weight,
-		})
+	run, err := h.prepare(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	simResult, err := run.execute()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp := run.response(simResult)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding or writing simulation response: %v", err)
+	}
+}
+
+// httpError is a failure with the status and message to report to the client.
+type httpError struct {
+	status  int
+	message string
+}
+
+func (e *httpError) Error() string { return e.message }
+
+// writeError reports err to the client, as a 500 unless it is an httpError.
+func writeError(w http.ResponseWriter, err error) {
+	var he *httpError
+	if errors.As(err, &he) {
+		http.Error(w, he.message, he.status)
+		return
 	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
 
-	returnsByAsset := make(map[string][]float64)
+// simulationRun is a validated request resolved into simulation parameters.
+type simulationRun struct {
+	req          SimulationRequest
+	portfolio    model.Portfolio
+	accounts     []model.Account // Nil unless the request gives accounts.
+	initialValue float64
+	history      *portfolio.DateRange // Nil when the returns are undated.
+	alignment    portfolio.Alignment
+	sources      map[string][]portfolio.Source // Sources of the backfilled assets' months.
+	excluded     []data.Window                 // Months the request left out of the history.
+	provenance   map[string]data.Provenance    // Which provider served each fetched ticker.
+	params       simulation.Params
+}
+
+// requestPortfolio returns the portfolio to fetch returns for and the starting value.
+// With accounts, their combined holdings stand in for the portfolio.
+func requestPortfolio(req SimulationRequest) (model.Portfolio, []model.Account, float64) {
+	if len(req.Accounts) == 0 {
+		return buildPortfolio(req.Portfolio), nil, req.InitialVal
+	}
+	accounts := buildAccounts(req.Accounts)
+	initialValue := 0.0
+	for _, a := range accounts {
+		initialValue += a.Balance
+	}
+	return portfolio.Combine(accounts), accounts, initialValue
+}
+
+// prepare fetches the returns of the request's assets and resolves the simulation parameters.
+func (h *Handler) prepare(ctx context.Context, req SimulationRequest) (*simulationRun, error) {
+	p, _, _ := requestPortfolio(req)
+	window, _, _ := req.history() // Already checked by Validate.
+	seriesByAsset, provenance, err := h.fetchReturns(withCacheMode(ctx, req), p, window)
+	if err != nil {
+		return nil, err
+	}
+	run, err := newSimulationRun(req, seriesByAsset)
+	if err != nil {
+		return nil, err
+	}
+	run.provenance = provenance
+	return run, nil
+}
+
+// fetchReturns fetches the monthly prices of p's assets and of their proxies within
+// window and converts them to returns, along with where each ticker's prices came from.
+// Synthetic assets are skipped. Returns are dated when the fetcher dates its prices.
+// Tickers are fetched concurrently, at most maxConcurrentFetches at a time, and all of
+// them must arrive within fetchTimeout. Every ticker that fails is reported.
+func (h *Handler) fetchReturns(ctx context.Context, p model.Portfolio, window data.Window) (map[string]data.ReturnSeries, map[string]data.Provenance, error) {
+	var tickers []string
+	seen := make(map[string]bool)
 	for _, asset := range p.Assets {
-		log.Printf("Fetching returns for ticker: %s", asset.Ticker)
-		assetReturns, fetchErr := h.Fetcher.GetMonthlyReturns(asset.Ticker)
-		if fetchErr != nil {
-			log.Printf("Error fetching returns for %s: %v", asset.Ticker, fetchErr)
-			http.Error(w, fmt.Sprintf("Failed to fetch returns for ticker %s", asset.Ticker), http.StatusInternalServerError)
-			return
+		if asset.Synthetic != nil {
+			continue // Generated from its parameters when the returns are aligned.
+		}
+		candidates := []string{asset.Ticker}
+		if asset.Backfill != nil {
+			candidates = append(candidates, asset.Backfill.Proxies...)
+		}
+		for _, ticker := range candidates {
+			if !seen[ticker] { // Also held directly or a proxy of another asset.
+				seen[ticker] = true
+				tickers = append(tickers, ticker)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.fetchTimeout())
+	defer cancel()
+	type fetched struct {
+		series data.ReturnSeries
+		prices data.PriceSeries
+		err    error
+	}
+	results := make([]fetched, len(tickers))
+	slots := make(chan struct{}, h.maxConcurrentFetches())
+	var wg sync.WaitGroup
+	for i, ticker := range tickers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				results[i].err = ctx.Err()
+				return
+			}
+			log.Printf("Fetching returns for ticker: %s", ticker)
+			prices, err := h.Fetcher.FetchPrices(ctx, ticker, data.FetchOptions{Window: window})
+			var series data.ReturnSeries
+			if err == nil {
+				series, err = prices.MonthlyReturns()
+			}
+			// Within drops any history the fetcher returned beyond window.
+			results[i] = fetched{series: series.Within(window), prices: prices, err: err}
+		}()
+	}
+	wg.Wait()
+
+	seriesByAsset := make(map[string]data.ReturnSeries)
+	provenance := make(map[string]data.Provenance)
+	var failed []string
+	var errs []error
+	for i, ticker := range tickers {
+		r := results[i]
+		if r.err != nil {
+			failed = append(failed, ticker)
+			errs = append(errs, fmt.Errorf("%s: %w", ticker, r.err))
+			continue
 		}
 		// It's possible a fetcher returns no error but also no returns (e.g., new ticker with no history).
-		if len(assetReturns) == 0 {
-			log.Printf("Warning: No returns fetched for %s. This might affect simulation results if its weight is > 0.", asset.Ticker)
+		if len(r.series.Returns) == 0 {
+			log.Printf("Warning: No returns fetched for %s. This might affect simulation results if its weight is > 0.", ticker)
 		}
-		returnsByAsset[asset.Ticker] = assetReturns
+		seriesByAsset[ticker] = r.series
+		provenance[ticker] = r.prices.Provenance
+	}
+	if len(errs) > 0 {
+		log.Printf("Error fetching returns: %v", errors.Join(errs...))
+		noun := "ticker"
+		if len(failed) > 1 {
+			noun = "tickers"
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, nil, &httpError{status: http.StatusGatewayTimeout, message: fmt.Sprintf("Timed out fetching returns for %s %s", noun, strings.Join(failed, ", "))}
+		}
+		return nil, nil, &httpError{status: http.StatusInternalServerError, message: fmt.Sprintf("Failed to fetch returns for %s %s", noun, strings.Join(failed, ", "))}
+	}
+
+	return seriesByAsset, provenance, nil
+}
+
+// maxConcurrentFetches returns how many tickers may be fetched at once.
+func (h *Handler) maxConcurrentFetches() int {
+	if h.MaxConcurrentFetches > 0 {
+		return h.MaxConcurrentFetches
+	}
+	return defaultMaxConcurrentFetches
+}
+
+// fetchTimeout returns how long fetching a request's prices may take.
+func (h *Handler) fetchTimeout() time.Duration {
+	if h.FetchTimeout > 0 {
+		return h.FetchTimeout
+	}
+	return defaultFetchTimeout
+}
+
+// withCacheMode passes the request's cache mode on to a caching fetcher.
+func withCacheMode(ctx context.Context, req SimulationRequest) context.Context {
+	if req.Cache == "" {
+		return ctx
+	}
+	return cache.WithMode(ctx, cache.Mode(strings.ToLower(req.Cache)))
+}
+
+// newSimulationRun resolves a validated request into simulation parameters, using the
+// fetched returns of its assets aligned by calendar month.
+func newSimulationRun(req SimulationRequest, seriesByAsset map[string]data.ReturnSeries) (*simulationRun, error) {
+	p, accounts, initialValue := requestPortfolio(req)
+	alignment := portfolio.Alignment(strings.ToLower(req.Alignment))
+	if alignment == "" {
+		alignment = portfolio.AlignIntersect
+	}
+	seriesByAsset, sources, err := portfolio.Backfill(p, seriesByAsset)
+	if err != nil {
+		log.Printf("Error backfilling asset returns: %v", err)
+		return nil, &httpError{status: http.StatusUnprocessableEntity, message: fmt.Sprintf("Failed to backfill asset returns: %v", err)}
+	}
+	_, excluded, _ := req.history() // Already checked by Validate.
+	if len(excluded) > 0 {
+		for ticker, series := range seriesByAsset {
+			if !series.Dated() {
+				return nil, &httpError{status: http.StatusUnprocessableEntity, message: "Excluding date ranges needs returns labelled with their months"}
+			}
+			seriesByAsset[ticker] = series.Excluding(excluded)
+		}
+	}
+	returnsByAsset, history, err := portfolio.AlignByDate(p, seriesByAsset, alignment)
+	if err != nil {
+		log.Printf("Error aligning asset returns by date: %v", err)
+		return nil, &httpError{status: http.StatusUnprocessableEntity, message: fmt.Sprintf("Failed to align asset returns: %v", err)}
+	}
+	if history != nil {
+		log.Printf("Aligned asset returns on %d months from %s to %s.", history.Months, history.Start.Format("2006-01"), history.End.Format("2006-01"))
 	}
 
 	portfolioReturns, err := portfolio.WeightedMonthlyReturns(p, returnsByAsset)
 	if err != nil {
 		log.Printf("Error computing weighted portfolio returns: %v", err)
-		http.Error(w, "Failed to compute weighted portfolio returns", http.StatusInternalServerError)
-		return
+		// The portfolio's own settings, such as its capital market assumptions, are at fault.
+		return nil, &httpError{status: http.StatusUnprocessableEntity, message: fmt.Sprintf("Failed to compute weighted portfolio returns: %v", err)}
 	}
 
 	// The simulation functions expect a non-empty returns slice if the portfolio is non-empty and assets are valid.
@@ -82,15 +299,121 @@ func (h *Handler) RunSimulation(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Computed portfolio returns for %d months.", len(portfolioReturns))
 
 	params := simulation.Params{
-		InitialValue:     req.InitialVal,
+		InitialValue:     initialValue,
 		Returns:          portfolioReturns,
 		WithdrawalRate:   req.Withdrawal, // This is withdrawalRate from request
 		InflationPerYear: req.Inflation,
 		Periods:          req.Periods,
 		Simulations:      req.Simulations,
+		Sampler:          simulation.Sampler(strings.ToLower(req.Sampler)),
+		TargetStdError:   req.TargetStdError,
+		MaxSimulations:   req.MaxSimulations,
+		Seed:             req.Seed,
+	}
+	if params.TargetStdError > 0 && params.MaxSimulations == 0 {
+		params.MaxSimulations = defaultMaxSimulations
+	}
+	if req.Lifespan != nil {
+		// The table was already checked by Validate, so an error here is unexpected.
+		table, err := req.Lifespan.MortalityTable()
+		if err != nil {
+			log.Printf("Error resolving mortality table: %v", err)
+			return nil, &httpError{status: http.StatusInternalServerError, message: "Failed to resolve mortality table"}
+		}
+		params.Lifespan = &simulation.Lifespan{Table: table, StartAge: req.Lifespan.Age}
+	}
+	if g := req.GlidePath; g != nil {
+		assetReturns, err := portfolio.AlignedReturns(p, returnsByAsset)
+		if err != nil {
+			log.Printf("Error aligning asset returns: %v", err)
+			return nil, &httpError{status: http.StatusInternalServerError, message: "Failed to align asset returns"}
+		}
+		params.AssetReturns = assetReturns
+		params.GlidePath = buildGlidePath(g, p)
+	}
+	if b := req.Bucket; b != nil {
+		refill := simulation.RefillRule(strings.ToLower(b.Refill))
+		if refill == "" {
+			refill = simulation.RefillUpYears
+		}
+		params.Bucket = &simulation.Bucket{Years: b.Years, Yield: b.Yield, Refill: refill}
 	}
+	params.Fees = buildFees(req.AdvisoryFee, p)
+	if l := req.Leverage; l != nil {
+		params.Leverage = &simulation.Leverage{
+			Ratio:            l.Ratio,
+			BorrowRate:       l.BorrowRate,
+			CashRates:        l.CashRates,
+			Spread:           l.Spread,
+			MaintenanceRatio: l.maintenanceRatio(),
+		}
+	}
+	if accounts != nil {
+		assetReturns, err := portfolio.AlignedReturns(p, returnsByAsset)
+		if err != nil {
+			log.Printf("Error aligning asset returns: %v", err)
+			return nil, &httpError{status: http.StatusInternalServerError, message: "Failed to align asset returns"}
+		}
+		params.AssetReturns = assetReturns
+		params.Accounts = buildMultiAccount(accounts, p, req)
+		if rmd := req.RMD; rmd != nil {
+			// The table was already checked by Validate, so an error here is unexpected.
+			table, err := rmd.DivisorTable()
+			if err != nil {
+				log.Printf("Error resolving RMD divisor table: %v", err)
+				return nil, &httpError{status: http.StatusInternalServerError, message: "Failed to resolve RMD divisor table"}
+			}
+			params.Accounts.RMD = &simulation.RMD{Age: rmd.Age, StartAge: rmd.startAge(), Table: table}
+		}
+	}
+	if a := req.Annuity; a != nil {
+		params.Annuity = &simulation.Annuity{
+			Period:     a.Period,
+			Fraction:   a.Fraction,
+			Amount:     a.Amount,
+			PayoutRate: a.PayoutRate,
+			COLA:       a.COLA,
+		}
+	}
+	if s := req.Scoring; s != nil {
+		params.Scoring = &simulation.Scoring{RiskAversion: s.RiskAversion, Floor: s.ConsumptionFloor}
+	}
+	for _, s := range req.Scenarios {
+		// Scenarios were already checked by Validate, so an error here is unexpected.
+		scenario, err := s.Scenario()
+		if err != nil {
+			log.Printf("Error resolving scenario %q: %v", s.Name, err)
+			return nil, &httpError{status: http.StatusInternalServerError, message: "Failed to resolve stress scenario"}
+		}
+		params.Scenarios = append(params.Scenarios, scenario)
+	}
+	if len(params.Scenarios) > 0 {
+		for _, asset := range p.Assets {
+			params.AssetClasses = append(params.AssetClasses, simulation.AssetClass(asset.Class))
+			params.AssetWeights = append(params.AssetWeights, asset.Weight)
+		}
+	}
+
+	return &simulationRun{
+		req:          req,
+		portfolio:    p,
+		accounts:     accounts,
+		initialValue: initialValue,
+		history:      history,
+		alignment:    alignment,
+		sources:      sources,
+		excluded:     excluded,
+		params:       params,
+	}, nil
+}
 
-	var simResult *simulation.Result
+// execute runs the simulation with the requested method.
+func (run *simulationRun) execute() (*simulation.Result, error) {
+	req, params := run.req, run.params
+	var (
+		simResult *simulation.Result
+		err       error
+	)
 	// req.Method is already validated to be "normal" or "bootstrap"
 	switch req.Method {
 	case "normal":
@@ -101,39 +424,355 @@ func (h *Handler) RunSimulation(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		log.Printf("Simulation error (method: %s): %v", req.Method, err)
-		http.Error(w, fmt.Sprintf("Simulation error: %v", err), http.StatusInternalServerError)
-		return
+		return nil, &httpError{status: http.StatusInternalServerError, message: fmt.Sprintf("Simulation error: %v", err)}
 	}
 
 	if simResult == nil {
 		log.Printf("Error: Simulation completed without error, but simResult is nil (method: %s)", req.Method)
-		http.Error(w, "Internal server error: Simulation returned no result", http.StatusInternalServerError)
-		return
+		return nil, &httpError{status: http.StatusInternalServerError, message: "Internal server error: Simulation returned no result"}
 	}
 
+	return simResult, nil
+}
+
+// response converts a simulation result into the API response.
+func (run *simulationRun) response(simResult *simulation.Result) SimulationResponse {
+	p, accounts, initialValue, params := run.portfolio, run.accounts, run.initialValue, run.params
+
 	var simulatedCAGR float64
-	if req.InitialVal > 0 && params.Periods > 0 {
+	if initialValue > 0 && params.Periods > 0 {
 		years := float64(params.Periods) / 12.0
 		if years > 0 {
 			if simResult.FinalStats.Mean >= 0 {
-				simulatedCAGR = math.Pow(simResult.FinalStats.Mean/req.InitialVal, 1.0/years) - 1.0
+				simulatedCAGR = math.Pow(simResult.FinalStats.Mean/initialValue, 1.0/years) - 1.0
 			} else {
-				if req.InitialVal > 0 {
+				if initialValue > 0 {
 					simulatedCAGR = -1.0 // Represents 100% loss or more if mean becomes negative
 				}
 			}
 		}
 	}
 
+	convergence := make([]ConvergencePointResponse, len(simResult.Convergence))
+	for i, point := range simResult.Convergence {
+		convergence[i] = ConvergencePointResponse(point)
+	}
+
 	resp := SimulationResponse{
 		Paths:         simResult.Paths,
 		FinalStats:    SummaryStatsResponse(simResult.FinalStats),
 		SuccessRate:   simResult.SuccessRate,
 		SimulatedCAGR: simulatedCAGR,
+		Convergence:   convergence,
+		Converged:     simResult.Converged,
+		Seed:          simResult.Seed,
+	}
+	if simResult.Lifespan != nil {
+		lifespan := LifespanStatsResponse(*simResult.Lifespan)
+		resp.Lifespan = &lifespan
+	}
+	if a := simResult.Annuity; a != nil {
+		resp.Annuity = &AnnuityComparisonResponse{
+			PurchaseRate:         a.PurchaseRate,
+			AveragePremium:       a.AveragePremium,
+			AverageMonthlyIncome: a.AverageMonthlyIncome,
+			WithAnnuity:          OutcomeResponse(a.WithAnnuity),
+			WithoutAnnuity:       OutcomeResponse(a.WithoutAnnuity),
+		}
+	}
+	if simResult.Allocations != nil {
+		tickers := make([]string, len(p.Assets))
+		for i, a := range p.Assets {
+			tickers[i] = a.Ticker
+		}
+		resp.Allocations = &AllocationCurveResponse{Tickers: tickers, Weights: simResult.Allocations}
+	}
+	if b := simResult.Bucket; b != nil {
+		resp.Bucket = &BucketComparisonResponse{
+			EmptiedRate:        b.EmptiedRate,
+			AverageEmptyMonths: b.AverageEmptyMonths,
+			WithBucket:         OutcomeResponse(b.WithBucket),
+			WithoutBucket:      OutcomeResponse(b.WithoutBucket),
+		}
+	}
+	if a := simResult.Accounts; a != nil {
+		balances := make([]AccountBalanceResponse, len(accounts))
+		for i, account := range accounts {
+			balances[i] = AccountBalanceResponse{Name: account.Name, Type: account.TaxType, Balance: a.MeanFinalBalances[i]}
+		}
+		resp.Accounts = &AccountStatsResponse{
+			AfterTaxSpending: SummaryStatsResponse(a.Spending),
+			TaxesPaid:        SummaryStatsResponse(a.Taxes),
+			AfterTaxTerminal: SummaryStatsResponse(a.AfterTaxTerminal),
+			ExcessRMD:        SummaryStatsResponse(a.ExcessRMD),
+			SpendingPerPath:  a.AfterTaxSpending,
+			TaxesPerPath:     a.TaxesPaid,
+			FinalBalances:    balances,
+		}
+	}
+	if l := simResult.Leverage; l != nil {
+		resp.Leverage = &LeverageStatsResponse{
+			MarginCallRate:      l.MarginCallRate,
+			AverageMarginCalls:  l.AverageMarginCalls,
+			LeveragedTerminal:   SummaryStatsResponse(l.LeveragedTerminal),
+			UnleveragedTerminal: SummaryStatsResponse(l.UnleveragedTerminal),
+			WithLeverage:        OutcomeResponse(l.WithLeverage),
+			WithoutLeverage:     OutcomeResponse(l.WithoutLeverage),
+		}
+	}
+	if s := simResult.Scores; s != nil {
+		scores := ScoreStatsResponse(*s)
+		resp.Scores = &scores
+	}
+	if s := simResult.Stress; s != nil {
+		resp.Stress = &StressStatsResponse{Baseline: OutcomeResponse(s.Baseline)}
+		for _, o := range s.Scenarios {
+			resp.Stress.Scenarios = append(resp.Stress.Scenarios, ScenarioOutcomeResponse{
+				Name:       o.Name,
+				Start:      o.Start,
+				Outcome:    OutcomeResponse(o.Outcome),
+				FinalStats: SummaryStatsResponse(o.FinalStats),
+			})
+		}
+	}
+	if h := run.history; h != nil {
+		resp.History = &HistoryResponse{
+			Start:     h.Start.Format("2006-01"),
+			End:       h.End.Format("2006-01"),
+			Months:    h.Months,
+			Alignment: string(run.alignment),
+			Excluded:  excludedMonths(*h, run.excluded),
+		}
+		for _, asset := range p.Assets {
+			if spans, ok := run.sources[asset.Ticker]; ok {
+				resp.History.Sources = append(resp.History.Sources, AssetSourcesResponse{
+					Ticker:  asset.Ticker,
+					Sources: sourceRanges(spans, *h),
+				})
+			}
+		}
+	}
+	for _, ticker := range slices.Sorted(maps.Keys(run.provenance)) {
+		prov := run.provenance[ticker]
+		if prov.Source == "" {
+			continue // The fetcher does not say where its prices come from.
+		}
+		provider := ProviderResponse{Ticker: ticker, Provider: prov.Source, Cached: prov.Cached}
+		if !prov.FetchedAt.IsZero() {
+			provider.FetchedAt = prov.FetchedAt.UTC().Format(time.RFC3339)
+		}
+		resp.Providers = append(resp.Providers, provider)
+	}
+	if f := simResult.Fees; f != nil {
+		resp.Fees = &FeeStatsResponse{
+			AverageFeesPaid:          f.AverageFeesPaid,
+			MedianFeesPaid:           f.MedianFeesPaid,
+			MeanTerminalDifference:   f.MeanTerminalDifference,
+			MedianTerminalDifference: f.MedianTerminalDifference,
+			WithFees:                 OutcomeResponse(f.WithFees),
+			WithoutFees:              OutcomeResponse(f.WithoutFees),
+		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		log.Printf("Error encoding or writing simulation response: %v", err)
+	return resp
+}
+
+// excludedMonths counts the months of the history's span that fall in an excluded window.
+func excludedMonths(history portfolio.DateRange, excluded []data.Window) int {
+	n := 0
+	for month := history.Start; !month.After(history.End); month = month.AddDate(0, 1, 0) {
+		for _, w := range excluded {
+			if w.Contains(month) {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// sourceRanges reports the sources of an asset's months that fall within the history,
+// as alignment may have dropped months at either end.
+func sourceRanges(spans []portfolio.Source, history portfolio.DateRange) []SourceRangeResponse {
+	var ranges []SourceRangeResponse
+	for _, s := range spans {
+		start, end := s.Start, s.End
+		if start.Before(history.Start) {
+			start = history.Start
+		}
+		if end.After(history.End) {
+			end = history.End
+		}
+		if end.Before(start) {
+			continue
+		}
+		span := (end.Year()-start.Year())*12 + int(end.Month()-start.Month()) + 1
+		ranges = append(ranges, SourceRangeResponse{
+			Ticker:          s.Ticker,
+			Start:           start.Format("2006-01"),
+			End:             end.Format("2006-01"),
+			Months:          min(s.Months, span),
+			VolatilityScale: s.VolatilityScale,
+		})
+	}
+	return ranges
+}
+
+// buildGlidePath converts the request's glide path to weights in portfolio order.
+// The portfolio weights form an implicit first breakpoint at period 0 unless the
+// request sets one there itself.
+func buildGlidePath(g *GlidePathRequest, p model.Portfolio) *simulation.GlidePath {
+	glide := &simulation.GlidePath{Interpolation: simulation.InterpolationLinear}
+	if strings.ToLower(g.Interpolation) == "step" {
+		glide.Interpolation = simulation.InterpolationStep
+	}
+	if g.Points[0].Period > 0 {
+		weights := make([]float64, len(p.Assets))
+		for i, a := range p.Assets {
+			weights[i] = a.Weight
+		}
+		glide.Points = append(glide.Points, simulation.GlidePoint{Period: 0, Weights: weights})
+	}
+	for _, point := range g.Points {
+		weights := make([]float64, len(p.Assets))
+		for i, a := range p.Assets {
+			weights[i] = point.Weights[a.Ticker]
+		}
+		glide.Points = append(glide.Points, simulation.GlidePoint{Period: point.Period, Weights: weights})
+	}
+	return glide
+}
+
+// buildPortfolio converts the requested assets to a portfolio.
+func buildPortfolio(assets []AssetRequest) model.Portfolio {
+	var p model.Portfolio
+	for _, ar := range assets {
+		p.Assets = append(p.Assets, model.Asset{
+			Ticker:       ar.Ticker,
+			Weight:       ar.Weight,
+			ExpenseRatio: ar.ExpenseRatio,
+			Class:        ar.assetClass(),
+			Synthetic:    buildSynthetic(ar.Synthetic),
+			Assumptions:  buildAssumptions(ar.Assumptions),
+			Backfill:     buildBackfill(ar.Backfill),
+		})
+	}
+	return p
+}
+
+// buildSynthetic converts a synthetic asset's annual parameters to monthly ones.
+func buildSynthetic(s *SyntheticRequest) *model.Synthetic {
+	if s == nil {
+		return nil
+	}
+	if s.MonthlyReturn != nil {
+		return &model.Synthetic{MonthlyMean: *s.MonthlyReturn}
+	}
+	return &model.Synthetic{
+		MonthlyMean:   monthlyMean(*s.Mean),
+		MonthlyStdDev: monthlyStdDev(s.Volatility),
+		Correlations:  s.Correlations,
+	}
+}
+
+// buildBackfill converts the asset's proxies.
+func buildBackfill(b *BackfillRequest) *model.Backfill {
+	if b == nil {
+		return nil
+	}
+	return &model.Backfill{Proxies: b.Proxies, ScaleVolatility: b.ScaleVolatility}
+}
+
+// buildAssumptions converts capital market assumptions to monthly terms.
+func buildAssumptions(a *AssumptionsRequest) *model.Assumptions {
+	if a == nil {
+		return nil
+	}
+	var m model.Assumptions
+	if a.ExpectedReturn != nil {
+		mean := monthlyMean(*a.ExpectedReturn)
+		m.MonthlyMean = &mean
+	}
+	if a.Volatility != nil {
+		std := monthlyStdDev(*a.Volatility)
+		m.MonthlyStdDev = &std
+	}
+	return &m
+}
+
+// monthlyMean converts an annual return to the monthly return that compounds to it.
+func monthlyMean(annual float64) float64 {
+	return math.Pow(1+annual, 1.0/12) - 1
+}
+
+// monthlyStdDev converts an annual volatility to a monthly one, assuming independent months.
+func monthlyStdDev(annual float64) float64 {
+	return annual / math.Sqrt(12)
+}
+
+// buildAccounts converts the requested accounts. Cost basis defaults to the balance,
+// i.e. no unrealised gains.
+func buildAccounts(requests []AccountRequest) []model.Account {
+	accounts := make([]model.Account, len(requests))
+	for i, ar := range requests {
+		basis := ar.Balance
+		if ar.CostBasis != nil {
+			basis = *ar.CostBasis
+		}
+		accounts[i] = model.Account{
+			Name:      ar.Name,
+			TaxType:   strings.ToLower(ar.Type),
+			Balance:   ar.Balance,
+			CostBasis: basis,
+			Portfolio: buildPortfolio(ar.Portfolio),
+		}
+	}
+	return accounts
+}
+
+// buildMultiAccount converts the accounts to the simulation's multi-account setup, with
+// weights in the column order of the combined portfolio p.
+func buildMultiAccount(accounts []model.Account, p model.Portfolio, req SimulationRequest) *simulation.MultiAccount {
+	m := &simulation.MultiAccount{Order: simulation.WithdrawalOrder(strings.ToLower(req.WithdrawalOrder))}
+	if req.Tax != nil {
+		m.Tax = simulation.TaxModel(*req.Tax)
+	}
+	for _, a := range accounts {
+		m.Accounts = append(m.Accounts, simulation.Account{
+			Name:      a.Name,
+			Type:      simulation.TaxType(a.TaxType),
+			Balance:   a.Balance,
+			CostBasis: a.CostBasis,
+			Weights:   portfolio.AlignedWeights(a.Portfolio, p),
+		})
+	}
+	return m
+}
+
+// buildFees converts the assets' expense ratios and the advisory fee to simulation fees.
+// It returns nil when neither is set, so no fee-free comparison run is made.
+func buildFees(advisory *AdvisoryFeeRequest, p model.Portfolio) *simulation.Fees {
+	fees := &simulation.Fees{AssetExpenseRatios: make([]float64, len(p.Assets))}
+	charged := false
+	for i, a := range p.Assets {
+		fees.AssetExpenseRatios[i] = a.ExpenseRatio
+		fees.ExpenseRatio += a.Weight * a.ExpenseRatio
+		charged = charged || a.ExpenseRatio > 0
+	}
+	if advisory != nil {
+		switch {
+		case len(advisory.Tiers) > 0:
+			for _, tier := range advisory.Tiers {
+				fees.AdvisoryTiers = append(fees.AdvisoryTiers, simulation.FeeTier(tier))
+			}
+			charged = true
+		case advisory.Rate > 0:
+			fees.AdvisoryTiers = []simulation.FeeTier{{Above: 0, Rate: advisory.Rate}}
+			charged = true
+		}
+	}
+	if !charged {
+		return nil
 	}
+	return fees
 }
@@ -2,33 +2,61 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"math" // Import math package for Pow
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"portfolio-simulator/backend/internal/data"
+	"portfolio-simulator/backend/internal/data/cache"
+	"portfolio-simulator/backend/internal/data/registry"
 )
 
-// mockFetcher implements the PriceFetcher interface for mocking.
+// mockFetcher implements data.ReturnsFetcher for mocking; handlers wrap it with data.AdaptReturns.
 type mockFetcher struct {
 	returns []float64
 	err     error
 }
 
-func (m *mockFetcher) GetMonthlyReturns(ticker string) ([]float64, error) {
+func (m *mockFetcher) GetMonthlyReturns(ticker string, window data.Window) ([]float64, error) {
 	return m.returns, m.err
 }
 
+// datedFetcher implements data.ReturnSeriesFetcher with a fixed series per ticker.
+type datedFetcher struct {
+	series map[string]data.ReturnSeries
+}
+
+func (m *datedFetcher) GetMonthlyReturns(ticker string, window data.Window) ([]float64, error) {
+	return m.series[ticker].Returns, nil
+}
+
+func (m *datedFetcher) GetMonthlyReturnSeries(ticker string, window data.Window) (data.ReturnSeries, error) {
+	return m.series[ticker], nil
+}
+
+// monthsFrom returns n consecutive months starting at year/month.
+func monthsFrom(year int, month time.Month, n int) []time.Time {
+	months := make([]time.Time, n)
+	for i := range months {
+		months[i] = time.Date(year, month+time.Month(i), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return months
+}
+
 func TestRunSimulation_Normal(t *testing.T) {
 	mock := &mockFetcher{
 		returns: []float64{0.01, 0.015, -0.005, 0.02, 0.0},
 	}
 
-	handler := &Handler{Fetcher: mock}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
 
 	reqBody := SimulationRequest{
 		Portfolio: []AssetRequest{
@@ -84,7 +112,7 @@ func TestRunSimulation_BootstrapMethod(t *testing.T) {
 	mock := &mockFetcher{
 		returns: []float64{0.01, 0.015, -0.005, 0.02, 0.03, -0.01},
 	}
-	handler := &Handler{Fetcher: mock}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
 
 	reqBody := SimulationRequest{
 		Portfolio:   []AssetRequest{{Ticker: "MOCK_BOOT", Weight: 1.0}},
@@ -131,6 +159,911 @@ func TestRunSimulation_BootstrapMethod(t *testing.T) {
 	}
 }
 
+func TestRunSimulation_SobolSampler(t *testing.T) {
+	mock := &mockFetcher{
+		returns: []float64{0.01, 0.015, -0.005, 0.02, 0.0},
+	}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
+
+	reqBody := SimulationRequest{
+		Portfolio:   []AssetRequest{{Ticker: "MOCK_SOBOL", Weight: 1.0}},
+		InitialVal:  1000,
+		Periods:     12,
+		Simulations: 64,
+		Method:      "normal",
+		Sampler:     "sobol",
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "Expected status OK for sobol sampler. Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Paths, reqBody.Simulations)
+	require.Equal(t, 1.0, resp.SuccessRate)
+}
+
+func TestRunSimulation_AdaptiveConvergence(t *testing.T) {
+	mock := &mockFetcher{
+		returns: []float64{0.03, -0.04, 0.05, -0.06, 0.02},
+	}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
+
+	reqBody := SimulationRequest{
+		Portfolio:      []AssetRequest{{Ticker: "MOCK_ADAPTIVE", Weight: 1.0}},
+		InitialVal:     1000,
+		Withdrawal:     0.3,
+		Periods:        60,
+		Simulations:    100,
+		Method:         "bootstrap",
+		TargetStdError: 0.02,
+		MaxSimulations: 2000,
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "Expected status OK for adaptive run. Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.True(t, resp.Converged)
+	require.NotEmpty(t, resp.Convergence)
+
+	last := resp.Convergence[len(resp.Convergence)-1]
+	require.Len(t, resp.Paths, last.Simulations)
+	require.LessOrEqual(t, last.StdError, reqBody.TargetStdError)
+	require.InDelta(t, resp.SuccessRate, last.SuccessRate, 1e-12)
+}
+
+func TestRunSimulation_Lifespan(t *testing.T) {
+	mock := &mockFetcher{
+		returns: []float64{0.01, 0.015, -0.005, 0.02, 0.0},
+	}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
+
+	reqBody := SimulationRequest{
+		Portfolio:   []AssetRequest{{Ticker: "MOCK_LIFESPAN", Weight: 1.0}},
+		InitialVal:  100000,
+		Withdrawal:  0.05,
+		Inflation:   0.02,
+		Periods:     40 * 12,
+		Simulations: 200,
+		Method:      "bootstrap",
+		Lifespan: &LifespanRequest{
+			Age:   80,
+			Table: []MortalityRateRequest{{Age: 80, Qx: 0.5}, {Age: 81, Qx: 0.5}, {Age: 82, Qx: 1.0}},
+		},
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "Expected status OK for lifespan run. Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.NotNil(t, resp.Lifespan)
+	// Everyone dies within three years, long before a 5% withdrawal can exhaust the portfolio.
+	require.Equal(t, 1.0, resp.SuccessRate)
+	require.Equal(t, 0.0, resp.Lifespan.ProbabilityOfRuin)
+	require.LessOrEqual(t, resp.Lifespan.ExpectedLifespanYears, 3.0)
+	require.Greater(t, resp.Lifespan.ExpectedBequest, 0.0)
+}
+
+func TestRunSimulation_AnnuityComparison(t *testing.T) {
+	mock := &mockFetcher{
+		returns: []float64{0.01, 0.015, -0.005, 0.02, 0.0, -0.01},
+	}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
+
+	reqBody := SimulationRequest{
+		Portfolio:   []AssetRequest{{Ticker: "MOCK_ANNUITY", Weight: 1.0}},
+		InitialVal:  100000,
+		Withdrawal:  0.05,
+		Inflation:   0.02,
+		Periods:     30 * 12,
+		Simulations: 100,
+		Method:      "bootstrap",
+		Seed:        42,
+		Annuity:     &AnnuityRequest{Period: 60, Fraction: 0.5, PayoutRate: 0.07, COLA: 0.02},
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "Expected status OK for annuity run. Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Equal(t, int64(42), resp.Seed)
+	require.NotNil(t, resp.Annuity)
+	require.Equal(t, resp.SuccessRate, resp.Annuity.WithAnnuity.SuccessRate)
+	require.Greater(t, resp.Annuity.AveragePremium, 0.0)
+	require.InDelta(t, resp.Annuity.AveragePremium*0.07/12, resp.Annuity.AverageMonthlyIncome, 1e-6)
+}
+
+func TestRunSimulation_BucketComparison(t *testing.T) {
+	mock := &mockFetcher{
+		returns: []float64{0.03, -0.04, 0.02, -0.01, 0.015, 0.0},
+	}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
+
+	reqBody := SimulationRequest{
+		Portfolio:   []AssetRequest{{Ticker: "MOCK_BUCKET", Weight: 1.0}},
+		InitialVal:  100000,
+		Withdrawal:  0.05,
+		Inflation:   0.02,
+		Periods:     20 * 12,
+		Simulations: 100,
+		Method:      "bootstrap",
+		Bucket:      &BucketRequest{Years: 2, Yield: 0.03},
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "Expected status OK for bucket run. Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.NotNil(t, resp.Bucket)
+	require.Equal(t, resp.SuccessRate, resp.Bucket.WithBucket.SuccessRate)
+	require.GreaterOrEqual(t, resp.Bucket.EmptiedRate, 0.0)
+	require.LessOrEqual(t, resp.Bucket.EmptiedRate, 1.0)
+}
+
+func TestRunSimulation_GlidePath(t *testing.T) {
+	mock := &mockFetcher{
+		returns: []float64{0.01, 0.015, -0.005, 0.02, 0.0},
+	}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
+
+	reqBody := SimulationRequest{
+		Portfolio: []AssetRequest{
+			{Ticker: "EQUITY", Weight: 0.9},
+			{Ticker: "BONDS", Weight: 0.1},
+		},
+		InitialVal:  1000,
+		Periods:     24,
+		Simulations: 10,
+		Method:      "bootstrap",
+		GlidePath: &GlidePathRequest{
+			Points: []GlidePointRequest{{Period: 12, Weights: map[string]float64{"EQUITY": 0.5, "BONDS": 0.5}}},
+		},
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "Expected status OK for glide path run. Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.NotNil(t, resp.Allocations)
+	require.Equal(t, []string{"EQUITY", "BONDS"}, resp.Allocations.Tickers)
+	require.Len(t, resp.Allocations.Weights, reqBody.Periods+1)
+	require.Equal(t, []float64{0.9, 0.1}, resp.Allocations.Weights[0], "Portfolio weights apply at period 0")
+	require.InDeltaSlice(t, []float64{0.7, 0.3}, resp.Allocations.Weights[6], 1e-12)
+	require.Equal(t, []float64{0.5, 0.5}, resp.Allocations.Weights[24])
+}
+
+func TestRunSimulation_Fees(t *testing.T) {
+	mock := &mockFetcher{
+		returns: []float64{0.03, -0.04, 0.02, -0.01, 0.015, 0.0},
+	}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
+
+	reqBody := SimulationRequest{
+		Portfolio: []AssetRequest{
+			{Ticker: "CHEAP", Weight: 0.6, ExpenseRatio: 0.0003},
+			{Ticker: "PRICEY", Weight: 0.4, ExpenseRatio: 0.0075},
+		},
+		InitialVal:  1000000,
+		Withdrawal:  0.04,
+		Periods:     10 * 12,
+		Simulations: 100,
+		Method:      "bootstrap",
+		AdvisoryFee: &AdvisoryFeeRequest{Tiers: []FeeTierRequest{
+			{Above: 0, Rate: 0.01},
+			{Above: 1000000, Rate: 0.005},
+		}},
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "Expected status OK for run with fees. Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.NotNil(t, resp.Fees)
+	require.Greater(t, resp.Fees.AverageFeesPaid, 0.0)
+	require.Greater(t, resp.Fees.MeanTerminalDifference, 0.0)
+	require.Equal(t, resp.FinalStats.Mean, resp.Fees.WithFees.MeanTerminal)
+}
+
+func TestRunSimulation_Accounts(t *testing.T) {
+	mock := &mockFetcher{
+		returns: []float64{0.01, 0.015, -0.005, 0.02, 0.0},
+	}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
+
+	basis := 60000.0
+	reqBody := SimulationRequest{
+		Accounts: []AccountRequest{
+			{Name: "brokerage", Type: "taxable", Balance: 100000, CostBasis: &basis, Portfolio: []AssetRequest{{Ticker: "VTI", Weight: 1.0}}},
+			{Name: "401k", Type: "tax-deferred", Balance: 200000, Portfolio: []AssetRequest{{Ticker: "VTI", Weight: 0.6}, {Ticker: "BND", Weight: 0.4}}},
+			{Name: "roth", Type: "roth", Balance: 50000, Portfolio: []AssetRequest{{Ticker: "VTI", Weight: 1.0}}},
+		},
+		Withdrawal:      0.04,
+		Periods:         24,
+		Simulations:     20,
+		Method:          "bootstrap",
+		WithdrawalOrder: "conventional",
+		Tax:             &TaxRequest{OrdinaryRate: 0.22, CapitalGainsRate: 0.15},
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "Expected status OK for multi-account run. Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.NotNil(t, resp.Accounts)
+	require.Len(t, resp.Accounts.SpendingPerPath, 20)
+	require.Len(t, resp.Accounts.TaxesPerPath, 20)
+	require.InDelta(t, 350000*0.04*2, resp.Accounts.AfterTaxSpending.Mean, 1e-6, "Spending is fully funded over two years")
+	require.Greater(t, resp.Accounts.TaxesPaid.Mean, 0.0, "Selling from the brokerage realises gains")
+	require.Len(t, resp.Accounts.FinalBalances, 3)
+	require.Equal(t, "401k", resp.Accounts.FinalBalances[1].Name)
+	require.Equal(t, "tax-deferred", resp.Accounts.FinalBalances[1].Type)
+	require.Equal(t, 350000.0, resp.Paths[0][0])
+}
+
+func TestRunSimulation_RMD(t *testing.T) {
+	mock := &mockFetcher{
+		returns: []float64{0.01, 0.015, -0.005, 0.02, 0.0},
+	}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
+
+	reqBody := SimulationRequest{
+		Accounts: []AccountRequest{
+			{Name: "brokerage", Type: "taxable", Balance: 0, Portfolio: []AssetRequest{{Ticker: "VTI", Weight: 1.0}}},
+			{Name: "ira", Type: "tax-deferred", Balance: 500000, Portfolio: []AssetRequest{{Ticker: "VTI", Weight: 1.0}}},
+		},
+		Periods:     36,
+		Simulations: 10,
+		Method:      "bootstrap",
+		Tax:         &TaxRequest{OrdinaryRate: 0.22},
+		RMD:         &RMDRequest{Age: 74, DivisorsCSV: "age,divisor\n73,26.5\n74,25.5\n75,24.6\n76,23.7"},
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "Expected status OK for RMD run. Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.NotNil(t, resp.Accounts)
+	require.Greater(t, resp.Accounts.ExcessRMD.Min, 500000/25.5*0.9, "Three years of distributions are forced without spending")
+	require.Greater(t, resp.Accounts.FinalBalances[0].Balance, 0.0, "Distributions are reinvested in the brokerage account")
+	require.InDelta(t, resp.Accounts.ExcessRMD.Mean*0.22, resp.Accounts.TaxesPaid.Mean, 1e-6)
+}
+
+func TestRunSimulation_Leverage(t *testing.T) {
+	mock := &mockFetcher{
+		returns: []float64{0.03, -0.04, 0.02, -0.01, 0.015, 0.0},
+	}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
+
+	reqBody := SimulationRequest{
+		Portfolio:   []AssetRequest{{Ticker: "MOCK_LEVERAGE", Weight: 1.0}},
+		InitialVal:  100000,
+		Periods:     10 * 12,
+		Simulations: 100,
+		Method:      "bootstrap",
+		Leverage:    &LeverageRequest{Ratio: 2, CashRates: []float64{0.02, 0.03}, Spread: 0.01},
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "Expected status OK for leveraged run. Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.NotNil(t, resp.Leverage)
+	require.Equal(t, resp.FinalStats, resp.Leverage.LeveragedTerminal)
+	require.GreaterOrEqual(t, resp.Leverage.MarginCallRate, 0.0)
+	require.LessOrEqual(t, resp.Leverage.MarginCallRate, 1.0)
+	require.Equal(t, resp.Leverage.UnleveragedTerminal.Mean, resp.Leverage.WithoutLeverage.MeanTerminal)
+}
+
+func TestRunSimulation_SyntheticAssets(t *testing.T) {
+	// Any fetch fails, so the run only succeeds if synthetic assets skip the fetcher.
+	handler := &Handler{Fetcher: data.AdaptReturns(&mockFetcher{err: errors.New("no such ticker")}, "mock")}
+
+	monthly := 0.0025
+	reqBody := SimulationRequest{
+		Portfolio:   []AssetRequest{{Ticker: "CASH", Weight: 1.0, Synthetic: &SyntheticRequest{MonthlyReturn: &monthly}}},
+		InitialVal:  100000,
+		Periods:     24,
+		Simulations: 10,
+		Method:      "bootstrap",
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "Expected status OK for synthetic assets. Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.InDelta(t, 100000*math.Pow(1+monthly, 24), resp.FinalStats.Mean, 1e-6)
+	require.InDelta(t, resp.FinalStats.Mean, resp.FinalStats.Min, 1e-6, "A constant return leaves no dispersion")
+}
+
+func TestRunSimulation_CapitalMarketAssumptions(t *testing.T) {
+	mock := &mockFetcher{
+		returns: []float64{0.04, -0.03, 0.05, 0.02, -0.01, 0.03},
+	}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
+
+	expected, vol := 0.06, 0.12
+	reqBody := SimulationRequest{
+		Portfolio: []AssetRequest{{
+			Ticker:      "MOCK_CMA",
+			Weight:      1.0,
+			Assumptions: &AssumptionsRequest{ExpectedReturn: &expected, Volatility: &vol},
+		}},
+		InitialVal:  100000,
+		Periods:     12,
+		Simulations: 2000,
+		Method:      "normal",
+		Seed:        11,
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "Expected status OK for capital market assumptions. Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	// The history averages over 1.6% a month; the assumptions bring a year down to about 6%.
+	require.InDelta(t, 100000*(1+expected), resp.FinalStats.Mean, 1000)
+}
+
+func TestRunSimulation_StressScenarios(t *testing.T) {
+	mock := &mockFetcher{
+		returns: []float64{0.02, -0.01, 0.015, 0.005, 0.01, -0.005},
+	}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
+
+	reqBody := SimulationRequest{
+		Portfolio:   []AssetRequest{{Ticker: "MOCK_STRESS", Weight: 1.0}},
+		InitialVal:  100000,
+		Withdrawal:  0.04,
+		Periods:     5 * 12,
+		Simulations: 100,
+		Method:      "bootstrap",
+		Scenarios: []ScenarioRequest{
+			{Name: "2008-crash"},
+			{Name: "flash crash", Start: 24, Returns: []float64{-0.3}},
+		},
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "Expected status OK for stress scenarios. Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.NotNil(t, resp.Stress)
+	require.Equal(t, resp.FinalStats.Mean, resp.Stress.Baseline.MeanTerminal)
+	require.Len(t, resp.Stress.Scenarios, 2)
+	require.Equal(t, "2008-crash", resp.Stress.Scenarios[0].Name)
+	require.Equal(t, 24, resp.Stress.Scenarios[1].Start)
+	for _, s := range resp.Stress.Scenarios {
+		require.Less(t, s.Outcome.MeanTerminal, resp.Stress.Baseline.MeanTerminal, "Scenario %s should hurt", s.Name)
+	}
+}
+
+func TestRunSimulation_StressScenarioByAssetClass(t *testing.T) {
+	mock := &mockFetcher{
+		returns: []float64{0.02, -0.01, 0.015, 0.005, 0.01, -0.005},
+	}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
+
+	// stressLoss runs 2008 against a 40/60 portfolio and returns the share of the
+	// baseline's mean terminal value that the crash takes away.
+	stressLoss := func(bondClass string) float64 {
+		reqBody := SimulationRequest{
+			Portfolio: []AssetRequest{
+				{Ticker: "MOCK_STOCKS", Weight: 0.4},
+				{Ticker: "MOCK_BONDS", Weight: 0.6, AssetClass: bondClass},
+			},
+			InitialVal:  100000,
+			Periods:     3 * 12,
+			Simulations: 100,
+			Method:      "bootstrap",
+			Seed:        11,
+			Scenarios:   []ScenarioRequest{{Name: "2008-crash"}},
+		}
+		body, err := json.Marshal(reqBody)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.RunSimulation(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, "Body: %s", rr.Body.String())
+
+		var resp SimulationResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		require.NotNil(t, resp.Stress)
+		require.Len(t, resp.Stress.Scenarios, 1)
+		return 1 - resp.Stress.Scenarios[0].Outcome.MeanTerminal/resp.Stress.Baseline.MeanTerminal
+	}
+
+	allEquity := stressLoss("")
+	withBonds := stressLoss("Bond")
+	require.Greater(t, allEquity, 0.4, "an all-equity portfolio should lose about half in 2008")
+	// Were the bonds crashed too, both portfolios would lose the same share.
+	require.Less(t, withBonds, allEquity*0.6, "bonds should not earn the equity crash")
+}
+
+func TestRunSimulation_Scoring(t *testing.T) {
+	mock := &mockFetcher{
+		returns: []float64{0.03, -0.04, 0.02, -0.01, 0.015, 0.0},
+	}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
+
+	reqBody := SimulationRequest{
+		Portfolio:   []AssetRequest{{Ticker: "MOCK_SCORING", Weight: 1.0}},
+		InitialVal:  100000,
+		Withdrawal:  0.08,
+		Periods:     25 * 12,
+		Simulations: 200,
+		Method:      "bootstrap",
+		Scoring:     &ScoringRequest{RiskAversion: 3, ConsumptionFloor: 0.3},
+		AdvisoryFee: &AdvisoryFeeRequest{Rate: 0.01},
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "Expected status OK for scoring. Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.NotNil(t, resp.Scores)
+	require.Greater(t, resp.Scores.CertaintyEquivalent, 0.3)
+	require.LessOrEqual(t, resp.Scores.CertaintyEquivalent, 1.0)
+	require.LessOrEqual(t, resp.Scores.AverageShortfall, resp.Scores.WorstShortfall)
+	// Comparison runs are scored too, so strategies can be compared on one number.
+	require.Equal(t, resp.Scores.CertaintyEquivalent, resp.Fees.WithFees.CertaintyEquivalent)
+	require.GreaterOrEqual(t, resp.Fees.WithoutFees.CertaintyEquivalent, resp.Fees.WithFees.CertaintyEquivalent)
+}
+
+func TestRunSimulation_AlignsByDate(t *testing.T) {
+	// OLD gained 2% a month from 2000 and has lost 5% a month since 2020, when NEW starts
+	// gaining 1% a month.
+	old := make([]float64, 300)
+	for i := range old {
+		old[i] = 0.02
+		if i >= 240 {
+			old[i] = -0.05
+		}
+	}
+	newReturns := make([]float64, 60)
+	for i := range newReturns {
+		newReturns[i] = 0.01
+	}
+	fetcher := &datedFetcher{series: map[string]data.ReturnSeries{
+		"OLD": {Months: monthsFrom(2000, time.January, len(old)), Returns: old},
+		"NEW": {Months: monthsFrom(2020, time.January, len(newReturns)), Returns: newReturns},
+	}}
+	handler := &Handler{Fetcher: data.AdaptReturns(fetcher, "mock")}
+
+	run := func(alignment string) SimulationResponse {
+		reqBody := SimulationRequest{
+			Portfolio:   []AssetRequest{{Ticker: "OLD", Weight: 0.5}, {Ticker: "NEW", Weight: 0.5}},
+			InitialVal:  100000,
+			Periods:     12,
+			Simulations: 50,
+			Method:      "bootstrap",
+			Alignment:   alignment,
+		}
+		body, err := json.Marshal(reqBody)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+		handler.RunSimulation(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, "Body: %s", rr.Body.String())
+
+		var resp SimulationResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		return resp
+	}
+
+	resp := run("")
+	require.Equal(t, &HistoryResponse{Start: "2020-01", End: "2024-12", Months: 60, Alignment: "intersect"}, resp.History)
+	// Index alignment would pair NEW's months with OLD's first five years of gains.
+	require.InDelta(t, 100000*math.Pow(0.98, 12), resp.FinalStats.Median, 1e-6)
+
+	resp = run("union")
+	require.Equal(t, &HistoryResponse{Start: "2000-01", End: "2024-12", Months: 300, Alignment: "union"}, resp.History)
+}
+
+func TestRunSimulation_Backfill(t *testing.T) {
+	fetcher := &datedFetcher{series: map[string]data.ReturnSeries{
+		"ETF":   {Months: monthsFrom(2020, time.January, 4), Returns: []float64{0.01, -0.01, 0.01, -0.01}},
+		"INDEX": {Months: monthsFrom(2010, time.January, 124), Returns: make([]float64, 124)},
+		"BND":   {Months: monthsFrom(2015, time.January, 64), Returns: make([]float64, 64)},
+	}}
+	handler := &Handler{Fetcher: data.AdaptReturns(fetcher, "mock")}
+
+	reqBody := SimulationRequest{
+		Portfolio: []AssetRequest{
+			{Ticker: "ETF", Weight: 0.6, Backfill: &BackfillRequest{Proxies: []string{"INDEX"}}},
+			{Ticker: "BND", Weight: 0.4},
+		},
+		InitialVal:  100000,
+		Periods:     12,
+		Simulations: 10,
+		Method:      "bootstrap",
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, "Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	// BND's history, not ETF's four months, now limits the window.
+	require.Equal(t, "2015-01", resp.History.Start)
+	require.Equal(t, "2020-04", resp.History.End)
+	require.Equal(t, 64, resp.History.Months)
+	require.Equal(t, []AssetSourcesResponse{{Ticker: "ETF", Sources: []SourceRangeResponse{
+		{Ticker: "INDEX", Start: "2015-01", End: "2019-12", Months: 60, VolatilityScale: 1},
+		{Ticker: "ETF", Start: "2020-01", End: "2020-04", Months: 4, VolatilityScale: 1},
+	}}}, resp.History.Sources)
+}
+
+func TestRunSimulation_AssumptionsLosingEverything(t *testing.T) {
+	handler := &Handler{Fetcher: data.AdaptReturns(&mockFetcher{returns: []float64{0.01, 0.01, 0.01, 0.01, 0.01, 0.01, 0.01, 0.01, 0.01, -0.09}}, "mock")}
+	volatility := 2.0
+	reqBody := SimulationRequest{
+		Portfolio:   []AssetRequest{{Ticker: "WILD", Weight: 1, Assumptions: &AssumptionsRequest{Volatility: &volatility}}},
+		InitialVal:  100000,
+		Periods:     12,
+		Simulations: 10,
+		Method:      "bootstrap",
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body)))
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	require.Contains(t, rr.Body.String(), "a loss of everything")
+}
+
+// seriesFetcher implements data.PriceFetcher with fixed prices per ticker.
+type seriesFetcher map[string]data.PriceSeries
+
+func (f seriesFetcher) FetchPrices(_ context.Context, ticker string, _ data.FetchOptions) (data.PriceSeries, error) {
+	return f[ticker], nil
+}
+
+func TestRunSimulation_BackfillsSinglePrice(t *testing.T) {
+	monthEnd := func(year int, month time.Month) time.Time { return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC) }
+	index := data.PriceSeries{Ticker: "INDEX", Frequency: data.FrequencyMonthly, PriceType: data.PriceAdjusted}
+	for i := range 25 {
+		index.Dates = append(index.Dates, monthEnd(2022, time.January+time.Month(i)))
+		index.Closes = append(index.Closes, 100+float64(i%3))
+	}
+	handler := &Handler{Fetcher: seriesFetcher{
+		"INDEX": index,
+		// Launched last month: one month-end price and so no returns yet.
+		"NEWETF": {Ticker: "NEWETF", Frequency: data.FrequencyMonthly, PriceType: data.PriceAdjusted, Dates: []time.Time{monthEnd(2024, time.January)}, Closes: []float64{50}},
+	}}
+
+	reqBody := SimulationRequest{
+		Portfolio:   []AssetRequest{{Ticker: "NEWETF", Weight: 1, Backfill: &BackfillRequest{Proxies: []string{"INDEX"}}}},
+		InitialVal:  100000,
+		Periods:     12,
+		Simulations: 10,
+		Method:      "bootstrap",
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body)))
+	require.Equal(t, http.StatusOK, rr.Code, "Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Equal(t, "2022-02", resp.History.Start)
+	require.Equal(t, "2024-01", resp.History.End)
+	require.Equal(t, []AssetSourcesResponse{{Ticker: "NEWETF", Sources: []SourceRangeResponse{
+		{Ticker: "INDEX", Start: "2022-02", End: "2024-01", Months: 24, VolatilityScale: 1},
+	}}}, resp.History.Sources, "All of the proxy's history stands in for the new fund")
+}
+
+func TestRunSimulation_DateRange(t *testing.T) {
+	returns := make([]float64, 36)
+	for i := range returns {
+		returns[i] = 0.01
+		if i >= 24 {
+			returns[i] = -0.2 // 2020 is a crash
+		}
+	}
+	fetcher := &datedFetcher{series: map[string]data.ReturnSeries{
+		"SPY": {Months: monthsFrom(2018, time.January, len(returns)), Returns: returns},
+	}}
+	handler := &Handler{Fetcher: data.AdaptReturns(fetcher, "mock")}
+
+	reqBody := SimulationRequest{
+		Portfolio:   []AssetRequest{{Ticker: "SPY", Weight: 1}},
+		InitialVal:  100000,
+		Periods:     12,
+		Simulations: 20,
+		Method:      "bootstrap",
+		StartDate:   "2018-07",
+		Exclude:     []DateRangeRequest{{Start: "2020-01", End: "2020-12"}},
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, "Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Equal(t, &HistoryResponse{Start: "2018-07", End: "2019-12", Months: 18, Alignment: "intersect"}, resp.History)
+	// Only the months before the excluded crash are drawn.
+	require.InDelta(t, 100000*math.Pow(1.01, 12), resp.FinalStats.Min, 1e-6)
+
+	// Undated returns cannot be filtered by month.
+	handler = &Handler{Fetcher: data.AdaptReturns(&mockFetcher{returns: returns}, "mock")}
+	req = httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body))
+	rr = httptest.NewRecorder()
+	handler.RunSimulation(rr, req)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+}
+
+// countingReturns is a mockFetcher that counts its calls.
+type countingReturns struct {
+	mockFetcher
+	calls int
+}
+
+func (c *countingReturns) GetMonthlyReturns(ticker string, window data.Window) ([]float64, error) {
+	c.calls++
+	return c.mockFetcher.GetMonthlyReturns(ticker, window)
+}
+
+func TestRunSimulation_CacheModes(t *testing.T) {
+	counting := &countingReturns{mockFetcher: mockFetcher{returns: []float64{0.01, -0.02, 0.03}}}
+	cached, err := cache.New(data.AdaptReturns(counting, "mock"), t.TempDir(), time.Hour)
+	require.NoError(t, err)
+	handler := &Handler{Fetcher: cached}
+
+	run := func(mode string) {
+		reqBody := SimulationRequest{
+			Portfolio:   []AssetRequest{{Ticker: "CACHED", Weight: 1}},
+			InitialVal:  1000,
+			Periods:     12,
+			Simulations: 10,
+			Method:      "bootstrap",
+			Cache:       mode,
+		}
+		body, err := json.Marshal(reqBody)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.RunSimulation(rr, httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body)))
+		require.Equal(t, http.StatusOK, rr.Code, "Body: %s", rr.Body.String())
+	}
+
+	run("")
+	run("")
+	require.Equal(t, 1, counting.calls, "The second run is served from the cache")
+	run("refresh")
+	run("bypass")
+	require.Equal(t, 3, counting.calls)
+}
+
+func TestRunSimulation_ReportsProviders(t *testing.T) {
+	reg := registry.New()
+	require.NoError(t, reg.Register("csv", data.AdaptReturns(&mockFetcher{err: errors.New("no file")}, "mock")))
+	require.NoError(t, reg.Register("tiingo", data.AdaptReturns(&mockFetcher{returns: []float64{0.01, -0.02, 0.03}}, "mock")))
+	composite, err := reg.Composite([]string{"csv", "tiingo"}, nil)
+	require.NoError(t, err)
+	handler := &Handler{Fetcher: composite}
+
+	reqBody := SimulationRequest{
+		Portfolio:   []AssetRequest{{Ticker: "VTI", Weight: 0.6}, {Ticker: "BND", Weight: 0.4}},
+		InitialVal:  1000,
+		Periods:     12,
+		Simulations: 10,
+		Method:      "bootstrap",
+	}
+	body, err := json.Marshal(reqBody)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(body)))
+	require.Equal(t, http.StatusOK, rr.Code, "Body: %s", rr.Body.String())
+
+	var resp SimulationResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp.Providers, 2)
+	require.Equal(t, "BND", resp.Providers[0].Ticker, "Providers are listed by ticker")
+	for _, p := range resp.Providers {
+		require.Equal(t, "tiingo", p.Provider, "The CSV provider failed, so Tiingo served %s", p.Ticker)
+		require.NotEmpty(t, p.FetchedAt)
+		require.False(t, p.Cached)
+	}
+}
+
+// blockingFetcher implements data.PriceFetcher. It holds each fetch for delay (or until
+// the context ends), fails the tickers in fail and records the most fetches in flight.
+type blockingFetcher struct {
+	delay    time.Duration
+	fail     map[string]bool
+	inFlight atomic.Int32
+	peak     atomic.Int32
+	calls    atomic.Int32
+}
+
+func (f *blockingFetcher) FetchPrices(ctx context.Context, ticker string, _ data.FetchOptions) (data.PriceSeries, error) {
+	f.calls.Add(1)
+	n := f.inFlight.Add(1)
+	defer f.inFlight.Add(-1)
+	for {
+		peak := f.peak.Load()
+		if n <= peak || f.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return data.PriceSeries{}, ctx.Err()
+	}
+	if f.fail[ticker] {
+		return data.PriceSeries{}, errors.New("no data")
+	}
+	return data.PriceSeries{Ticker: ticker, Frequency: data.FrequencyMonthly, PriceType: data.PriceAdjusted, Closes: []float64{1, 1.01, 0.99, 1.02}}, nil
+}
+
+// fetchRequest returns a simulation request holding the given tickers equally.
+func fetchRequest(t *testing.T, tickers ...string) []byte {
+	portfolio := make([]AssetRequest, len(tickers))
+	for i, ticker := range tickers {
+		portfolio[i] = AssetRequest{Ticker: ticker, Weight: 1 / float64(len(tickers))}
+	}
+	body, err := json.Marshal(SimulationRequest{Portfolio: portfolio, InitialVal: 1000, Periods: 12, Simulations: 10, Method: "bootstrap"})
+	require.NoError(t, err)
+	return body
+}
+
+func TestRunSimulation_FetchesConcurrently(t *testing.T) {
+	fetcher := &blockingFetcher{delay: 20 * time.Millisecond}
+	handler := &Handler{Fetcher: fetcher, MaxConcurrentFetches: 3}
+
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(fetchRequest(t, "A", "B", "C", "D", "E", "F", "G", "H"))))
+	require.Equal(t, http.StatusOK, rr.Code, "Body: %s", rr.Body.String())
+	require.Equal(t, int32(8), fetcher.calls.Load())
+	require.Equal(t, int32(3), fetcher.peak.Load(), "Fetches run in parallel up to the limit")
+}
+
+func TestRunSimulation_ReportsEveryFailedTicker(t *testing.T) {
+	fetcher := &blockingFetcher{fail: map[string]bool{"B": true, "D": true}}
+	handler := &Handler{Fetcher: fetcher}
+
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(fetchRequest(t, "A", "B", "C", "D"))))
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.Contains(t, rr.Body.String(), "Failed to fetch returns for tickers B, D")
+}
+
+func TestRunSimulation_FetchTimeout(t *testing.T) {
+	fetcher := &blockingFetcher{delay: time.Minute}
+	handler := &Handler{Fetcher: fetcher, FetchTimeout: 20 * time.Millisecond}
+
+	rr := httptest.NewRecorder()
+	handler.RunSimulation(rr, httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(fetchRequest(t, "A", "B"))))
+	require.Equal(t, http.StatusGatewayTimeout, rr.Code)
+	require.Contains(t, rr.Body.String(), "Timed out fetching returns for tickers A, B")
+}
+
+func TestRunSimulation_ClientDisconnectCancelsFetches(t *testing.T) {
+	fetcher := &blockingFetcher{delay: time.Minute}
+	handler := &Handler{Fetcher: fetcher, MaxConcurrentFetches: 1}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", bytes.NewBuffer(fetchRequest(t, "A", "B", "C"))).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		handler.RunSimulation(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Fetches kept running after the client went away")
+	}
+	require.Equal(t, int32(1), fetcher.calls.Load(), "Queued tickers are not fetched once the request is cancelled")
+}
+
 // TestRunSimulation_FailureInFetcher, TestRunSimulation_InvalidRequestBody, TestRunSimulation_ValidationErrors, TestRunSimulation_NoReturnsFromFetcher
 // remain the same as they don't reach the point of successful response decoding for CAGR.
 // ... (rest of the test file as previously provided) ...
@@ -138,7 +1071,7 @@ func TestRunSimulation_FailureInFetcher(t *testing.T) {
 	mock := &mockFetcher{
 		err: errors.New("API provider is down"),
 	}
-	handler := &Handler{Fetcher: mock}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
 
 	reqBody := SimulationRequest{
 		Portfolio: []AssetRequest{
@@ -163,7 +1096,7 @@ func TestRunSimulation_FailureInFetcher(t *testing.T) {
 }
 
 func TestRunSimulation_InvalidRequestBody(t *testing.T) {
-	handler := &Handler{Fetcher: &mockFetcher{}} // Fetcher setup doesn't matter much here
+	handler := &Handler{Fetcher: data.AdaptReturns(&mockFetcher{}, "mock")} // Fetcher setup doesn't matter much here
 
 	malformedJSON := `{"initialValue": 1000, "periods": 12, "portfolio": [`                        // Missing closing bracket and brace
 	req := httptest.NewRequest(http.MethodPost, "/api/simulate", strings.NewReader(malformedJSON)) // Use strings.Reader
@@ -204,6 +1137,131 @@ func TestRunSimulation_ValidationErrors(t *testing.T) {
 		{"periods too high", func(r *SimulationRequest) { r.Periods = 1201 }, "periods must be between 1 and 1200"},
 		{"zero simulations", func(r *SimulationRequest) { r.Simulations = 0 }, "simulations must be between 1 and 10000"},
 		{"simulations too high", func(r *SimulationRequest) { r.Simulations = 10001 }, "simulations must be between 1 and 10000"},
+		{"negative target std error", func(r *SimulationRequest) { r.TargetStdError = -0.01 }, "target standard error must be between 0 and 0.5"},
+		{"max simulations below batch", func(r *SimulationRequest) { r.MaxSimulations = 5 }, "max simulations must be between simulations and 10000"},
+		{"max simulations too high", func(r *SimulationRequest) { r.MaxSimulations = 10001 }, "max simulations must be between simulations and 10000"},
+		{"lifespan age too high", func(r *SimulationRequest) { r.Lifespan = &LifespanRequest{Age: 130, Sex: "male"} }, "lifespan age must be between 0 and 119"},
+		{"lifespan unknown sex", func(r *SimulationRequest) { r.Lifespan = &LifespanRequest{Age: 65, Sex: "other"} }, "no built-in table"},
+		{"lifespan table missing start age", func(r *SimulationRequest) {
+			r.Lifespan = &LifespanRequest{Age: 65, TableCSV: "age,qx\n70,0.02\n71,0.03"}
+		}, "lifespan table must include the starting age"},
+		{"lifespan both tables", func(r *SimulationRequest) {
+			r.Lifespan = &LifespanRequest{Age: 65, TableCSV: "65,0.01", Table: []MortalityRateRequest{{Age: 65, Qx: 0.01}}}
+		}, "either table or tableCsv"},
+		{"annuity period past horizon", func(r *SimulationRequest) {
+			r.Annuity = &AnnuityRequest{Period: 12, Fraction: 0.3, PayoutRate: 0.06}
+		}, "annuity period must be between 0 and periods-1"},
+		{"annuity fraction and amount", func(r *SimulationRequest) {
+			r.Annuity = &AnnuityRequest{Fraction: 0.3, Amount: 500, PayoutRate: 0.06}
+		}, "exactly one of a positive fraction or amount"},
+		{"annuity missing payout rate", func(r *SimulationRequest) { r.Annuity = &AnnuityRequest{Fraction: 0.3} }, "annuity payout rate"},
+		{"bucket without withdrawals", func(r *SimulationRequest) {
+			r.Withdrawal = 0
+			r.Bucket = &BucketRequest{Years: 2}
+		}, "bucket strategy requires a withdrawal rate"},
+		{"bucket zero years", func(r *SimulationRequest) { r.Bucket = &BucketRequest{} }, "bucket years must be greater than 0"},
+		{"bucket invalid refill", func(r *SimulationRequest) { r.Bucket = &BucketRequest{Years: 2, Refill: "weekly"} }, "bucket refill must be"},
+		{"glide path without points", func(r *SimulationRequest) { r.GlidePath = &GlidePathRequest{} }, "glide path must have at least one point"},
+		{"glide path unknown ticker", func(r *SimulationRequest) {
+			r.GlidePath = &GlidePathRequest{Points: []GlidePointRequest{{Period: 6, Weights: map[string]float64{"OTHER": 1}}}}
+		}, "glide path ticker OTHER is not in the portfolio"},
+		{"glide path weights not 1", func(r *SimulationRequest) {
+			r.GlidePath = &GlidePathRequest{Points: []GlidePointRequest{{Period: 6, Weights: map[string]float64{"VALID": 0.5}}}}
+		}, "glide path weights at period 6 must sum to approximately 1.0"},
+		{"glide path past horizon", func(r *SimulationRequest) {
+			r.GlidePath = &GlidePathRequest{Points: []GlidePointRequest{{Period: 13, Weights: map[string]float64{"VALID": 1}}}}
+		}, "glide path periods must be between 0 and periods"},
+		{"advisory fee rate too high", func(r *SimulationRequest) { r.AdvisoryFee = &AdvisoryFeeRequest{Rate: 0.2} }, "advisory fee rate must be between 0 and 0.1"},
+		{"advisory fee rate and tiers", func(r *SimulationRequest) {
+			r.AdvisoryFee = &AdvisoryFeeRequest{Rate: 0.01, Tiers: []FeeTierRequest{{Above: 0, Rate: 0.01}}}
+		}, "either a rate or tiers"},
+		{"advisory fee tiers not increasing", func(r *SimulationRequest) {
+			r.AdvisoryFee = &AdvisoryFeeRequest{Tiers: []FeeTierRequest{{Above: 0, Rate: 0.01}, {Above: 0, Rate: 0.005}}}
+		}, "advisory fee tiers must have increasing thresholds"},
+		{"asset expense ratio too high", func(r *SimulationRequest) {
+			r.Portfolio = []AssetRequest{{Ticker: "T", Weight: 1.0, ExpenseRatio: 0.5}}
+		}, "asset expense ratios must be between 0 and 0.1"},
+		{"accounts with portfolio", func(r *SimulationRequest) {
+			r.Accounts = []AccountRequest{{Type: "roth", Balance: 100, Portfolio: validPortfolio}}
+		}, "portfolio and initial value must be omitted when accounts are given"},
+		{"account unknown type", func(r *SimulationRequest) {
+			r.Portfolio, r.InitialVal = nil, 0
+			r.Accounts = []AccountRequest{{Type: "hsa", Balance: 100, Portfolio: validPortfolio}}
+		}, "account type must be"},
+		{"account weights not 1", func(r *SimulationRequest) {
+			r.Portfolio, r.InitialVal = nil, 0
+			r.Accounts = []AccountRequest{{Name: "ira", Type: "roth", Balance: 100, Portfolio: []AssetRequest{{Ticker: "T", Weight: 0.5}}}}
+		}, "account ira: sum of portfolio weights must be approximately 1.0"},
+		{"accounts without balance", func(r *SimulationRequest) {
+			r.Portfolio, r.InitialVal = nil, 0
+			r.Accounts = []AccountRequest{{Type: "roth", Portfolio: validPortfolio}}
+		}, "account balances must add up to more than 0"},
+		{"invalid withdrawal order", func(r *SimulationRequest) { r.WithdrawalOrder = "random" }, "withdrawal order must be"},
+		{"tax rate of 1", func(r *SimulationRequest) { r.Tax = &TaxRequest{OrdinaryRate: 1} }, "tax rates must be at least 0 and below 1"},
+		{"rmd without accounts", func(r *SimulationRequest) { r.RMD = &RMDRequest{Age: 75} }, "rmd requires accounts"},
+		{"rmd without taxable account", func(r *SimulationRequest) {
+			r.Portfolio, r.InitialVal = nil, 0
+			r.Accounts = []AccountRequest{{Type: "tax-deferred", Balance: 100, Portfolio: validPortfolio}}
+			r.RMD = &RMDRequest{Age: 75}
+		}, "rmd requires a taxable account"},
+		{"rmd start age before table", func(r *SimulationRequest) {
+			r.Portfolio, r.InitialVal = nil, 0
+			r.Accounts = []AccountRequest{{Type: "taxable", Balance: 100, Portfolio: validPortfolio}}
+			r.RMD = &RMDRequest{Age: 75, StartAge: 70}
+		}, "rmd start age must be between 72 and 119"},
+		{"leverage ratio too high", func(r *SimulationRequest) { r.Leverage = &LeverageRequest{Ratio: 6} }, "leverage ratio must be between 1 and 5"},
+		{"leverage rate and cash rates", func(r *SimulationRequest) {
+			r.Leverage = &LeverageRequest{Ratio: 1.5, BorrowRate: 0.05, CashRates: []float64{0.03}}
+		}, "either a borrow rate or cash rates"},
+		{"leverage maintenance above equity share", func(r *SimulationRequest) {
+			r.Leverage = &LeverageRequest{Ratio: 2, MaintenanceRatio: 0.5}
+		}, "maintenance ratio must be above 0 and below 1/ratio"},
+		{"synthetic asset without parameters", func(r *SimulationRequest) {
+			r.Portfolio = []AssetRequest{{Ticker: "CASH", Weight: 1.0, Synthetic: &SyntheticRequest{}}}
+		}, "synthetic asset CASH: give either monthlyReturn or mean and volatility"},
+		{"synthetic asset correlated with unknown ticker", func(r *SimulationRequest) {
+			mean := 0.04
+			r.Portfolio = []AssetRequest{{Ticker: "BONDS", Weight: 1.0, Synthetic: &SyntheticRequest{
+				Mean: &mean, Volatility: 0.06, Correlations: map[string]float64{"VTI": 0.2},
+			}}}
+		}, "synthetic asset BONDS is correlated with VTI"},
+		{"assumptions without values", func(r *SimulationRequest) {
+			r.Portfolio = []AssetRequest{{Ticker: "T", Weight: 1.0, Assumptions: &AssumptionsRequest{}}}
+		}, "capital market assumptions need an expected return or volatility"},
+		{"unknown scenario", func(r *SimulationRequest) {
+			r.Scenarios = []ScenarioRequest{{Name: "alien-invasion"}}
+		}, "scenario name must be one of"},
+		{"unknown asset class", func(r *SimulationRequest) {
+			r.Portfolio = []AssetRequest{{Ticker: "T", Weight: 1.0, AssetClass: "gold"}}
+		}, "asset class must be 'equity', 'bond' or 'cash'"},
+		{"scenario bond returns too short", func(r *SimulationRequest) {
+			r.Scenarios = []ScenarioRequest{{Returns: []float64{-0.1, -0.1}, BondReturns: []float64{0.01}}}
+		}, "scenario bond and cash returns must be as many as its returns"},
+		{"scenario inflation without returns", func(r *SimulationRequest) {
+			zero := 0.0
+			r.Scenarios = []ScenarioRequest{{Name: "2008-crash", Inflation: &zero}}
+		}, "need custom returns"},
+		{"scenario after horizon", func(r *SimulationRequest) {
+			r.Scenarios = []ScenarioRequest{{Returns: []float64{-0.1}, Start: r.Periods}}
+		}, "scenario start must be between 0 and periods-1"},
+		{"scoring without floor", func(r *SimulationRequest) {
+			r.Scoring = &ScoringRequest{RiskAversion: 3}
+		}, "scoring needs a consumption floor above 0"},
+		{"backfill without proxies", func(r *SimulationRequest) {
+			r.Portfolio = []AssetRequest{{Ticker: "ETF", Weight: 1, Backfill: &BackfillRequest{}}}
+		}, "needs between 1 and 5 proxies"},
+		{"backfill with itself", func(r *SimulationRequest) {
+			r.Portfolio = []AssetRequest{{Ticker: "ETF", Weight: 1, Backfill: &BackfillRequest{Proxies: []string{"ETF"}}}}
+		}, "repeats ticker"},
+		{"malformed start date", func(r *SimulationRequest) { r.StartDate = "1990" }, "start date must be a month like 1990-01"},
+		{"end before start", func(r *SimulationRequest) {
+			r.StartDate, r.EndDate = "2020-01", "2010-01"
+		}, "end date must not be before start date"},
+		{"open exclusion", func(r *SimulationRequest) {
+			r.Exclude = []DateRangeRequest{{Start: "2020-01"}}
+		}, "excluded date ranges need a start and an end"},
+		{"unknown cache mode", func(r *SimulationRequest) { r.Cache = "never" }, "cache must be 'use', 'refresh' or 'bypass'"},
+		{"unknown alignment", func(r *SimulationRequest) { r.Alignment = "outer" }, "alignment must be 'intersect' or 'union'"},
 		{"negative withdrawal", func(r *SimulationRequest) { r.Withdrawal = -0.1 }, "withdrawal rate must be between 0 and 1"},
 		{"withdrawal too high", func(r *SimulationRequest) { r.Withdrawal = 1.1 }, "withdrawal rate must be between 0 and 1"},
 		{"negative inflation", func(r *SimulationRequest) { r.Inflation = -0.1 }, "inflation must be between 0 and 1"},
@@ -217,11 +1275,13 @@ func TestRunSimulation_ValidationErrors(t *testing.T) {
 			r.Portfolio = []AssetRequest{{Ticker: "T1", Weight: 0.5}, {Ticker: "T2", Weight: 0.6}}
 		}, "sum of portfolio weights must be approximately 1.0"},
 		{"invalid method", func(r *SimulationRequest) { r.Method = "unknown" }, "method must be 'normal' or 'bootstrap'"},
+		{"invalid sampler", func(r *SimulationRequest) { r.Sampler = "halton" }, "sampler must be 'pseudo' or 'sobol'"},
+		{"sobol with bootstrap", func(r *SimulationRequest) { r.Method, r.Sampler = "bootstrap", "sobol" }, "only supported with the 'normal' method"},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			handler := &Handler{Fetcher: fetcherForValidationTests}
+			handler := &Handler{Fetcher: data.AdaptReturns(fetcherForValidationTests, "mock")}
 			reqBody := baseRequest()
 			tc.modifier(&reqBody)
 
@@ -245,7 +1305,7 @@ func TestRunSimulation_NoReturnsFromFetcher(t *testing.T) {
 		returns: []float64{},
 		err:     nil,
 	}
-	handler := &Handler{Fetcher: mock}
+	handler := &Handler{Fetcher: data.AdaptReturns(mock, "mock")}
 
 	reqBody := SimulationRequest{
 		Portfolio:   []AssetRequest{{Ticker: "MOCK_NO_RETURNS", Weight: 1.0}},
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+)
+
+// RunSensitivity handles requests to vary the inputs of a simulation, for tornado charts.
+func (h *Handler) RunSensitivity(w http.ResponseWriter, r *http.Request) {
+	if h.Fetcher == nil {
+		log.Println("Error: Handler's PriceFetcher is not initialized.")
+		http.Error(w, "Internal server error: Fetcher service not available", http.StatusInternalServerError)
+		return
+	}
+
+	var req SensitivityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.sensitivity(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding or writing sensitivity response: %v", err)
+	}
+}
+
+// sensitivity runs the base simulation, then each input at its low and high value and
+// finally the grid. Returns are fetched once, as the inputs never change the tickers.
+func (h *Handler) sensitivity(ctx context.Context, req SensitivityRequest) (*SensitivityResponse, error) {
+	base := req.Base
+	// Adaptive runs stop after different numbers of paths, so every run uses a fixed count.
+	base.TargetStdError = 0
+	base.MaxSimulations = 0
+
+	p, _, _ := requestPortfolio(base)
+	window, _, _ := base.history() // Already checked by Validate.
+	seriesByAsset, _, err := h.fetchReturns(withCacheMode(ctx, base), p, window)
+	if err != nil {
+		return nil, err
+	}
+
+	// run simulates a request with the shared returns and summarises it. It stops once
+	// the client has gone, so an abandoned request does not keep simulating.
+	run := func(r SimulationRequest) (float64, float64, int64, error) {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, 0, err
+		}
+		if err := r.Validate(); err != nil {
+			return 0, 0, 0, &httpError{status: http.StatusBadRequest, message: err.Error()}
+		}
+		sim, err := newSimulationRun(r, seriesByAsset)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		result, err := sim.execute()
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return result.SuccessRate, result.FinalStats.Median, result.Seed, nil
+	}
+
+	baseSuccess, baseMedian, seed, err := run(base)
+	if err != nil {
+		return nil, err
+	}
+	// Every other run reuses the base run's seed: common random numbers.
+	base.Seed = seed
+	point := func(success, median float64) SensitivityPointResponse {
+		return SensitivityPointResponse{
+			SuccessRate:          success,
+			MedianTerminal:       median,
+			SuccessRateChange:    success - baseSuccess,
+			MedianTerminalChange: median - baseMedian,
+		}
+	}
+	// runAt simulates the base request with the inputs set to values.
+	runAt := func(inputs []SensitivityInput, values []float64) (SensitivityPointResponse, error) {
+		r, err := applyInputs(base, inputs, values)
+		if err != nil {
+			return SensitivityPointResponse{}, err
+		}
+		success, median, _, err := run(r)
+		if err != nil {
+			return SensitivityPointResponse{}, err
+		}
+		return point(success, median), nil
+	}
+
+	resp := &SensitivityResponse{Seed: seed, Baseline: point(baseSuccess, baseMedian)}
+	for _, in := range req.Inputs {
+		low, err := runAt([]SensitivityInput{in}, []float64{in.Low})
+		if err != nil {
+			return nil, err
+		}
+		high, err := runAt([]SensitivityInput{in}, []float64{in.High})
+		if err != nil {
+			return nil, err
+		}
+		resp.Inputs = append(resp.Inputs, InputSensitivityResponse{
+			Name:                in.Name,
+			Ticker:              in.Ticker,
+			LowValue:            in.Low,
+			HighValue:           in.High,
+			Low:                 low,
+			High:                high,
+			SuccessRateSwing:    math.Abs(high.SuccessRate - low.SuccessRate),
+			MedianTerminalSwing: math.Abs(high.MedianTerminal - low.MedianTerminal),
+		})
+	}
+	sort.SliceStable(resp.Inputs, func(i, j int) bool {
+		return resp.Inputs[i].SuccessRateSwing > resp.Inputs[j].SuccessRateSwing
+	})
+
+	if req.GridSteps > 0 {
+		for _, values := range gridValues(req.Inputs, req.GridSteps) {
+			pt, err := runAt(req.Inputs, values)
+			if err != nil {
+				return nil, err
+			}
+			labels := make(map[string]float64, len(values))
+			for i, in := range req.Inputs {
+				labels[in.label()] = values[i]
+			}
+			resp.Grid = append(resp.Grid, GridPointResponse{Values: labels, SensitivityPointResponse: pt})
+		}
+	}
+	return resp, nil
+}
+
+// gridValues returns every combination of steps evenly spaced values per input, from
+// low to high, with the last input varying fastest.
+func gridValues(inputs []SensitivityInput, steps int) [][]float64 {
+	combos := [][]float64{nil}
+	for _, in := range inputs {
+		var next [][]float64
+		for _, prefix := range combos {
+			for k := range steps {
+				v := in.Low + (in.High-in.Low)*float64(k)/float64(steps-1)
+				next = append(next, append(append([]float64(nil), prefix...), v))
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// applyInputs returns a copy of r with each input set to its value. Varied weights are
+// set together, so one does not rescale another; see setWeights.
+func applyInputs(r SimulationRequest, inputs []SensitivityInput, values []float64) (SimulationRequest, error) {
+	weights := make(map[string]float64)
+	for i, in := range inputs {
+		if in.Name == inputEquityWeight {
+			weights[in.Ticker] = values[i]
+			continue
+		}
+		var err error
+		if r, err = applyInput(r, in, values[i]); err != nil {
+			return r, err
+		}
+	}
+	if len(weights) == 0 {
+		return r, nil
+	}
+	return setWeights(r, weights)
+}
+
+// setWeights returns a copy of r with the given tickers at their weights. The assets
+// not being varied keep their proportions within what the varied ones leave over.
+func setWeights(r SimulationRequest, weights map[string]float64) (SimulationRequest, error) {
+	assets := append([]AssetRequest(nil), r.Portfolio...)
+	varied, rest := 0.0, 0.0
+	for _, a := range assets {
+		if w, ok := weights[a.Ticker]; ok {
+			varied += w
+		} else {
+			rest += a.Weight
+		}
+	}
+	if varied > 1+1e-9 {
+		return r, &httpError{status: http.StatusBadRequest, message: fmt.Sprintf("varied weights add up to %.4g, more than 1", varied)}
+	}
+	if rest <= 0 && varied < 1-1e-9 {
+		return r, &httpError{status: http.StatusBadRequest, message: "cannot vary the weights without another asset to take up the remainder"}
+	}
+	for j := range assets {
+		if w, ok := weights[assets[j].Ticker]; ok {
+			assets[j].Weight = w
+		} else if rest > 0 {
+			assets[j].Weight *= (1 - varied) / rest
+		}
+	}
+	r.Portfolio = assets
+	return r, nil
+}
+
+// applyInput returns a copy of r with the input, other than a weight, set to value. The
+// portfolio is copied before it is changed, so r itself is left as it was.
+func applyInput(r SimulationRequest, in SensitivityInput, value float64) (SimulationRequest, error) {
+	switch in.Name {
+	case inputWithdrawalRate:
+		r.Withdrawal = value
+	case inputInflation:
+		r.Inflation = value
+	case inputAdvisoryFee:
+		r.AdvisoryFee = &AdvisoryFeeRequest{Rate: value} // Validate rejects a tiered base fee.
+	case inputExpectedReturn:
+		assets := append([]AssetRequest(nil), r.Portfolio...)
+		i := slices.IndexFunc(assets, func(a AssetRequest) bool { return a.Ticker == in.Ticker })
+		a := &assets[i]
+		if s := a.Synthetic; s != nil {
+			if s.Mean == nil {
+				return r, &httpError{status: http.StatusBadRequest, message: fmt.Sprintf("cannot vary the expected return of %s, which has a constant return", in.Ticker)}
+			}
+			synthetic := *s
+			synthetic.Mean = &value
+			a.Synthetic = &synthetic
+		} else {
+			assumptions := AssumptionsRequest{}
+			if a.Assumptions != nil {
+				assumptions = *a.Assumptions
+			}
+			assumptions.ExpectedReturn = &value
+			a.Assumptions = &assumptions
+		}
+		r.Portfolio = assets
+	}
+	return r, nil
+}
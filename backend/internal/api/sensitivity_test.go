@@ -0,0 +1,188 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"portfolio-simulator/backend/internal/data"
+)
+
+// postSensitivity sends req to the sensitivity endpoint of a handler with mock returns.
+func postSensitivity(t *testing.T, req SensitivityRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	handler := &Handler{Fetcher: data.AdaptReturns(&mockFetcher{returns: []float64{0.03, -0.04, 0.02, -0.01, 0.015, 0.0}}, "mock")}
+	body, err := json.Marshal(req)
+	require.NoError(t, err)
+
+	httpReq := httptest.NewRequest(http.MethodPost, "/api/sensitivity", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.RunSensitivity(rr, httpReq)
+	return rr
+}
+
+func sensitivityBase() SimulationRequest {
+	return SimulationRequest{
+		Portfolio:   []AssetRequest{{Ticker: "STOCKS", Weight: 0.6}, {Ticker: "BONDS", Weight: 0.4}},
+		InitialVal:  1000000,
+		Withdrawal:  0.05,
+		Inflation:   0.02,
+		Periods:     20 * 12,
+		Simulations: 200,
+		Method:      "bootstrap",
+	}
+}
+
+func TestRunSensitivity_Tornado(t *testing.T) {
+	rr := postSensitivity(t, SensitivityRequest{
+		Base: sensitivityBase(),
+		Inputs: []SensitivityInput{
+			{Name: "inflation", Low: 0.01, High: 0.03},
+			{Name: "withdrawalRate", Low: 0.03, High: 0.08},
+			{Name: "equityWeight", Ticker: "STOCKS", Low: 0.4, High: 0.8},
+			{Name: "advisoryFee", Low: 0, High: 0.02},
+			{Name: "expectedReturn", Ticker: "STOCKS", Low: 0.02, High: 0.08},
+		},
+	})
+	require.Equal(t, http.StatusOK, rr.Code, "Body: %s", rr.Body.String())
+
+	var resp SensitivityResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.NotZero(t, resp.Seed)
+	require.Zero(t, resp.Baseline.SuccessRateChange)
+	require.Len(t, resp.Inputs, 5)
+	for i := 1; i < len(resp.Inputs); i++ {
+		require.GreaterOrEqual(t, resp.Inputs[i-1].SuccessRateSwing, resp.Inputs[i].SuccessRateSwing, "Inputs are ordered for a tornado chart")
+	}
+	for _, in := range resp.Inputs {
+		require.InDelta(t, in.Low.SuccessRate-resp.Baseline.SuccessRate, in.Low.SuccessRateChange, 1e-12)
+		switch in.Name {
+		case "withdrawalRate":
+			// Common random numbers make the direction of every change exact.
+			require.GreaterOrEqual(t, in.Low.SuccessRate, in.High.SuccessRate)
+			require.Greater(t, in.Low.MedianTerminal, in.High.MedianTerminal)
+		case "expectedReturn":
+			require.Greater(t, in.High.MedianTerminal, in.Low.MedianTerminal)
+		}
+	}
+}
+
+func TestRunSensitivity_Grid(t *testing.T) {
+	rr := postSensitivity(t, SensitivityRequest{
+		Base: sensitivityBase(),
+		Inputs: []SensitivityInput{
+			{Name: "withdrawalRate", Low: 0.03, High: 0.05},
+			{Name: "inflation", Low: 0.0, High: 0.04},
+		},
+		GridSteps: 3,
+	})
+	require.Equal(t, http.StatusOK, rr.Code, "Body: %s", rr.Body.String())
+
+	var resp SensitivityResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Grid, 9)
+	require.Equal(t, map[string]float64{"withdrawalRate": 0.03, "inflation": 0.04}, resp.Grid[2].Values)
+	// The grid point matching the base request reproduces the base run.
+	require.Equal(t, map[string]float64{"withdrawalRate": 0.05, "inflation": 0.02}, resp.Grid[7].Values)
+	require.InDelta(t, resp.Baseline.MedianTerminal, resp.Grid[7].MedianTerminal, 1e-6)
+}
+
+func TestRunSensitivity_ValidationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     SensitivityRequest
+		message string
+	}{
+		{"no inputs", SensitivityRequest{Base: sensitivityBase()}, "sensitivity needs between 1 and 8 inputs"},
+		{"unknown input", SensitivityRequest{Base: sensitivityBase(), Inputs: []SensitivityInput{{Name: "luck", Low: 0, High: 1}}}, "sensitivity input name must be"},
+		{"ticker not in portfolio", SensitivityRequest{Base: sensitivityBase(), Inputs: []SensitivityInput{{Name: "equityWeight", Ticker: "GOLD", Low: 0.1, High: 0.2}}}, "needs a ticker from the portfolio"},
+		{"low above high", SensitivityRequest{Base: sensitivityBase(), Inputs: []SensitivityInput{{Name: "inflation", Low: 0.03, High: 0.01}}}, "must have low below high"},
+		{"grid too large", SensitivityRequest{Base: sensitivityBase(), Inputs: []SensitivityInput{
+			{Name: "inflation", Low: 0, High: 0.04},
+			{Name: "withdrawalRate", Low: 0.03, High: 0.05},
+			{Name: "advisoryFee", Low: 0, High: 0.01},
+		}, GridSteps: 6}, "grid steps ^ inputs must be at most 125 points"},
+		{"varied value out of range", SensitivityRequest{Base: sensitivityBase(), Inputs: []SensitivityInput{{Name: "withdrawalRate", Low: 0.04, High: 1.5}}}, "withdrawal rate must be between 0 and 1"},
+		{"grid weights above 1", SensitivityRequest{Base: sensitivityBase(), Inputs: []SensitivityInput{
+			{Name: "equityWeight", Ticker: "STOCKS", Low: 0.4, High: 0.7},
+			{Name: "equityWeight", Ticker: "BONDS", Low: 0.2, High: 0.4},
+		}, GridSteps: 2}, "highs adding up to at most 1"},
+		{"weight with a glide path", SensitivityRequest{Base: func() SimulationRequest {
+			b := sensitivityBase()
+			b.GlidePath = &GlidePathRequest{Points: []GlidePointRequest{{Period: 0, Weights: map[string]float64{"STOCKS": 0.6, "BONDS": 0.4}}}}
+			return b
+		}(), Inputs: []SensitivityInput{{Name: "equityWeight", Ticker: "STOCKS", Low: 0.4, High: 0.8}}}, "cannot be varied with a glide path"},
+		{"tiered base fee", SensitivityRequest{Base: func() SimulationRequest {
+			b := sensitivityBase()
+			b.AdvisoryFee = &AdvisoryFeeRequest{Tiers: []FeeTierRequest{{Rate: 0.01}, {Above: 500000, Rate: 0.005}}}
+			return b
+		}(), Inputs: []SensitivityInput{{Name: "advisoryFee", Low: 0, High: 0.02}}}, "base advisory fee must not be tiered"},
+		{"too many paths", SensitivityRequest{Base: func() SimulationRequest {
+			b := sensitivityBase()
+			b.Simulations = 10000
+			return b
+		}(), Inputs: []SensitivityInput{
+			{Name: "withdrawalRate", Low: 0.03, High: 0.05},
+			{Name: "inflation", Low: 0.0, High: 0.04},
+			{Name: "advisoryFee", Low: 0, High: 0.01},
+			{Name: "expectedReturn", Ticker: "STOCKS", Low: 0.02, High: 0.08},
+		}, GridSteps: 3}, "must be at most 500000 paths"},
+		{"invalid base", SensitivityRequest{Base: SimulationRequest{}, Inputs: []SensitivityInput{{Name: "inflation", Low: 0, High: 0.1}}}, "base: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := postSensitivity(t, tt.req)
+			require.Equal(t, http.StatusBadRequest, rr.Code)
+			require.Contains(t, rr.Body.String(), tt.message)
+		})
+	}
+}
+
+func TestSetWeights_VariesWeightsTogether(t *testing.T) {
+	base := sensitivityBase()
+	base.Portfolio = []AssetRequest{{Ticker: "A", Weight: 0.3}, {Ticker: "B", Weight: 0.3}, {Ticker: "C", Weight: 0.4}}
+	inputs := []SensitivityInput{{Name: "equityWeight", Ticker: "A"}, {Name: "equityWeight", Ticker: "B"}}
+
+	r, err := applyInputs(base, inputs, []float64{0.2, 0.5})
+	require.NoError(t, err)
+	weights := make(map[string]float64)
+	for _, a := range r.Portfolio {
+		weights[a.Ticker] = a.Weight
+	}
+	require.InDelta(t, 0.2, weights["A"], 1e-12, "Setting B does not rescale A")
+	require.InDelta(t, 0.5, weights["B"], 1e-12)
+	require.InDelta(t, 0.3, weights["C"], 1e-12, "Only the assets not varied take up the remainder")
+	require.Equal(t, 0.3, base.Portfolio[0].Weight, "The base request is left as it was")
+
+	_, err = applyInputs(base, inputs, []float64{0.6, 0.5})
+	require.ErrorContains(t, err, "more than 1")
+}
+
+// cancelAfterFetch cancels the request's context once the prices have been fetched, as
+// if the client disconnected while the simulations run.
+type cancelAfterFetch struct {
+	data.PriceFetcher
+	cancel context.CancelFunc
+}
+
+func (f *cancelAfterFetch) FetchPrices(ctx context.Context, ticker string, opts data.FetchOptions) (data.PriceSeries, error) {
+	defer f.cancel()
+	return f.PriceFetcher.FetchPrices(ctx, ticker, opts)
+}
+
+func TestSensitivity_StopsWhenClientGoes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fetcher := &cancelAfterFetch{PriceFetcher: data.AdaptReturns(&mockFetcher{returns: []float64{0.03, -0.04, 0.02}}, "mock"), cancel: cancel}
+	handler := &Handler{Fetcher: fetcher}
+
+	req := SensitivityRequest{Base: sensitivityBase(), Inputs: []SensitivityInput{{Name: "inflation", Low: 0.01, High: 0.03}}}
+	req.Base.Portfolio = []AssetRequest{{Ticker: "STOCKS", Weight: 1}}
+	_, err := handler.sensitivity(ctx, req)
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -2,14 +2,69 @@ package api
 
 import (
 	"errors"
+	"fmt"
 	"math"
+	"slices"
 	"strings"
+	"time"
+
+	"portfolio-simulator/backend/internal/data"
+	"portfolio-simulator/backend/internal/data/cache"
+	"portfolio-simulator/backend/internal/mortality"
+	"portfolio-simulator/backend/internal/portfolio"
+	"portfolio-simulator/backend/internal/simulation"
 )
 
 // AssetRequest defines a single asset within a portfolio, including its ticker and weight.
 type AssetRequest struct {
-	Ticker string  `json:"ticker"` // Asset identifier (e.g. AAPL, SPY, BTCUSD)
-	Weight float64 `json:"weight"` // Portfolio weight (e.g. 0.25 for 25%)
+	Ticker       string            `json:"ticker"`              // Asset identifier (e.g. AAPL, SPY, BTCUSD); a label for synthetic assets
+	Weight       float64           `json:"weight"`              // Portfolio weight (e.g. 0.25 for 25%)
+	ExpenseRatio float64           `json:"expenseRatio"`        // Optional annual expense ratio (e.g. 0.0003 for 0.03%)
+	AssetClass   string            `json:"assetClass"`          // Optional: "equity", "bond" or "cash", for stress scenarios
+	Synthetic    *SyntheticRequest `json:"synthetic,omitempty"` // Optional: generate the returns instead of fetching the ticker
+
+	Assumptions *AssumptionsRequest `json:"assumptions,omitempty"` // Optional: capital market assumptions for a fetched ticker
+	Backfill    *BackfillRequest    `json:"backfill,omitempty"`    // Optional: proxies spliced in before the ticker's inception
+}
+
+// assetClass returns the asset's class for stress scenarios. Unless one is given,
+// synthetic assets with a constant return are cash and everything else equity.
+func (a AssetRequest) assetClass() string {
+	if a.AssetClass != "" {
+		return strings.ToLower(a.AssetClass)
+	}
+	if a.Synthetic != nil && a.Synthetic.MonthlyReturn != nil {
+		return string(simulation.ClassCash)
+	}
+	return string(simulation.ClassEquity)
+}
+
+// maxProxies caps the chain of proxies behind a single asset.
+const maxProxies = 5
+
+// BackfillRequest extends a fetched ticker's history with proxy tickers. Each proxy, in
+// order, fills the months before the history built so far.
+type BackfillRequest struct {
+	Proxies         []string `json:"proxies"`         // Proxy tickers (e.g. ["VFIAX", "^GSPC"])
+	ScaleVolatility bool     `json:"scaleVolatility"` // Optional: rescale each proxy to the history's volatility over their overlap
+}
+
+// AssumptionsRequest overrides an asset's expected annual return and volatility. The
+// historical returns are shifted and rescaled to match, keeping their shape and their
+// correlations with the other assets.
+type AssumptionsRequest struct {
+	ExpectedReturn *float64 `json:"expectedReturn,omitempty"` // Expected annual return (e.g. 0.05); omitted keeps the historical mean
+	Volatility     *float64 `json:"volatility,omitempty"`     // Annual volatility (e.g. 0.16); omitted keeps the historical volatility
+}
+
+// SyntheticRequest describes an asset without price history, such as cash or a TIPS
+// ladder. It gives either a constant monthly return, or an annual mean and volatility
+// with optional correlations to other tickers in the portfolio.
+type SyntheticRequest struct {
+	MonthlyReturn *float64           `json:"monthlyReturn,omitempty"` // Constant monthly return (e.g. 0.0025)
+	Mean          *float64           `json:"mean,omitempty"`          // Expected annual return (e.g. 0.04)
+	Volatility    float64            `json:"volatility"`              // Annual volatility (e.g. 0.06)
+	Correlations  map[string]float64 `json:"correlations,omitempty"`  // Correlation with other tickers; omitted ones are uncorrelated
 }
 
 type SimulationRequest struct {
@@ -20,11 +75,330 @@ type SimulationRequest struct {
 	Simulations int            `json:"simulations"`    // Number of simulation paths
 	Periods     int            `json:"periods"`        // Number of periods (e.g. months)
 	Method      string         `json:"method"`         // "normal" or "bootstrap"
+	Sampler     string         `json:"sampler"`        // Optional: "pseudo" (default) or "sobol"; "sobol" requires "normal"
+
+	TargetStdError float64 `json:"targetStdError"` // Optional: run batches of Simulations until the success-rate standard error is below this
+	MaxSimulations int     `json:"maxSimulations"` // Optional cap on total paths for adaptive runs (default 10000)
+
+	Lifespan *LifespanRequest `json:"lifespan,omitempty"` // Optional: draw a lifespan per path and measure success until death
+	Annuity  *AnnuityRequest  `json:"annuity,omitempty"`  // Optional: buy an immediate annuity and compare against not buying it
+	Bucket   *BucketRequest   `json:"bucket,omitempty"`   // Optional: cash-bucket strategy, compared against plain withdrawals
+	Seed     int64            `json:"seed"`               // Optional: fixes the random draws so runs can be reproduced
+
+	GlidePath *GlidePathRequest `json:"glidePath,omitempty"` // Optional: time-varying allocation; portfolio weights apply at period 0
+
+	AdvisoryFee *AdvisoryFeeRequest `json:"advisoryFee,omitempty"` // Optional: advisory fee on assets under management, deducted monthly
+	Leverage    *LeverageRequest    `json:"leverage,omitempty"`    // Optional: borrow against the portfolio, compared against no leverage
+
+	Accounts        []AccountRequest `json:"accounts,omitempty"` // Optional: tax-treated accounts; replaces portfolio and initialValue
+	WithdrawalOrder string           `json:"withdrawalOrder"`    // "conventional" (default), "deferred-first" or "proportional"
+	Tax             *TaxRequest      `json:"tax,omitempty"`      // Tax rates applied to account withdrawals
+	RMD             *RMDRequest      `json:"rmd,omitempty"`      // Optional: required minimum distributions from tax-deferred accounts
+
+	Scenarios []ScenarioRequest `json:"scenarios,omitempty"` // Optional: stress scenarios reported next to the baseline run
+	Scoring   *ScoringRequest   `json:"scoring,omitempty"`   // Optional: utility and shortfall scores of consumption
+
+	Alignment string `json:"alignment"` // Optional: "intersect" (default) keeps months every asset has; "union" fills gaps with each asset's mean
+
+	StartDate string             `json:"startDate"`         // Optional first month of history to estimate returns from, e.g. "1990-01"
+	EndDate   string             `json:"endDate"`           // Optional last month of history, e.g. "2020-12"
+	Exclude   []DateRangeRequest `json:"exclude,omitempty"` // Optional months of history to leave out, e.g. 2020
+
+	Cache string `json:"cache"` // Optional: "use" (default) serves cached prices, "refresh" refetches them, "bypass" skips the cache
+}
+
+// maxExclusions caps the date ranges a request can leave out of the history.
+const maxExclusions = 20
+
+// DateRangeRequest is a range of months, inclusive at both ends, in "YYYY-MM" form.
+type DateRangeRequest struct {
+	Start string `json:"start"` // e.g. "2020-01"
+	End   string `json:"end"`   // e.g. "2020-12"
+}
+
+// parseMonth parses a "YYYY-MM" month; an empty string gives the zero time.
+func parseMonth(field, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	month, err := time.Parse("2006-01", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be a month like 1990-01", field)
+	}
+	return month, nil
+}
+
+// history returns the window of history to fetch and the windows to leave out of it.
+func (r *SimulationRequest) history() (data.Window, []data.Window, error) {
+	var window data.Window
+	var err error
+	if window.Start, err = parseMonth("start date", r.StartDate); err != nil {
+		return window, nil, err
+	}
+	if window.End, err = parseMonth("end date", r.EndDate); err != nil {
+		return window, nil, err
+	}
+	if !window.Start.IsZero() && !window.End.IsZero() && window.End.Before(window.Start) {
+		return window, nil, errors.New("end date must not be before start date")
+	}
+	if len(r.Exclude) > maxExclusions {
+		return window, nil, fmt.Errorf("at most %d date ranges can be excluded", maxExclusions)
+	}
+	excluded := make([]data.Window, len(r.Exclude))
+	for i, e := range r.Exclude {
+		if e.Start == "" || e.End == "" {
+			return window, nil, errors.New("excluded date ranges need a start and an end")
+		}
+		if excluded[i].Start, err = parseMonth("excluded start", e.Start); err != nil {
+			return window, nil, err
+		}
+		if excluded[i].End, err = parseMonth("excluded end", e.End); err != nil {
+			return window, nil, err
+		}
+		if excluded[i].End.Before(excluded[i].Start) {
+			return window, nil, errors.New("excluded end must not be before its start")
+		}
+	}
+	return window, excluded, nil
+}
+
+// ScoringRequest configures the outcome scores. Consumption is measured as a fraction
+// of planned spending, so a fully funded path consumes 1 every month.
+type ScoringRequest struct {
+	RiskAversion     float64 `json:"riskAversion"`     // CRRA relative risk aversion (0 = risk neutral, 1 = log utility, e.g. 3)
+	ConsumptionFloor float64 `json:"consumptionFloor"` // Fraction of planned spending always consumed (e.g. 0.2 from a pension); needed from riskAversion 1
+}
+
+// maxScenarios caps the stress scenarios per request, as each one is a full rerun.
+const maxScenarios = 10
+
+// ScenarioRequest is a stress spliced into every path from period Start. It either names
+// a built-in scenario or gives its own monthly returns.
+type ScenarioRequest struct {
+	Name        string    `json:"name"`        // Built-in scenario (e.g. "2008-crash"), or a label for custom returns
+	Start       int       `json:"start"`       // Period after which the stress begins; 0 means the first month
+	Returns     []float64 `json:"returns"`     // Optional custom monthly equity returns (e.g. [-0.2, -0.1, 0.05])
+	BondReturns []float64 `json:"bondReturns"` // Optional monthly bond returns, as many as returns; bonds are held flat without them
+	CashReturns []float64 `json:"cashReturns"` // Optional monthly cash returns, as many as returns; cash is held flat without them
+	Inflation   *float64  `json:"inflation"`   // Optional annual inflation during custom returns; unset keeps the request's inflation
+}
+
+// Scenario resolves the scenario described by the request.
+func (s *ScenarioRequest) Scenario() (simulation.Scenario, error) {
+	if len(s.Returns) == 0 {
+		return simulation.LibraryScenario(strings.ToLower(s.Name), s.Start)
+	}
+	name := s.Name
+	if name == "" {
+		name = "custom"
+	}
+	return simulation.Scenario{
+		Name:      name,
+		Start:     s.Start,
+		Returns:   s.Returns,
+		Bonds:     s.BondReturns,
+		Cash:      s.CashReturns,
+		Inflation: s.Inflation,
+	}, nil
+}
+
+// defaultRMDStartAge is the age at which required minimum distributions begin when none is given.
+const defaultRMDStartAge = 73
+
+// RMDRequest configures required minimum distributions. The IRS Uniform Lifetime Table
+// is used unless a custom divisor table is given (as JSON rows or CSV text).
+type RMDRequest struct {
+	Age         int              `json:"age"`         // Owner's age at the start of the simulation
+	StartAge    int              `json:"startAge"`    // Age from which distributions are required (default 73)
+	Divisors    []DivisorRequest `json:"divisors"`    // Optional custom divisor table rows
+	DivisorsCSV string           `json:"divisorsCsv"` // Optional custom divisor table as CSV with "age" and "divisor" columns
+}
+
+// DivisorRequest is one row of a custom RMD divisor table.
+type DivisorRequest struct {
+	Age     int     `json:"age"`     // Integer age in years
+	Divisor float64 `json:"divisor"` // Distribution period in years (e.g. 26.5)
+}
+
+// DivisorTable resolves the divisor table described by the request.
+func (r *RMDRequest) DivisorTable() (*mortality.DivisorTable, error) {
+	switch {
+	case len(r.Divisors) > 0 && r.DivisorsCSV != "":
+		return nil, errors.New("rmd: provide either divisors or divisorsCsv, not both")
+	case len(r.Divisors) > 0:
+		divisors := make([]mortality.Divisor, len(r.Divisors))
+		for i, d := range r.Divisors {
+			divisors[i] = mortality.Divisor(d)
+		}
+		return mortality.NewDivisorTable(divisors)
+	case r.DivisorsCSV != "":
+		return mortality.ParseDivisorCSV(strings.NewReader(r.DivisorsCSV))
+	default:
+		return mortality.UniformLifetime(), nil
+	}
+}
+
+// startAge returns the requested start age or the default.
+func (r *RMDRequest) startAge() int {
+	if r.StartAge == 0 {
+		return defaultRMDStartAge
+	}
+	return r.StartAge
+}
+
+// AccountRequest is one account with its own allocation and tax treatment.
+type AccountRequest struct {
+	Name      string         `json:"name"`      // Label echoed in the response (e.g. "401k")
+	Type      string         `json:"type"`      // "taxable", "tax-deferred" or "roth"
+	Balance   float64        `json:"balance"`   // Starting balance
+	CostBasis *float64       `json:"costBasis"` // Optional cost basis of a taxable account; defaults to the balance
+	Portfolio []AssetRequest `json:"portfolio"` // Allocation within the account
+}
+
+// TaxRequest holds the flat tax rates of the simple tax model.
+type TaxRequest struct {
+	OrdinaryRate     float64 `json:"ordinaryRate"`     // Rate on tax-deferred withdrawals (e.g. 0.22)
+	CapitalGainsRate float64 `json:"capitalGainsRate"` // Rate on realised gains in taxable accounts (e.g. 0.15)
+}
+
+// defaultMaintenanceRatio is the minimum equity share of leveraged assets when none is given.
+const defaultMaintenanceRatio = 0.25
+
+// LeverageRequest describes borrowing against the portfolio. The borrowing rate is either
+// fixed or a spread over a series of cash rates.
+type LeverageRequest struct {
+	Ratio            float64   `json:"ratio"`            // Assets per unit of equity (e.g. 1.5)
+	BorrowRate       float64   `json:"borrowRate"`       // Fixed annual borrowing rate (e.g. 0.06)
+	CashRates        []float64 `json:"cashRates"`        // Optional annual cash rate per month of the simulation; the last one holds afterwards
+	Spread           float64   `json:"spread"`           // Annual spread over cashRates (e.g. 0.015)
+	MaintenanceRatio float64   `json:"maintenanceRatio"` // Minimum equity as a share of assets before a margin call (default 0.25)
+}
+
+// maintenanceRatio returns the requested maintenance ratio or the default.
+func (l *LeverageRequest) maintenanceRatio() float64 {
+	if l.MaintenanceRatio == 0 {
+		return defaultMaintenanceRatio
+	}
+	return l.MaintenanceRatio
+}
+
+// AdvisoryFeeRequest is either a flat annual rate on assets under management or a tiered schedule.
+type AdvisoryFeeRequest struct {
+	Rate  float64          `json:"rate"`  // Flat annual fee (e.g. 0.01 for 1%)
+	Tiers []FeeTierRequest `json:"tiers"` // Tiered schedule; each rate applies to the balance within its tier
+}
+
+// FeeTierRequest is one band of a tiered advisory fee schedule.
+type FeeTierRequest struct {
+	Above float64 `json:"above"` // Balance at which the tier starts; the first tier starts at 0
+	Rate  float64 `json:"rate"`  // Annual fee on the balance within the tier (e.g. 0.0075)
+}
+
+// GlidePathRequest describes how the allocation changes over the horizon.
+type GlidePathRequest struct {
+	Interpolation string              `json:"interpolation"` // "linear" (default) or "step"
+	Points        []GlidePointRequest `json:"points"`        // Breakpoints in increasing period order
+}
+
+// GlidePointRequest fixes the allocation at a given period.
+type GlidePointRequest struct {
+	Period  int                `json:"period"`  // Month at which the allocation applies
+	Weights map[string]float64 `json:"weights"` // Weight per portfolio ticker; tickers left out get 0
+}
+
+// BucketRequest describes a cash reserve that funds withdrawals and is refilled from the portfolio.
+type BucketRequest struct {
+	Years  float64 `json:"years"`  // Years of withdrawals held in cash (e.g. 2)
+	Yield  float64 `json:"yield"`  // Annual yield on cash (e.g. 0.03)
+	Refill string  `json:"refill"` // "monthly", "annual" or "up-years" (default): refill only after a year with gains
+}
+
+// AnnuityRequest describes a single-premium immediate annuity bought during the simulation.
+// Exactly one of Fraction or Amount must be set.
+type AnnuityRequest struct {
+	Period     int     `json:"period"`     // Month at whose end the annuity is bought (0 = at the start)
+	Fraction   float64 `json:"fraction"`   // Share of the portfolio used as the premium (e.g. 0.3 for 30%)
+	Amount     float64 `json:"amount"`     // Fixed premium, capped at the portfolio value
+	PayoutRate float64 `json:"payoutRate"` // Annual payout as a share of the premium (e.g. 0.065)
+	COLA       float64 `json:"cola"`       // Optional annual cost-of-living adjustment of the payments (e.g. 0.02)
+}
+
+// validate checks the glide path against the portfolio tickers and the horizon.
+func (g *GlidePathRequest) validate(portfolio []AssetRequest, periods int) error {
+	if len(g.Points) == 0 {
+		return errors.New("glide path must have at least one point")
+	}
+	if interp := strings.ToLower(g.Interpolation); interp != "" && interp != "linear" && interp != "step" {
+		return errors.New("glide path interpolation must be 'linear' or 'step'")
+	}
+	tickers := make(map[string]bool, len(portfolio))
+	for _, a := range portfolio {
+		tickers[a.Ticker] = true
+	}
+	for i, p := range g.Points {
+		if p.Period < 0 || p.Period > periods {
+			return errors.New("glide path periods must be between 0 and periods")
+		}
+		if i > 0 && p.Period <= g.Points[i-1].Period {
+			return errors.New("glide path periods must be strictly increasing")
+		}
+		total := 0.0
+		for ticker, w := range p.Weights {
+			if !tickers[ticker] {
+				return fmt.Errorf("glide path ticker %s is not in the portfolio", ticker)
+			}
+			if w < 0 {
+				return errors.New("glide path weights cannot be negative")
+			}
+			total += w
+		}
+		if math.Abs(total-1.0) > 0.01 {
+			return fmt.Errorf("glide path weights at period %d must sum to approximately 1.0", p.Period)
+		}
+	}
+	return nil
+}
+
+// MortalityRateRequest is one row of a custom life table.
+type MortalityRateRequest struct {
+	Age int     `json:"age"` // Integer age in years
+	Qx  float64 `json:"qx"`  // Probability of dying within the year (e.g. 0.012)
+}
+
+// LifespanRequest configures stochastic lifespans. Either the built-in table for Sex
+// or a custom survival table (as JSON rows or CSV text) is used.
+type LifespanRequest struct {
+	Age      int                    `json:"age"`      // Age at the start of the simulation
+	Sex      string                 `json:"sex"`      // "male" or "female"; ignored when a custom table is given
+	Table    []MortalityRateRequest `json:"table"`    // Optional custom table rows
+	TableCSV string                 `json:"tableCsv"` // Optional custom table as CSV with "age" and "qx" columns
+}
+
+// MortalityTable resolves the life table described by the request.
+func (l *LifespanRequest) MortalityTable() (*mortality.Table, error) {
+	switch {
+	case len(l.Table) > 0 && l.TableCSV != "":
+		return nil, errors.New("lifespan: provide either table or tableCsv, not both")
+	case len(l.Table) > 0:
+		rates := make([]mortality.Rate, len(l.Table))
+		for i, r := range l.Table {
+			rates[i] = mortality.Rate(r)
+		}
+		return mortality.NewTable(rates)
+	case l.TableCSV != "":
+		return mortality.ParseCSV(strings.NewReader(l.TableCSV))
+	default:
+		return mortality.Builtin(l.Sex)
+	}
 }
 
 // Validate checks the SimulationRequest for correctness and completeness.
 func (r *SimulationRequest) Validate() error {
-	if r.InitialVal <= 0 {
+	if len(r.Accounts) > 0 {
+		if err := r.validateAccounts(); err != nil {
+			return err
+		}
+	} else if r.InitialVal <= 0 {
 		return errors.New("initial value must be greater than 0")
 	}
 	if r.Periods <= 0 || r.Periods > 1200 {
@@ -33,6 +407,12 @@ func (r *SimulationRequest) Validate() error {
 	if r.Simulations <= 0 || r.Simulations > 10000 {
 		return errors.New("simulations must be between 1 and 10000")
 	}
+	if r.TargetStdError < 0 || r.TargetStdError >= 0.5 {
+		return errors.New("target standard error must be between 0 and 0.5")
+	}
+	if r.MaxSimulations != 0 && (r.MaxSimulations < r.Simulations || r.MaxSimulations > 10000) {
+		return errors.New("max simulations must be between simulations and 10000")
+	}
 	if r.Withdrawal < 0 || r.Withdrawal > 1 {
 		return errors.New("withdrawal rate must be between 0 and 1")
 	}
@@ -40,12 +420,189 @@ func (r *SimulationRequest) Validate() error {
 		return errors.New("inflation must be between 0 and 1")
 	}
 
-	if len(r.Portfolio) == 0 {
-		return errors.New("portfolio must be provided and cannot be empty")
+	if r.Lifespan != nil {
+		if r.Lifespan.Age < 0 || r.Lifespan.Age >= mortality.MaxAge {
+			return fmt.Errorf("lifespan age must be between 0 and %d", mortality.MaxAge-1)
+		}
+		table, err := r.Lifespan.MortalityTable()
+		if err != nil {
+			return err
+		}
+		if !table.Covers(r.Lifespan.Age) {
+			return errors.New("lifespan table must include the starting age")
+		}
+	}
+
+	if a := r.Annuity; a != nil {
+		if a.Period < 0 || a.Period >= r.Periods {
+			return errors.New("annuity period must be between 0 and periods-1")
+		}
+		if (a.Fraction > 0) == (a.Amount > 0) || a.Fraction < 0 || a.Amount < 0 {
+			return errors.New("annuity must specify exactly one of a positive fraction or amount")
+		}
+		if a.Fraction > 1 {
+			return errors.New("annuity fraction cannot exceed 1.0 (100%)")
+		}
+		if a.PayoutRate <= 0 || a.PayoutRate > 1 {
+			return errors.New("annuity payout rate must be greater than 0 and at most 1")
+		}
+		if a.COLA < 0 || a.COLA > 1 {
+			return errors.New("annuity cola must be between 0 and 1")
+		}
+	}
+
+	if b := r.Bucket; b != nil {
+		if r.Withdrawal <= 0 {
+			return errors.New("bucket strategy requires a withdrawal rate greater than 0")
+		}
+		if b.Years <= 0 || b.Years > 10 {
+			return errors.New("bucket years must be greater than 0 and at most 10")
+		}
+		if b.Yield < 0 || b.Yield > 1 {
+			return errors.New("bucket yield must be between 0 and 1")
+		}
+		switch strings.ToLower(b.Refill) {
+		case "", "monthly", "annual", "up-years":
+		default:
+			return errors.New("bucket refill must be 'monthly', 'annual' or 'up-years'")
+		}
+	}
+
+	if f := r.AdvisoryFee; f != nil {
+		if f.Rate < 0 || f.Rate > 0.1 {
+			return errors.New("advisory fee rate must be between 0 and 0.1")
+		}
+		if f.Rate > 0 && len(f.Tiers) > 0 {
+			return errors.New("advisory fee must specify either a rate or tiers, not both")
+		}
+		for i, tier := range f.Tiers {
+			if i == 0 && tier.Above != 0 {
+				return errors.New("the first advisory fee tier must start at 0")
+			}
+			if i > 0 && tier.Above <= f.Tiers[i-1].Above {
+				return errors.New("advisory fee tiers must have increasing thresholds")
+			}
+			if tier.Rate < 0 || tier.Rate > 0.1 {
+				return errors.New("advisory fee tier rates must be between 0 and 0.1")
+			}
+		}
+	}
+
+	if l := r.Leverage; l != nil {
+		if l.Ratio < 1 || l.Ratio > 5 {
+			return errors.New("leverage ratio must be between 1 and 5")
+		}
+		if l.BorrowRate < 0 || l.BorrowRate > 1 || l.Spread < 0 || l.Spread > 1 {
+			return errors.New("leverage borrow rate and spread must be between 0 and 1")
+		}
+		if l.BorrowRate > 0 && len(l.CashRates) > 0 {
+			return errors.New("leverage must specify either a borrow rate or cash rates, not both")
+		}
+		for _, rate := range l.CashRates {
+			if rate < -0.1 || rate > 1 {
+				return errors.New("leverage cash rates must be between -0.1 and 1")
+			}
+		}
+		if m := l.maintenanceRatio(); m <= 0 || m >= 1/l.Ratio {
+			return errors.New("leverage maintenance ratio must be above 0 and below 1/ratio")
+		}
+	}
+
+	if len(r.Accounts) == 0 {
+		if len(r.Portfolio) == 0 {
+			return errors.New("portfolio must be provided and cannot be empty")
+		}
+
+		if g := r.GlidePath; g != nil {
+			if err := g.validate(r.Portfolio, r.Periods); err != nil {
+				return err
+			}
+		}
+
+		if err := validatePortfolio(r.Portfolio); err != nil {
+			return err
+		}
+		if err := validateCorrelations(r.Portfolio); err != nil {
+			return err
+		}
+	} else {
+		var assets []AssetRequest
+		for _, a := range r.Accounts {
+			assets = append(assets, a.Portfolio...)
+		}
+		if err := validateCorrelations(assets); err != nil {
+			return err
+		}
+	}
+
+	switch strings.ToLower(r.WithdrawalOrder) {
+	case "", "conventional", "deferred-first", "proportional":
+	default:
+		return errors.New("withdrawal order must be 'conventional', 'deferred-first' or 'proportional'")
+	}
+	if t := r.Tax; t != nil {
+		if t.OrdinaryRate < 0 || t.OrdinaryRate >= 1 || t.CapitalGainsRate < 0 || t.CapitalGainsRate >= 1 {
+			return errors.New("tax rates must be at least 0 and below 1")
+		}
+	}
+	if r.RMD != nil {
+		if err := r.validateRMD(); err != nil {
+			return err
+		}
+	}
+	if err := r.validateScenarios(); err != nil {
+		return err
+	}
+	if s := r.Scoring; s != nil {
+		if r.Withdrawal <= 0 {
+			return errors.New("scoring requires a withdrawal rate above 0")
+		}
+		if s.RiskAversion < 0 || s.RiskAversion > 20 {
+			return errors.New("scoring risk aversion must be between 0 and 20")
+		}
+		if s.ConsumptionFloor < 0 || s.ConsumptionFloor > 1 {
+			return errors.New("scoring consumption floor must be between 0 and 1")
+		}
+		if s.RiskAversion >= 1 && s.ConsumptionFloor == 0 {
+			return errors.New("scoring needs a consumption floor above 0 when risk aversion is 1 or more")
+		}
+	}
+
+	if method := strings.ToLower(r.Method); method != "normal" && method != "bootstrap" {
+		return errors.New("method must be 'normal' or 'bootstrap'")
 	}
 
+	switch strings.ToLower(r.Sampler) {
+	case "", "pseudo":
+	case "sobol":
+		if strings.ToLower(r.Method) != "normal" {
+			return errors.New("sampler 'sobol' is only supported with the 'normal' method")
+		}
+	default:
+		return errors.New("sampler must be 'pseudo' or 'sobol'")
+	}
+
+	switch portfolio.Alignment(strings.ToLower(r.Alignment)) {
+	case "", portfolio.AlignIntersect, portfolio.AlignUnion:
+	default:
+		return errors.New("alignment must be 'intersect' or 'union'")
+	}
+	if _, _, err := r.history(); err != nil {
+		return err
+	}
+	switch cache.Mode(strings.ToLower(r.Cache)) {
+	case "", cache.ModeUse, cache.ModeRefresh, cache.ModeBypass:
+	default:
+		return errors.New("cache must be 'use', 'refresh' or 'bypass'")
+	}
+
+	return nil
+}
+
+// validatePortfolio checks the tickers and weights of a portfolio.
+func validatePortfolio(assets []AssetRequest) error {
 	totalWeight := 0.0
-	for _, a := range r.Portfolio {
+	for _, a := range assets {
 		if a.Ticker == "" {
 			return errors.New("each asset in portfolio must have a ticker")
 		}
@@ -55,20 +612,208 @@ func (r *SimulationRequest) Validate() error {
 		if a.Weight > 1.0 {
 			return errors.New("individual asset weight cannot exceed 1.0 (100%)")
 		}
+		if a.ExpenseRatio < 0 || a.ExpenseRatio > 0.1 {
+			return errors.New("asset expense ratios must be between 0 and 0.1")
+		}
+		switch simulation.AssetClass(a.assetClass()) {
+		case simulation.ClassEquity, simulation.ClassBond, simulation.ClassCash:
+		default:
+			return errors.New("asset class must be 'equity', 'bond' or 'cash'")
+		}
+		if s := a.Synthetic; s != nil {
+			if err := s.validate(); err != nil {
+				return fmt.Errorf("synthetic asset %s: %w", a.Ticker, err)
+			}
+		}
+		if c := a.Assumptions; c != nil {
+			if a.Synthetic != nil {
+				return fmt.Errorf("synthetic asset %s cannot have capital market assumptions", a.Ticker)
+			}
+			if c.ExpectedReturn == nil && c.Volatility == nil {
+				return errors.New("capital market assumptions need an expected return or volatility")
+			}
+			if c.ExpectedReturn != nil && (*c.ExpectedReturn <= -1 || *c.ExpectedReturn > 1) {
+				return errors.New("expected return must be above -1 and at most 1")
+			}
+			if c.Volatility != nil && (*c.Volatility <= 0 || *c.Volatility > 2) {
+				return errors.New("assumed volatility must be above 0 and at most 2")
+			}
+		}
+		if b := a.Backfill; b != nil {
+			if err := b.validate(a); err != nil {
+				return err
+			}
+		}
 		// AssetType checks are removed.
 		totalWeight += a.Weight
 	}
 	if math.Abs(totalWeight-1.0) > 0.01 {
 		return errors.New("sum of portfolio weights must be approximately 1.0")
 	}
+	return nil
+}
 
-	if method := strings.ToLower(r.Method); method != "normal" && method != "bootstrap" {
-		return errors.New("method must be 'normal' or 'bootstrap'")
+// validate checks the chain of proxies behind asset a.
+func (b *BackfillRequest) validate(a AssetRequest) error {
+	if a.Synthetic != nil {
+		return fmt.Errorf("synthetic asset %s cannot be backfilled", a.Ticker)
 	}
+	if len(b.Proxies) == 0 || len(b.Proxies) > maxProxies {
+		return fmt.Errorf("backfill of %s needs between 1 and %d proxies", a.Ticker, maxProxies)
+	}
+	seen := map[string]bool{a.Ticker: true}
+	for _, proxy := range b.Proxies {
+		if proxy == "" {
+			return fmt.Errorf("backfill of %s has an empty proxy ticker", a.Ticker)
+		}
+		if seen[proxy] {
+			return fmt.Errorf("backfill of %s repeats ticker %s", a.Ticker, proxy)
+		}
+		seen[proxy] = true
+	}
+	return nil
+}
 
+// validate checks that the synthetic asset is either constant or parametric.
+func (s *SyntheticRequest) validate() error {
+	switch {
+	case (s.MonthlyReturn == nil) == (s.Mean == nil):
+		return errors.New("give either monthlyReturn or mean and volatility")
+	case s.MonthlyReturn != nil:
+		if *s.MonthlyReturn <= -1 || *s.MonthlyReturn > 1 {
+			return errors.New("monthly return must be above -1 and at most 1")
+		}
+		if s.Volatility != 0 || len(s.Correlations) > 0 {
+			return errors.New("a constant monthly return cannot have volatility or correlations")
+		}
+	default:
+		if *s.Mean <= -1 || *s.Mean > 1 {
+			return errors.New("mean must be above -1 and at most 1")
+		}
+		if s.Volatility < 0 || s.Volatility > 2 {
+			return errors.New("volatility must be between 0 and 2")
+		}
+		for _, c := range s.Correlations {
+			if c < -1 || c > 1 {
+				return errors.New("correlations must be between -1 and 1")
+			}
+		}
+	}
+	return nil
+}
+
+// validateCorrelations checks that synthetic assets are only correlated with other
+// tickers that are held.
+func validateCorrelations(assets []AssetRequest) error {
+	tickers := make(map[string]bool, len(assets))
+	for _, a := range assets {
+		tickers[a.Ticker] = true
+	}
+	for _, a := range assets {
+		if a.Synthetic == nil {
+			continue
+		}
+		for ticker := range a.Synthetic.Correlations {
+			if ticker == a.Ticker || !tickers[ticker] {
+				return fmt.Errorf("synthetic asset %s is correlated with %s, which is not another asset in the portfolio", a.Ticker, ticker)
+			}
+		}
+	}
 	return nil
 }
 
+// validateAccounts checks the accounts, which take the place of portfolio and initialValue.
+func (r *SimulationRequest) validateAccounts() error {
+	if len(r.Portfolio) > 0 || r.InitialVal != 0 {
+		return errors.New("portfolio and initial value must be omitted when accounts are given")
+	}
+	if r.GlidePath != nil || r.Annuity != nil || r.Bucket != nil || r.Leverage != nil {
+		return errors.New("accounts cannot be combined with a glide path, annuity, bucket or leverage")
+	}
+	total := 0.0
+	for _, a := range r.Accounts {
+		switch strings.ToLower(a.Type) {
+		case "taxable", "tax-deferred", "roth":
+		default:
+			return errors.New("account type must be 'taxable', 'tax-deferred' or 'roth'")
+		}
+		if a.Balance < 0 {
+			return errors.New("account balances cannot be negative")
+		}
+		if a.CostBasis != nil && *a.CostBasis < 0 {
+			return errors.New("account cost basis cannot be negative")
+		}
+		if len(a.Portfolio) == 0 {
+			return fmt.Errorf("account %s must have a portfolio", a.Name)
+		}
+		if err := validatePortfolio(a.Portfolio); err != nil {
+			return fmt.Errorf("account %s: %w", a.Name, err)
+		}
+		total += a.Balance
+	}
+	if total <= 0 {
+		return errors.New("account balances must add up to more than 0")
+	}
+	return nil
+}
+
+// validateScenarios checks the stress scenarios against the horizon.
+func (r *SimulationRequest) validateScenarios() error {
+	if len(r.Scenarios) > maxScenarios {
+		return fmt.Errorf("at most %d scenarios can be requested", maxScenarios)
+	}
+	for _, s := range r.Scenarios {
+		if len(s.Returns) == 0 {
+			if _, err := s.Scenario(); err != nil {
+				return fmt.Errorf("scenario name must be one of %s, or custom returns must be given", strings.Join(simulation.ScenarioNames(), ", "))
+			}
+		}
+		if s.Start < 0 || s.Start >= r.Periods {
+			return errors.New("scenario start must be between 0 and periods-1")
+		}
+		if len(s.Returns) == 0 && (s.BondReturns != nil || s.CashReturns != nil || s.Inflation != nil) {
+			return errors.New("scenario bond returns, cash returns and inflation need custom returns")
+		}
+		for _, returns := range [][]float64{s.BondReturns, s.CashReturns} {
+			if returns != nil && len(returns) != len(s.Returns) {
+				return errors.New("scenario bond and cash returns must be as many as its returns")
+			}
+		}
+		for _, ret := range slices.Concat(s.Returns, s.BondReturns, s.CashReturns) {
+			if ret <= -1 || ret > 10 {
+				return errors.New("scenario returns must be above -1 and at most 10")
+			}
+		}
+		if s.Inflation != nil && (*s.Inflation < -0.1 || *s.Inflation > 1) {
+			return errors.New("scenario inflation must be between -0.1 and 1")
+		}
+	}
+	return nil
+}
+
+// validateRMD checks the RMD rule against the accounts it applies to.
+func (r *SimulationRequest) validateRMD() error {
+	if len(r.Accounts) == 0 {
+		return errors.New("rmd requires accounts")
+	}
+	if r.RMD.Age < 0 || r.RMD.Age >= mortality.MaxAge {
+		return fmt.Errorf("rmd age must be between 0 and %d", mortality.MaxAge-1)
+	}
+	table, err := r.RMD.DivisorTable()
+	if err != nil {
+		return err
+	}
+	if start := r.RMD.startAge(); start < table.StartAge || start >= mortality.MaxAge {
+		return fmt.Errorf("rmd start age must be between %d and %d", table.StartAge, mortality.MaxAge-1)
+	}
+	for _, a := range r.Accounts {
+		if strings.ToLower(a.Type) == "taxable" {
+			return nil
+		}
+	}
+	return errors.New("rmd requires a taxable account to reinvest excess distributions; add one with a balance of 0 if needed")
+}
+
 // SummaryStatsResponse and SimulationResponse remain the same.
 type SummaryStatsResponse struct {
 	Mean   float64 `json:"mean"`
@@ -77,9 +822,315 @@ type SummaryStatsResponse struct {
 	Max    float64 `json:"max"`
 }
 
+// ConvergencePointResponse is the success-rate estimate and its 95% confidence interval after a batch of paths.
+type ConvergencePointResponse struct {
+	Simulations int     `json:"simulations"`
+	SuccessRate float64 `json:"successRate"`
+	StdError    float64 `json:"stdError"`
+	Lower       float64 `json:"ciLower"`
+	Upper       float64 `json:"ciUpper"`
+}
+
 type SimulationResponse struct {
-	Paths         [][]float64          `json:"paths"`
-	FinalStats    SummaryStatsResponse `json:"finalStats"`
-	SuccessRate   float64              `json:"successRate"`
-	SimulatedCAGR float64              `json:"simulatedCAGR"`
+	Paths         [][]float64                `json:"paths"`
+	FinalStats    SummaryStatsResponse       `json:"finalStats"`
+	SuccessRate   float64                    `json:"successRate"`
+	SimulatedCAGR float64                    `json:"simulatedCAGR"`
+	Convergence   []ConvergencePointResponse `json:"convergence"`
+	Converged     bool                       `json:"converged"` // True when targetStdError was met
+	Lifespan      *LifespanStatsResponse     `json:"lifespan,omitempty"`
+	Annuity       *AnnuityComparisonResponse `json:"annuity,omitempty"`
+	Bucket        *BucketComparisonResponse  `json:"bucket,omitempty"`
+	Allocations   *AllocationCurveResponse   `json:"allocations,omitempty"`
+	Fees          *FeeStatsResponse          `json:"fees,omitempty"`
+	Accounts      *AccountStatsResponse      `json:"accounts,omitempty"`
+	Leverage      *LeverageStatsResponse     `json:"leverage,omitempty"`
+	Stress        *StressStatsResponse       `json:"stress,omitempty"`
+	Scores        *ScoreStatsResponse        `json:"scores,omitempty"`
+	History       *HistoryResponse           `json:"history,omitempty"`   // Calendar months of history the returns were drawn from
+	Providers     []ProviderResponse         `json:"providers,omitempty"` // Which price provider served each ticker
+	Seed          int64                      `json:"seed"`                // Seed used; send it back to reproduce the run
+}
+
+// ProviderResponse records where a ticker's prices came from.
+type ProviderResponse struct {
+	Ticker    string `json:"ticker"`
+	Provider  string `json:"provider"`            // Name of the provider, e.g. "tiingo" or "csv"
+	FetchedAt string `json:"fetchedAt,omitempty"` // When the provider was asked, RFC 3339
+	Cached    bool   `json:"cached"`              // Served from the price cache
+}
+
+// HistoryResponse is the range of calendar months the assets' returns were aligned on.
+type HistoryResponse struct {
+	Start     string                 `json:"start"`             // First month, e.g. "2004-11"
+	End       string                 `json:"end"`               // Last month
+	Months    int                    `json:"months"`            // Months of returns; fewer than the span when months are missing
+	Alignment string                 `json:"alignment"`         // "intersect" or "union"
+	Excluded  int                    `json:"excludedMonths"`    // Months between start and end left out by the request's exclusions
+	Sources   []AssetSourcesResponse `json:"sources,omitempty"` // Where the months of backfilled assets came from
+}
+
+// AssetSourcesResponse lists the tickers a backfilled asset's months came from.
+type AssetSourcesResponse struct {
+	Ticker  string                `json:"ticker"`
+	Sources []SourceRangeResponse `json:"sources"` // Earliest first; only months within the history are counted
+}
+
+// SourceRangeResponse is a run of months taken from one ticker.
+type SourceRangeResponse struct {
+	Ticker          string  `json:"ticker"`          // The asset itself or a proxy
+	Start           string  `json:"start"`           // First month, e.g. "1998-01"
+	End             string  `json:"end"`             // Last month
+	Months          int     `json:"months"`          // Months taken from the ticker
+	VolatilityScale float64 `json:"volatilityScale"` // Factor applied to the proxy's volatility; 1 when unscaled
+}
+
+// LeverageStatsResponse reports margin calls and compares leveraged and unleveraged results on the same paths.
+type LeverageStatsResponse struct {
+	MarginCallRate      float64              `json:"marginCallRate"`      // Share of paths with at least one margin call
+	AverageMarginCalls  float64              `json:"averageMarginCalls"`  // Mean margin calls per path
+	LeveragedTerminal   SummaryStatsResponse `json:"leveragedTerminal"`   // Final equity with leverage
+	UnleveragedTerminal SummaryStatsResponse `json:"unleveragedTerminal"` // Final value without leverage
+	WithLeverage        OutcomeResponse      `json:"withLeverage"`
+	WithoutLeverage     OutcomeResponse      `json:"withoutLeverage"`
+}
+
+// ScoreStatsResponse scores consumption across paths, as fractions of planned spending.
+type ScoreStatsResponse struct {
+	ExpectedUtility           float64 `json:"expectedUtility"`
+	CertaintyEquivalent       float64 `json:"certaintyEquivalent"`       // Guaranteed consumption with the same expected utility
+	AverageShortfall          float64 `json:"averageShortfall"`          // Mean share of planned spending left unfunded
+	WorstShortfall            float64 `json:"worstShortfall"`            // Largest share left unfunded on any path
+	AverageYearsUnfunded      float64 `json:"averageYearsUnfunded"`      // Mean years not fully funded, across all paths
+	YearsUnfundedWhenDepleted float64 `json:"yearsUnfundedWhenDepleted"` // Mean years not fully funded, among paths that were
+	WorstYearsUnfunded        float64 `json:"worstYearsUnfunded"`
+}
+
+// StressStatsResponse compares the baseline run with each stress scenario on the same paths.
+type StressStatsResponse struct {
+	Baseline  OutcomeResponse           `json:"baseline"`
+	Scenarios []ScenarioOutcomeResponse `json:"scenarios"`
+}
+
+// ScenarioOutcomeResponse is the result with one stress scenario spliced into every path.
+type ScenarioOutcomeResponse struct {
+	Name       string               `json:"name"`
+	Start      int                  `json:"start"`
+	Outcome    OutcomeResponse      `json:"outcome"`
+	FinalStats SummaryStatsResponse `json:"finalStats"` // Final values with the scenario
+}
+
+// AccountStatsResponse reports after-tax results of a multi-account run.
+type AccountStatsResponse struct {
+	AfterTaxSpending SummaryStatsResponse     `json:"afterTaxSpending"` // Total after-tax spending per path
+	TaxesPaid        SummaryStatsResponse     `json:"taxesPaid"`        // Total taxes paid per path
+	AfterTaxTerminal SummaryStatsResponse     `json:"afterTaxTerminal"` // Final value net of the tax due on liquidation
+	ExcessRMD        SummaryStatsResponse     `json:"excessRmd"`        // Distributions forced by RMDs beyond spending, reinvested in taxable
+	SpendingPerPath  []float64                `json:"spendingPerPath"`  // After-tax spending of each path, in the order of paths
+	TaxesPerPath     []float64                `json:"taxesPerPath"`     // Taxes paid on each path, in the order of paths
+	FinalBalances    []AccountBalanceResponse `json:"finalBalances"`    // Mean final balance of each account
+}
+
+// AccountBalanceResponse is the mean final balance of one account.
+type AccountBalanceResponse struct {
+	Name    string  `json:"name"`
+	Type    string  `json:"type"`
+	Balance float64 `json:"balance"`
+}
+
+// FeeStatsResponse reports the fees paid and compares the outcome with a fee-free run on the same paths.
+type FeeStatsResponse struct {
+	AverageFeesPaid          float64         `json:"averageFeesPaid"`          // Mean total fees paid per path
+	MedianFeesPaid           float64         `json:"medianFeesPaid"`           // Median total fees paid per path
+	MeanTerminalDifference   float64         `json:"meanTerminalDifference"`   // Mean terminal value lost to fees
+	MedianTerminalDifference float64         `json:"medianTerminalDifference"` // Median terminal value lost to fees
+	WithFees                 OutcomeResponse `json:"withFees"`
+	WithoutFees              OutcomeResponse `json:"withoutFees"`
+}
+
+// AllocationCurveResponse is the glide path's allocation at each period from 0 to periods.
+type AllocationCurveResponse struct {
+	Tickers []string    `json:"tickers"`
+	Weights [][]float64 `json:"weights"` // One row per period, columns in the order of tickers
+}
+
+// BucketComparisonResponse compares the cash-bucket strategy with plain withdrawals, on the same paths.
+type BucketComparisonResponse struct {
+	EmptiedRate        float64         `json:"emptiedRate"`        // Share of paths where the bucket ran dry at least once
+	AverageEmptyMonths float64         `json:"averageEmptyMonths"` // Mean months per path the bucket could not cover
+	WithBucket         OutcomeResponse `json:"withBucket"`
+	WithoutBucket      OutcomeResponse `json:"withoutBucket"`
+}
+
+// OutcomeResponse summarises one strategy in a side-by-side comparison.
+type OutcomeResponse struct {
+	SuccessRate     float64 `json:"successRate"`
+	MedianTerminal  float64 `json:"medianTerminal"`
+	MeanTerminal    float64 `json:"meanTerminal"`
+	ExpectedBequest float64 `json:"expectedBequest"` // Mean value at death with lifespans, otherwise the mean terminal value
+
+	CertaintyEquivalent float64 `json:"certaintyEquivalent,omitempty"` // Certainty-equivalent consumption when scoring is requested
+}
+
+// AnnuityComparisonResponse compares buying the annuity with keeping everything invested, on the same paths.
+type AnnuityComparisonResponse struct {
+	PurchaseRate         float64         `json:"purchaseRate"`         // Share of paths solvent at purchase time
+	AveragePremium       float64         `json:"averagePremium"`       // Mean premium paid
+	AverageMonthlyIncome float64         `json:"averageMonthlyIncome"` // Mean first monthly payment
+	WithAnnuity          OutcomeResponse `json:"withAnnuity"`
+	WithoutAnnuity       OutcomeResponse `json:"withoutAnnuity"`
+}
+
+// LifespanStatsResponse holds mortality-weighted outcomes; successRate is then measured until death.
+type LifespanStatsResponse struct {
+	ProbabilityOfRuin          float64 `json:"probabilityOfRuin"`          // Probability of outliving the money
+	ExpectedBequest            float64 `json:"expectedBequest"`            // Mean portfolio value at death
+	ExpectedShortfallYears     float64 `json:"expectedShortfallYears"`     // Mean unfunded years across all paths
+	ShortfallYearsWhenDepleted float64 `json:"shortfallYearsWhenDepleted"` // Mean unfunded years among ruined paths
+	ExpectedLifespanYears      float64 `json:"expectedLifespanYears"`      // Mean remaining lifetime, capped at the horizon
+}
+
+// Inputs that a sensitivity analysis can vary.
+const (
+	inputWithdrawalRate = "withdrawalRate"
+	inputInflation      = "inflation"
+	inputEquityWeight   = "equityWeight"
+	inputAdvisoryFee    = "advisoryFee"
+	inputExpectedReturn = "expectedReturn"
+)
+
+// Limits that keep a sensitivity analysis to a bounded number of simulation runs.
+const (
+	maxSensitivityInputs = 8
+	maxGridPoints        = 125
+	maxSensitivityPaths  = 500000 // Paths over every run, each with base.simulations paths.
+)
+
+// SensitivityRequest varies inputs of a base simulation, one at a time between their low
+// and high values and optionally all together on a grid. Every run uses the same seed,
+// so differences come from the inputs rather than from the random draws.
+type SensitivityRequest struct {
+	Base      SimulationRequest  `json:"base"`      // Simulation the inputs are varied from; targetStdError is ignored
+	Inputs    []SensitivityInput `json:"inputs"`    // Inputs to vary
+	GridSteps int                `json:"gridSteps"` // Optional: values per input for a full grid sweep (e.g. 3); 0 skips the grid
+}
+
+// SensitivityInput is one input and the range it is varied over.
+type SensitivityInput struct {
+	Name   string  `json:"name"`   // "withdrawalRate", "inflation", "equityWeight", "advisoryFee" or "expectedReturn"
+	Ticker string  `json:"ticker"` // Asset whose weight or expected return is varied
+	Low    float64 `json:"low"`
+	High   float64 `json:"high"`
+}
+
+// label identifies the input in grid points.
+func (in SensitivityInput) label() string {
+	if in.Ticker == "" {
+		return in.Name
+	}
+	return in.Name + ":" + in.Ticker
+}
+
+// Validate checks the base request and the inputs. Each varied request is validated
+// again when it is built, which catches values the base request would reject.
+func (r *SensitivityRequest) Validate() error {
+	if err := r.Base.Validate(); err != nil {
+		return fmt.Errorf("base: %w", err)
+	}
+	if len(r.Inputs) == 0 || len(r.Inputs) > maxSensitivityInputs {
+		return fmt.Errorf("sensitivity needs between 1 and %d inputs", maxSensitivityInputs)
+	}
+	seen := make(map[string]bool, len(r.Inputs))
+	for _, in := range r.Inputs {
+		switch in.Name {
+		case inputWithdrawalRate, inputInflation, inputAdvisoryFee:
+			if in.Ticker != "" {
+				return fmt.Errorf("sensitivity input %s does not take a ticker", in.Name)
+			}
+			if in.Name == inputAdvisoryFee && r.Base.AdvisoryFee != nil && len(r.Base.AdvisoryFee.Tiers) > 0 {
+				// A flat rate against a tiered base would mix the fee level with the fee structure.
+				return errors.New("sensitivity input advisoryFee varies a flat rate, so the base advisory fee must not be tiered")
+			}
+		case inputEquityWeight, inputExpectedReturn:
+			if len(r.Base.Accounts) > 0 {
+				return fmt.Errorf("sensitivity input %s needs a portfolio, not accounts", in.Name)
+			}
+			if in.Name == inputEquityWeight && r.Base.GlidePath != nil {
+				// The glide path's own weights take over from the portfolio's.
+				return errors.New("sensitivity input equityWeight cannot be varied with a glide path")
+			}
+			if !slices.ContainsFunc(r.Base.Portfolio, func(a AssetRequest) bool { return a.Ticker == in.Ticker }) {
+				return fmt.Errorf("sensitivity input %s needs a ticker from the portfolio", in.Name)
+			}
+		default:
+			return errors.New("sensitivity input name must be 'withdrawalRate', 'inflation', 'equityWeight', 'advisoryFee' or 'expectedReturn'")
+		}
+		if in.Low >= in.High {
+			return fmt.Errorf("sensitivity input %s must have low below high", in.label())
+		}
+		if seen[in.label()] {
+			return fmt.Errorf("sensitivity input %s is given more than once", in.label())
+		}
+		seen[in.label()] = true
+	}
+	if r.GridSteps != 0 {
+		if r.GridSteps < 2 {
+			return errors.New("grid steps must be 0 or at least 2")
+		}
+		if math.Pow(float64(r.GridSteps), float64(len(r.Inputs))) > maxGridPoints {
+			return fmt.Errorf("grid steps ^ inputs must be at most %d points", maxGridPoints)
+		}
+		// Grid points set every varied weight at once, up to all of their highs.
+		highs := 0.0
+		for _, in := range r.Inputs {
+			if in.Name == inputEquityWeight {
+				highs += in.High
+			}
+		}
+		if highs > 1 {
+			return errors.New("sensitivity inputs equityWeight must have highs adding up to at most 1 for a grid")
+		}
+	}
+	runs := 1 + 2*len(r.Inputs)
+	if r.GridSteps > 0 {
+		runs += int(math.Pow(float64(r.GridSteps), float64(len(r.Inputs))))
+	}
+	if runs*r.Base.Simulations > maxSensitivityPaths {
+		return fmt.Errorf("sensitivity runs * simulations must be at most %d paths", maxSensitivityPaths)
+	}
+	return nil
+}
+
+// SensitivityResponse reports the outcome of each varied run against the base run.
+type SensitivityResponse struct {
+	Seed     int64                      `json:"seed"` // Seed shared by every run
+	Baseline SensitivityPointResponse   `json:"baseline"`
+	Inputs   []InputSensitivityResponse `json:"inputs"`         // Ordered by success-rate swing, largest first, for a tornado chart
+	Grid     []GridPointResponse        `json:"grid,omitempty"` // Every combination of grid values
+}
+
+// SensitivityPointResponse is the outcome of one run and its change from the base run.
+type SensitivityPointResponse struct {
+	SuccessRate          float64 `json:"successRate"`
+	MedianTerminal       float64 `json:"medianTerminal"`
+	SuccessRateChange    float64 `json:"successRateChange"`
+	MedianTerminalChange float64 `json:"medianTerminalChange"`
+}
+
+// InputSensitivityResponse is the outcome with one input at its low and at its high value.
+type InputSensitivityResponse struct {
+	Name                string                   `json:"name"`
+	Ticker              string                   `json:"ticker,omitempty"`
+	LowValue            float64                  `json:"lowValue"`
+	HighValue           float64                  `json:"highValue"`
+	Low                 SensitivityPointResponse `json:"low"`
+	High                SensitivityPointResponse `json:"high"`
+	SuccessRateSwing    float64                  `json:"successRateSwing"`    // |high - low| success rate
+	MedianTerminalSwing float64                  `json:"medianTerminalSwing"` // |high - low| median terminal value
+}
+
+// GridPointResponse is the outcome at one combination of input values.
+type GridPointResponse struct {
+	Values map[string]float64 `json:"values"` // Value per input, keyed by name or name:ticker
+	SensitivityPointResponse
 }
@@ -38,6 +38,21 @@ func TestSimulationRequest_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "sobol sampler with normal method",
+			req: SimulationRequest{
+				InitialVal:  10000,
+				Periods:     240,
+				Simulations: 500,
+				Withdrawal:  0.04,
+				Method:      "normal",
+				Sampler:     "sobol",
+				Portfolio: []AssetRequest{
+					{Ticker: "AAPL", Weight: 1.0},
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "invalid method",
 			req: SimulationRequest{
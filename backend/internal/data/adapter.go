@@ -0,0 +1,75 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ReturnsFetcher is implemented by fetchers that only provide monthly returns.
+type ReturnsFetcher interface {
+	GetMonthlyReturns(ticker string, window Window) ([]float64, error)
+}
+
+// ReturnSeriesFetcher is implemented by returns fetchers that can also label each
+// return with its month.
+type ReturnSeriesFetcher interface {
+	GetMonthlyReturnSeries(ticker string, window Window) (ReturnSeries, error)
+}
+
+// AdaptReturns wraps a fetcher of returns as a PriceFetcher, so fetchers written before
+// PriceFetcher keep working. Prices are rebuilt from the returns as an index starting
+// at 1; they are dated when the fetcher is also a ReturnSeriesFetcher. Only monthly
+// adjusted prices can be rebuilt, and there are no dividends.
+func AdaptReturns(f ReturnsFetcher, source string) PriceFetcher {
+	return &returnsAdapter{fetcher: f, source: source}
+}
+
+type returnsAdapter struct {
+	fetcher ReturnsFetcher
+	source  string
+}
+
+func (a *returnsAdapter) FetchPrices(ctx context.Context, ticker string, opts FetchOptions) (PriceSeries, error) {
+	opts = opts.Normalized()
+	if opts.Frequency != FrequencyMonthly || opts.PriceType != PriceAdjusted {
+		return PriceSeries{}, fmt.Errorf("data: %s only provides monthly total returns", a.source)
+	}
+	if err := ctx.Err(); err != nil {
+		return PriceSeries{}, err
+	}
+
+	var series ReturnSeries
+	var err error
+	if sf, ok := a.fetcher.(ReturnSeriesFetcher); ok {
+		series, err = sf.GetMonthlyReturnSeries(ticker, opts.Window)
+	} else {
+		series.Returns, err = a.fetcher.GetMonthlyReturns(ticker, opts.Window)
+	}
+	if err != nil {
+		return PriceSeries{}, err
+	}
+
+	prices := PriceSeries{
+		Ticker:     ticker,
+		Frequency:  FrequencyMonthly,
+		PriceType:  PriceAdjusted,
+		Provenance: Provenance{Source: a.source, FetchedAt: time.Now()},
+	}
+	if len(series.Returns) == 0 {
+		if series.Dated() {
+			prices.Dates = []time.Time{} // Keeps the empty history dated.
+		}
+		return prices, nil
+	}
+	prices.Closes = make([]float64, len(series.Returns)+1)
+	prices.Closes[0] = 1
+	for i, r := range series.Returns {
+		prices.Closes[i+1] = prices.Closes[i] * (1 + r)
+	}
+	if series.Dated() {
+		// The index starts at the end of the month before the first return.
+		prices.Dates = append([]time.Time{MonthOf(series.Months[0]).AddDate(0, -1, 0)}, series.Months...)
+	}
+	return prices, nil
+}
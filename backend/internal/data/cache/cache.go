@@ -0,0 +1,226 @@
+// Package cache keeps fetched price series on disk, so repeated simulations of the same
+// tickers do not go back to the data provider.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"portfolio-simulator/backend/internal/data"
+)
+
+// Mode controls how a request uses the cache.
+type Mode string
+
+const (
+	// ModeUse serves fresh entries from the cache and fetches the rest. It is the default.
+	ModeUse Mode = "use"
+	// ModeRefresh fetches every series again and stores the result.
+	ModeRefresh Mode = "refresh"
+	// ModeBypass fetches every series without reading or writing the cache.
+	ModeBypass Mode = "bypass"
+)
+
+type modeKey struct{}
+
+// WithMode returns a context that makes the cache handle fetches in mode.
+func WithMode(ctx context.Context, mode Mode) context.Context {
+	return context.WithValue(ctx, modeKey{}, mode)
+}
+
+// modeOf returns the mode set on ctx, ModeUse if none is.
+func modeOf(ctx context.Context) Mode {
+	if mode, ok := ctx.Value(modeKey{}).(Mode); ok && mode != "" {
+		return mode
+	}
+	return ModeUse
+}
+
+// Fetcher is a PriceFetcher that stores the series of the fetcher it wraps on disk.
+// An entry is refetched once it is older than the TTL. Entries whose window runs to
+// today are also refetched once a new month has started, as month-end data only
+// changes then. Concurrent fetches of the same series share a single request.
+type Fetcher struct {
+	next  data.PriceFetcher
+	dir   string
+	ttl   time.Duration
+	now   func() time.Time
+	group group
+}
+
+// entry is the JSON stored for each series.
+type entry struct {
+	StoredAt time.Time        `json:"storedAt"`
+	Series   data.PriceSeries `json:"series"`
+}
+
+// New returns a cache in dir, which is created if needed, around next.
+func New(next data.PriceFetcher, dir string, ttl time.Duration) (*Fetcher, error) {
+	if ttl <= 0 {
+		return nil, errors.New("cache: TTL must be positive")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cache: creating %s failed: %w", dir, err)
+	}
+	return &Fetcher{next: next, dir: dir, ttl: ttl, now: time.Now}, nil
+}
+
+// FetchPrices returns the cached series for ticker and opts if it is fresh, and fetches
+// and stores it otherwise. Failed fetches are not cached.
+func (f *Fetcher) FetchPrices(ctx context.Context, ticker string, opts data.FetchOptions) (data.PriceSeries, error) {
+	opts = opts.Normalized()
+	mode := modeOf(ctx)
+	if mode == ModeBypass {
+		log.Printf("cache: bypassed for %s", ticker)
+		return f.next.FetchPrices(ctx, ticker, opts)
+	}
+
+	key := cacheKey(ticker, opts)
+	if mode == ModeUse {
+		if e, ok := f.load(key); ok && f.fresh(e, opts) {
+			log.Printf("cache: hit for %s", ticker)
+			e.Series.Provenance.Cached = true
+			return e.Series, nil
+		}
+		log.Printf("cache: miss for %s", ticker)
+	} else {
+		log.Printf("cache: refreshing %s", ticker)
+	}
+
+	return f.group.do(ctx, key, func(ctx context.Context) (data.PriceSeries, error) {
+		series, err := f.next.FetchPrices(ctx, ticker, opts)
+		if err != nil {
+			return data.PriceSeries{}, err
+		}
+		f.store(key, entry{StoredAt: f.now(), Series: series})
+		return series, nil
+	})
+}
+
+// fresh reports whether e can still be served.
+func (f *Fetcher) fresh(e entry, opts data.FetchOptions) bool {
+	now := f.now()
+	if now.Sub(e.StoredAt) >= f.ttl {
+		return false
+	}
+	if opts.Window.End.IsZero() && !data.MonthOf(now).Equal(data.MonthOf(e.StoredAt)) {
+		return false // A month has ended since, so there is a new month-end price.
+	}
+	return true
+}
+
+// cacheKey names the file of a series; the options are part of it, so different windows
+// and price types are stored apart.
+func cacheKey(ticker string, opts data.FetchOptions) string {
+	month := func(t time.Time) string {
+		if t.IsZero() {
+			return "open"
+		}
+		return data.MonthOf(t).Format("2006-01")
+	}
+	raw := fmt.Sprintf("%s|%s|%s|%s|%s", ticker, month(opts.Window.Start), month(opts.Window.End), opts.Frequency, opts.PriceType)
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:16])
+}
+
+func (f *Fetcher) path(key string) string {
+	return filepath.Join(f.dir, key+".json")
+}
+
+// load reads the entry for key. Unreadable entries count as missing.
+func (f *Fetcher) load(key string) (entry, bool) {
+	raw, err := os.ReadFile(f.path(key))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("cache: reading %s failed: %v", f.path(key), err)
+		}
+		return entry{}, false
+	}
+	var e entry
+	if err := json.Unmarshal(raw, &e); err != nil {
+		log.Printf("cache: decoding %s failed: %v", f.path(key), err)
+		return entry{}, false
+	}
+	return e, true
+}
+
+// store writes the entry for key through a temporary file, so readers never see part of
+// it. A failed write is logged; the fetched series is still served.
+func (f *Fetcher) store(key string, e entry) {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("cache: encoding %s failed: %v", e.Series.Ticker, err)
+		return
+	}
+	tmp, err := os.CreateTemp(f.dir, key+".*.tmp")
+	if err != nil {
+		log.Printf("cache: writing %s failed: %v", e.Series.Ticker, err)
+		return
+	}
+	_, err = tmp.Write(raw)
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.path(key))
+	}
+	if err != nil {
+		log.Printf("cache: writing %s failed: %v", e.Series.Ticker, err)
+		_ = os.Remove(tmp.Name())
+	}
+}
+
+// group runs one fetch per key at a time and shares its result with every caller that
+// asks for the key meanwhile.
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	done   chan struct{}
+	series data.PriceSeries
+	err    error
+}
+
+// do runs fn for key unless a run is already under way, and waits for the result. The
+// run is not cancelled with the first caller's context, as others may be waiting on it;
+// each caller stops waiting when its own context is done.
+func (g *group) do(ctx context.Context, key string, fn func(context.Context) (data.PriceSeries, error)) (data.PriceSeries, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	c, running := g.calls[key]
+	if !running {
+		c = &call{done: make(chan struct{})}
+		g.calls[key] = c
+	}
+	g.mu.Unlock()
+
+	if !running {
+		go func() {
+			c.series, c.err = fn(context.WithoutCancel(ctx))
+			g.mu.Lock()
+			delete(g.calls, key)
+			g.mu.Unlock()
+			close(c.done)
+		}()
+	}
+
+	select {
+	case <-c.done:
+		return c.series, c.err
+	case <-ctx.Done():
+		return data.PriceSeries{}, ctx.Err()
+	}
+}
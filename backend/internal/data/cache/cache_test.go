@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"portfolio-simulator/backend/internal/data"
+)
+
+// countingFetcher returns a fixed series and counts its calls. When gate is set, each
+// call waits for it to be closed.
+type countingFetcher struct {
+	calls atomic.Int32
+	gate  chan struct{}
+	err   error
+}
+
+func (c *countingFetcher) FetchPrices(_ context.Context, ticker string, opts data.FetchOptions) (data.PriceSeries, error) {
+	c.calls.Add(1)
+	if c.gate != nil {
+		<-c.gate
+	}
+	if c.err != nil {
+		return data.PriceSeries{}, c.err
+	}
+	return data.PriceSeries{
+		Ticker:    ticker,
+		Frequency: opts.Frequency,
+		PriceType: opts.PriceType,
+		Dates:     []time.Time{time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		Closes:    []float64{100, 110},
+	}, nil
+}
+
+// newTestCache returns a cache in a temporary directory with a settable clock.
+func newTestCache(t *testing.T, next data.PriceFetcher, ttl time.Duration) (*Fetcher, *time.Time) {
+	f, err := New(next, t.TempDir(), ttl)
+	require.NoError(t, err)
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+	return f, &now
+}
+
+func TestFetcher_HitAndExpiry(t *testing.T) {
+	next := &countingFetcher{}
+	f, now := newTestCache(t, next, 24*time.Hour)
+	ctx := context.Background()
+
+	first, err := f.FetchPrices(ctx, "SPY", data.FetchOptions{})
+	require.NoError(t, err)
+	second, err := f.FetchPrices(ctx, "SPY", data.FetchOptions{})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), next.calls.Load(), "The second fetch is served from disk")
+	require.False(t, first.Provenance.Cached)
+	require.True(t, second.Provenance.Cached)
+	require.Equal(t, first.Closes, second.Closes)
+	require.Equal(t, first.Dates[1].Unix(), second.Dates[1].Unix())
+
+	_, err = f.FetchPrices(ctx, "SPY", data.FetchOptions{PriceType: data.PriceClose})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), next.calls.Load(), "Other options are stored apart")
+
+	*now = now.Add(25 * time.Hour)
+	_, err = f.FetchPrices(ctx, "SPY", data.FetchOptions{})
+	require.NoError(t, err)
+	require.Equal(t, int32(3), next.calls.Load(), "Expired entries are fetched again")
+}
+
+func TestFetcher_NewMonth(t *testing.T) {
+	next := &countingFetcher{}
+	f, now := newTestCache(t, next, 30*24*time.Hour)
+	ctx := context.Background()
+	closed := data.FetchOptions{Window: data.Window{End: time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)}}
+
+	for _, opts := range []data.FetchOptions{{}, closed} {
+		_, err := f.FetchPrices(ctx, "SPY", opts)
+		require.NoError(t, err)
+	}
+	*now = time.Date(2024, 4, 1, 9, 0, 0, 0, time.UTC)
+	for _, opts := range []data.FetchOptions{{}, closed} {
+		_, err := f.FetchPrices(ctx, "SPY", opts)
+		require.NoError(t, err)
+	}
+	require.Equal(t, int32(3), next.calls.Load(), "Only the window running to today has a new month-end price")
+}
+
+func TestFetcher_Modes(t *testing.T) {
+	next := &countingFetcher{}
+	f, _ := newTestCache(t, next, time.Hour)
+	ctx := context.Background()
+
+	_, err := f.FetchPrices(WithMode(ctx, ModeBypass), "SPY", data.FetchOptions{})
+	require.NoError(t, err)
+	_, err = f.FetchPrices(ctx, "SPY", data.FetchOptions{})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), next.calls.Load(), "Bypassed fetches are not stored")
+
+	_, err = f.FetchPrices(WithMode(ctx, ModeRefresh), "SPY", data.FetchOptions{})
+	require.NoError(t, err)
+	_, err = f.FetchPrices(ctx, "SPY", data.FetchOptions{})
+	require.NoError(t, err)
+	require.Equal(t, int32(3), next.calls.Load(), "Refreshing fetches again and stores the result")
+}
+
+func TestFetcher_ErrorsAreNotCached(t *testing.T) {
+	next := &countingFetcher{err: errors.New("quota exceeded")}
+	f, _ := newTestCache(t, next, time.Hour)
+
+	_, err := f.FetchPrices(context.Background(), "SPY", data.FetchOptions{})
+	require.ErrorContains(t, err, "quota exceeded")
+	next.err = nil
+	_, err = f.FetchPrices(context.Background(), "SPY", data.FetchOptions{})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), next.calls.Load())
+}
+
+func TestFetcher_ConcurrentFetchesShareOneRequest(t *testing.T) {
+	next := &countingFetcher{gate: make(chan struct{})}
+	f, _ := newTestCache(t, next, time.Hour)
+
+	const callers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := f.FetchPrices(context.Background(), "SPY", data.FetchOptions{})
+			errs <- err
+		}()
+	}
+	// Let the callers queue up behind the first fetch before it completes.
+	require.Eventually(t, func() bool { return next.calls.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	close(next.gate)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.Equal(t, int32(1), next.calls.Load())
+}
+
+func TestFetcher_WaiterCancelled(t *testing.T) {
+	next := &countingFetcher{gate: make(chan struct{})}
+	defer close(next.gate)
+	f, _ := newTestCache(t, next, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := f.FetchPrices(ctx, "SPY", data.FetchOptions{})
+	require.ErrorIs(t, err, context.Canceled)
+}
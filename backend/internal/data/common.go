@@ -22,7 +22,12 @@ func ToMonthlyReturns(prices []PriceData) []float64 {
 	sort.Slice(prices, func(i, j int) bool {
 		return prices[i].Date.Before(prices[j].Date)
 	})
+	return sequentialReturns(prices)
+}
 
+// sequentialReturns calculates the percentage return between each pair of neighbouring
+// prices, in the order given.
+func sequentialReturns(prices []PriceData) []float64 {
 	if len(prices) < 2 {
 		return nil // Need at least two data points to calculate one return.
 	}
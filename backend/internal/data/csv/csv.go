@@ -0,0 +1,233 @@
+// Package csv reads price histories from local CSV files, so simulations can run offline
+// on in-house data.
+package csv
+
+import (
+	"context"
+	encodingcsv "encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"portfolio-simulator/backend/internal/data"
+)
+
+// Columns names the CSV columns to read. Names are matched case-insensitively.
+type Columns struct {
+	Date     string // Defaults to "date".
+	Close    string // Raw close; defaults to "close".
+	AdjClose string // Close adjusted for dividends and splits; defaults to "adjClose".
+	Dividend string // Optional cash dividend per share; defaults to "dividend".
+}
+
+// Config configures a Provider.
+type Config struct {
+	Dir         string   // Directory holding one <TICKER>.csv file per ticker.
+	Columns     Columns  // Column names; empty names take the defaults.
+	DateFormats []string // Layouts tried in order for each date; defaults to "2006-01-02" and RFC 3339.
+}
+
+// Provider implements data.PriceFetcher over a directory of CSV files. Each file has a
+// header row and one row per date, daily or monthly, in any order. Daily prices are
+// resampled to month ends when monthly prices are asked for.
+type Provider struct {
+	dir         string
+	columns     Columns
+	dateFormats []string
+	now         func() time.Time
+}
+
+// New returns a Provider for cfg; the directory must exist.
+func New(cfg Config) (*Provider, error) {
+	info, err := os.Stat(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("csv: price directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("csv: %s is not a directory", cfg.Dir)
+	}
+	columns := cfg.Columns
+	if columns.Date == "" {
+		columns.Date = "date"
+	}
+	if columns.Close == "" {
+		columns.Close = "close"
+	}
+	if columns.AdjClose == "" {
+		columns.AdjClose = "adjClose"
+	}
+	if columns.Dividend == "" {
+		columns.Dividend = "dividend"
+	}
+	formats := cfg.DateFormats
+	if len(formats) == 0 {
+		formats = []string{"2006-01-02", time.RFC3339}
+	}
+	return &Provider{dir: cfg.Dir, columns: columns, dateFormats: formats, now: time.Now}, nil
+}
+
+// FetchPrices reads the ticker's file and converts it with data.Resample. Adjusted
+// prices come from the adjusted close column; in files without one they are the closes
+// with the dividend column reinvested, or the closes themselves when there are no
+// dividends either, which should then already be adjusted. Prices start a month before
+// the window, as with other providers, so that its first month has a return.
+func (p *Provider) FetchPrices(ctx context.Context, ticker string, opts data.FetchOptions) (data.PriceSeries, error) {
+	if err := ctx.Err(); err != nil {
+		return data.PriceSeries{}, err
+	}
+	opts = opts.Normalized()
+	if ticker == "" || strings.ContainsAny(ticker, `/\`) || ticker == "." || ticker == ".." {
+		return data.PriceSeries{}, fmt.Errorf("csv: invalid ticker %q", ticker)
+	}
+
+	path := filepath.Join(p.dir, ticker+".csv")
+	file, err := os.Open(path)
+	if err != nil {
+		return data.PriceSeries{}, fmt.Errorf("csv: no price file for %s: %w", ticker, err)
+	}
+	defer file.Close()
+
+	series, err := p.read(file, opts.PriceType)
+	if err != nil {
+		return data.PriceSeries{}, fmt.Errorf("csv: %s: %w", path, err)
+	}
+	if len(series.Closes) == 0 {
+		return data.PriceSeries{}, fmt.Errorf("csv: no price data in %s", path)
+	}
+	if opts.Frequency == data.FrequencyDaily && !daily(series.Dates) {
+		return data.PriceSeries{}, fmt.Errorf("csv: %s has only monthly prices", path)
+	}
+	series.Ticker = ticker
+	series.Provenance = data.Provenance{Source: "csv", FetchedAt: p.now()}
+	if series, err = data.Resample(series, opts.Frequency, opts.PriceType); err != nil {
+		return data.PriceSeries{}, fmt.Errorf("csv: %s: %w", path, err)
+	}
+
+	// Keep the month before the window too, so its first month has a return.
+	window := opts.Window
+	if !window.Start.IsZero() {
+		window.Start = data.MonthOf(window.Start).AddDate(0, -1, 0)
+	}
+	windowed := series
+	windowed.Dates, windowed.Closes, windowed.Dividends = []time.Time{}, nil, nil
+	for i, date := range series.Dates {
+		if window.Contains(date) {
+			windowed.Dates = append(windowed.Dates, date)
+			windowed.Closes = append(windowed.Closes, series.Closes[i])
+			windowed.Dividends = append(windowed.Dividends, series.Dividends[i])
+		}
+	}
+	if len(windowed.Closes) == 0 {
+		return data.PriceSeries{}, fmt.Errorf("csv: no prices for %s in the requested window", ticker)
+	}
+	return windowed, nil
+}
+
+// read parses a price file into a series sorted by date, holding adjusted closes when the
+// file has them and priceType asks for them, and raw closes otherwise.
+func (p *Provider) read(r io.Reader, priceType data.PriceType) (data.PriceSeries, error) {
+	reader := encodingcsv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return data.PriceSeries{}, fmt.Errorf("reading header: %w", err)
+	}
+	index := func(name string) int {
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), name) {
+				return i
+			}
+		}
+		return -1
+	}
+	dateCol := index(p.columns.Date)
+	if dateCol < 0 {
+		return data.PriceSeries{}, fmt.Errorf("no %q column", p.columns.Date)
+	}
+	series := data.PriceSeries{PriceType: data.PriceClose}
+	priceCol := index(p.columns.Close)
+	dividendCol := index(p.columns.Dividend)
+	if priceType == data.PriceAdjusted {
+		if adj := index(p.columns.AdjClose); adj >= 0 {
+			priceCol = adj // Dividends are already in the adjusted close, so they are only reported.
+			series.PriceType = data.PriceAdjusted
+		} else if dividendCol < 0 {
+			series.PriceType = data.PriceAdjusted // The closes are taken to be adjusted.
+		}
+	}
+	if priceCol < 0 {
+		return data.PriceSeries{}, fmt.Errorf("no %q column", p.columns.Close)
+	}
+
+	type row struct {
+		date            time.Time
+		price, dividend float64
+	}
+	var rows []row
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return data.PriceSeries{}, err
+		}
+		date, err := p.parseDate(record[dateCol])
+		if err != nil {
+			return data.PriceSeries{}, fmt.Errorf("line %d: %w", line, err)
+		}
+		price, err := strconv.ParseFloat(strings.TrimSpace(record[priceCol]), 64)
+		if err != nil {
+			return data.PriceSeries{}, fmt.Errorf("line %d: invalid price %q", line, record[priceCol])
+		}
+		var dividend float64
+		if dividendCol >= 0 {
+			if raw := strings.TrimSpace(record[dividendCol]); raw != "" {
+				if dividend, err = strconv.ParseFloat(raw, 64); err != nil {
+					return data.PriceSeries{}, fmt.Errorf("line %d: invalid dividend %q", line, raw)
+				}
+			}
+		}
+		rows = append(rows, row{date: date, price: price, dividend: dividend})
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return rows[i].date.Before(rows[j].date) })
+	series.Frequency = data.FrequencyMonthly
+	series.Dates = make([]time.Time, len(rows))
+	series.Closes = make([]float64, len(rows))
+	series.Dividends = make([]float64, len(rows))
+	for i, r := range rows {
+		series.Dates[i], series.Closes[i], series.Dividends[i] = r.date, r.price, r.dividend
+	}
+	if daily(series.Dates) {
+		series.Frequency = data.FrequencyDaily
+	}
+	return series, nil
+}
+
+// parseDate parses value with the first date format that fits.
+func (p *Provider) parseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range p.dateFormats {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("date %q matches none of the formats %q", value, p.dateFormats)
+}
+
+// daily reports whether any month has more than one of the sorted dates.
+func daily(dates []time.Time) bool {
+	for i := 1; i < len(dates); i++ {
+		if data.MonthOf(dates[i]).Equal(data.MonthOf(dates[i-1])) {
+			return true
+		}
+	}
+	return false
+}
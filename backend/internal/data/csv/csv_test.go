@@ -0,0 +1,115 @@
+package csv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"portfolio-simulator/backend/internal/data"
+)
+
+// writeFiles writes the named files into a temporary directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	return dir
+}
+
+func TestProvider_DailyResampledToMonthEnds(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"FUND.csv": `date,close,adjClose,dividend
+2024-02-01,101,101,
+2024-01-31,100,99,
+2024-01-15,98,97,0.5
+2024-02-29,105,104.5,0.25
+2024-03-28,103,103,
+`})
+	p, err := New(Config{Dir: dir})
+	require.NoError(t, err)
+
+	series, err := p.FetchPrices(context.Background(), "FUND", data.FetchOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []time.Time{
+		time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 28, 0, 0, 0, 0, time.UTC),
+	}, series.Dates)
+	require.Equal(t, []float64{99, 104.5, 103}, series.Closes, "Adjusted closes by default")
+	require.Equal(t, []float64{0.5, 0.25, 0}, series.Dividends, "Dividends add up over the month")
+	require.Equal(t, "csv", series.Provenance.Source)
+
+	returns, err := series.MonthlyReturns()
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), returns.Months[0])
+
+	raw, err := p.FetchPrices(context.Background(), "FUND", data.FetchOptions{PriceType: data.PriceClose, Frequency: data.FrequencyDaily})
+	require.NoError(t, err)
+	require.Equal(t, []float64{98, 100, 101, 105, 103}, raw.Closes)
+
+	windowed, err := p.FetchPrices(context.Background(), "FUND", data.FetchOptions{Window: data.Window{
+		Start: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}})
+	require.NoError(t, err)
+	require.Equal(t, []float64{104.5, 103}, windowed.Closes, "February's price gives March its return")
+}
+
+func TestProvider_TotalReturnFromDividends(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"BOND.csv": "date,close,dividend\n2024-01-31,50,\n2024-02-29,50,0.5\n"})
+	p, err := New(Config{Dir: dir})
+	require.NoError(t, err)
+
+	total, err := p.FetchPrices(context.Background(), "BOND", data.FetchOptions{})
+	require.NoError(t, err)
+	require.InDeltaSlice(t, []float64{50, 50.5}, total.Closes, 1e-12, "The coupon is reinvested")
+
+	price, err := p.FetchPrices(context.Background(), "BOND", data.FetchOptions{PriceType: data.PriceClose})
+	require.NoError(t, err)
+	require.InDeltaSlice(t, []float64{50, 50}, price.Closes, 1e-12)
+}
+
+func TestProvider_CustomColumnsAndDates(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"INDEX.csv": "Month,Price\n31/01/2024,100\n29/02/2024,110\n"})
+	p, err := New(Config{
+		Dir:         dir,
+		Columns:     Columns{Date: "month", Close: "price"},
+		DateFormats: []string{"02/01/2006"},
+	})
+	require.NoError(t, err)
+
+	series, err := p.FetchPrices(context.Background(), "INDEX", data.FetchOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []float64{100, 110}, series.Closes, "Files without an adjusted column use the close")
+
+	_, err = p.FetchPrices(context.Background(), "INDEX", data.FetchOptions{Frequency: data.FrequencyDaily})
+	require.ErrorContains(t, err, "has only monthly prices")
+}
+
+func TestProvider_Errors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"BAD.csv":    "date,close\n2024-01-31,abc\n",
+		"NODATE.csv": "day,close\n2024-01-31,1\n",
+		"FORMAT.csv": "date,close\n01/31/2024,1\n",
+	})
+	p, err := New(Config{Dir: dir})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = p.FetchPrices(ctx, "MISSING", data.FetchOptions{})
+	require.ErrorContains(t, err, "no price file for MISSING")
+	_, err = p.FetchPrices(ctx, "../BAD", data.FetchOptions{})
+	require.ErrorContains(t, err, "invalid ticker")
+	_, err = p.FetchPrices(ctx, "BAD", data.FetchOptions{})
+	require.ErrorContains(t, err, `line 2: invalid price "abc"`)
+	_, err = p.FetchPrices(ctx, "NODATE", data.FetchOptions{})
+	require.ErrorContains(t, err, `no "date" column`)
+	_, err = p.FetchPrices(ctx, "FORMAT", data.FetchOptions{})
+	require.ErrorContains(t, err, "matches none of the formats")
+
+	_, err = New(Config{Dir: filepath.Join(dir, "nowhere")})
+	require.Error(t, err)
+}
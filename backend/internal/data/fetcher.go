@@ -0,0 +1,90 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Frequency is the spacing of the prices in a series.
+type Frequency string
+
+const (
+	FrequencyDaily   Frequency = "daily"
+	FrequencyMonthly Frequency = "monthly" // Month-end prices; the default.
+)
+
+// PriceType selects which price a series holds.
+type PriceType string
+
+const (
+	// PriceAdjusted is the close adjusted for dividends and splits, so its changes are
+	// total returns. It is the default.
+	PriceAdjusted PriceType = "adjusted"
+	// PriceClose is the raw close, so its changes are price returns without dividends.
+	PriceClose PriceType = "close"
+)
+
+// FetchOptions selects the prices to fetch.
+type FetchOptions struct {
+	Window    Window    // Months to cover; a zero window leaves the range to the fetcher.
+	Frequency Frequency // Spacing of the prices; empty means monthly.
+	PriceType PriceType // Adjusted or raw closes; empty means adjusted.
+}
+
+// Normalized returns the options with the default frequency and price type filled in.
+func (o FetchOptions) Normalized() FetchOptions {
+	if o.Frequency == "" {
+		o.Frequency = FrequencyMonthly
+	}
+	if o.PriceType == "" {
+		o.PriceType = PriceAdjusted
+	}
+	return o
+}
+
+// Provenance records where a series came from.
+type Provenance struct {
+	Source    string    // Provider that supplied the prices (e.g., "tiingo").
+	FetchedAt time.Time // When the provider was asked for them.
+	Cached    bool      // Served from a cache rather than by the provider itself.
+}
+
+// PriceSeries is a ticker's price history with the options it was fetched with.
+type PriceSeries struct {
+	Ticker    string
+	Frequency Frequency
+	PriceType PriceType
+	Dates     []time.Time // Date of each price, ascending; nil when the provider does not date them.
+	Closes    []float64   // Price at each date.
+	Dividends []float64   // Cash dividend paid in each period; nil when unknown.
+	// SplitFactors holds the split ratio at each date (e.g., 2 for a 2-for-1 split, 1 when
+	// there is none) for raw closes; nil when there are no splits or prices are adjusted.
+	SplitFactors []float64
+	Provenance   Provenance
+}
+
+// PriceFetcher fetches the price history of a ticker.
+type PriceFetcher interface {
+	FetchPrices(ctx context.Context, ticker string, opts FetchOptions) (PriceSeries, error)
+}
+
+// MonthlyReturns converts a monthly price series to returns, each labelled with the month
+// of the price it ends at. Undated prices give undated returns.
+func (s PriceSeries) MonthlyReturns() (ReturnSeries, error) {
+	if s.Frequency != "" && s.Frequency != FrequencyMonthly {
+		return ReturnSeries{}, fmt.Errorf("data: %s prices of %s are not monthly", s.Frequency, s.Ticker)
+	}
+	prices := make([]PriceData, len(s.Closes))
+	for i, c := range s.Closes {
+		prices[i] = PriceData{Close: c}
+		if s.Dates != nil {
+			prices[i].Date = s.Dates[i]
+		}
+	}
+	if s.Dates == nil {
+		// Without dates the prices are taken in the order given.
+		return ReturnSeries{Returns: sequentialReturns(prices)}, nil
+	}
+	return ToMonthlyReturnSeries(prices), nil
+}
@@ -0,0 +1,65 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// returnsOnly serves fixed undated returns.
+type returnsOnly []float64
+
+func (r returnsOnly) GetMonthlyReturns(string, Window) ([]float64, error) { return r, nil }
+
+// datedReturns serves a fixed dated series.
+type datedReturns struct{ ReturnSeries }
+
+func (d datedReturns) GetMonthlyReturns(string, Window) ([]float64, error) { return d.Returns, nil }
+
+func (d datedReturns) GetMonthlyReturnSeries(string, Window) (ReturnSeries, error) {
+	return d.ReturnSeries, nil
+}
+
+func TestAdaptReturns(t *testing.T) {
+	ctx := context.Background()
+	prices, err := AdaptReturns(returnsOnly{0.1, -0.5}, "legacy").FetchPrices(ctx, "OLD", FetchOptions{})
+	require.NoError(t, err)
+	require.Nil(t, prices.Dates)
+	require.InDeltaSlice(t, []float64{1, 1.1, 0.55}, prices.Closes, 1e-12)
+	require.Equal(t, "legacy", prices.Provenance.Source)
+	returns, err := prices.MonthlyReturns()
+	require.NoError(t, err)
+	require.False(t, returns.Dated())
+	require.InDeltaSlice(t, []float64{0.1, -0.5}, returns.Returns, 1e-12)
+
+	months := []time.Time{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)}
+	fetcher := AdaptReturns(datedReturns{ReturnSeries{Months: months, Returns: []float64{0.1, -0.5}}}, "legacy")
+	prices, err = fetcher.FetchPrices(ctx, "OLD", FetchOptions{})
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2019, 12, 1, 0, 0, 0, 0, time.UTC), prices.Dates[0], "The index starts a month early")
+	returns, err = prices.MonthlyReturns()
+	require.NoError(t, err)
+	require.Equal(t, months, returns.Months)
+
+	prices, err = AdaptReturns(datedReturns{ReturnSeries{Months: []time.Time{}}}, "legacy").FetchPrices(ctx, "NEW", FetchOptions{})
+	require.NoError(t, err)
+	returns, err = prices.MonthlyReturns()
+	require.NoError(t, err)
+	require.True(t, returns.Dated(), "A dated history without returns stays dated")
+
+	_, err = fetcher.FetchPrices(ctx, "OLD", FetchOptions{PriceType: PriceClose})
+	require.ErrorContains(t, err, "only provides monthly total returns")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = fetcher.FetchPrices(cancelled, "OLD", FetchOptions{})
+	require.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestPriceSeries_MonthlyReturnsNeedsMonthlyPrices(t *testing.T) {
+	_, err := PriceSeries{Ticker: "SPY", Frequency: FrequencyDaily, Closes: []float64{1, 2}}.MonthlyReturns()
+	require.ErrorContains(t, err, "daily prices of SPY are not monthly")
+}
@@ -0,0 +1,167 @@
+// Package registry names the available price providers and combines them into a
+// fetcher that falls back from one to the next.
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"portfolio-simulator/backend/internal/data"
+)
+
+// Registry holds price providers by name.
+type Registry struct {
+	providers map[string]data.PriceFetcher
+	names     []string
+}
+
+// New returns an empty registry.
+func New() *Registry {
+	return &Registry{providers: make(map[string]data.PriceFetcher)}
+}
+
+// Register adds a provider under name, which must not be taken yet.
+func (r *Registry) Register(name string, f data.PriceFetcher) error {
+	if name == "" || f == nil {
+		return errors.New("registry: a provider needs a name and a fetcher")
+	}
+	if _, ok := r.providers[name]; ok {
+		return fmt.Errorf("registry: provider %q is already registered", name)
+	}
+	r.providers[name] = f
+	r.names = append(r.names, name)
+	return nil
+}
+
+// Get returns the provider registered under name.
+func (r *Registry) Get(name string) (data.PriceFetcher, bool) {
+	f, ok := r.providers[name]
+	return f, ok
+}
+
+// Names returns the registered names in the order they were registered.
+func (r *Registry) Names() []string {
+	return append([]string(nil), r.names...)
+}
+
+// Route sends the tickers matching Pattern to their own chain of providers.
+type Route struct {
+	Pattern   string   // Glob as in path.Match, e.g. "*USD" for crypto pairs.
+	Providers []string // Provider names, tried in order.
+}
+
+// Composite is a PriceFetcher that asks providers in turn until one serves the ticker.
+type Composite struct {
+	chain  []link
+	routes []route
+}
+
+type link struct {
+	name    string
+	fetcher data.PriceFetcher
+}
+
+type route struct {
+	pattern string
+	chain   []link
+}
+
+// Composite returns a fetcher that tries the providers in chain in order, except for
+// tickers matching one of routes, which use the chain of the first route they match.
+func (r *Registry) Composite(chain []string, routes []Route) (*Composite, error) {
+	c := &Composite{}
+	var err error
+	if c.chain, err = r.links(chain); err != nil {
+		return nil, err
+	}
+	for _, rt := range routes {
+		if _, err := path.Match(rt.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("registry: invalid route pattern %q: %w", rt.Pattern, err)
+		}
+		links, err := r.links(rt.Providers)
+		if err != nil {
+			return nil, err
+		}
+		c.routes = append(c.routes, route{pattern: rt.Pattern, chain: links})
+	}
+	return c, nil
+}
+
+// links resolves provider names.
+func (r *Registry) links(names []string) ([]link, error) {
+	if len(names) == 0 {
+		return nil, errors.New("registry: a chain needs at least one provider")
+	}
+	links := make([]link, len(names))
+	for i, name := range names {
+		f, ok := r.providers[name]
+		if !ok {
+			return nil, fmt.Errorf("registry: unknown provider %q; registered are %q", name, r.names)
+		}
+		links[i] = link{name: name, fetcher: f}
+	}
+	return links, nil
+}
+
+// chainFor returns the providers to try for ticker.
+func (c *Composite) chainFor(ticker string) []link {
+	for _, rt := range c.routes {
+		if ok, _ := path.Match(rt.pattern, ticker); ok {
+			return rt.chain
+		}
+	}
+	return c.chain
+}
+
+// FetchPrices returns the series of the first provider that serves the ticker, with
+// Provenance.Source set to that provider's registered name. If none does, the error
+// joins every provider's error. A cancelled context stops the chain.
+func (c *Composite) FetchPrices(ctx context.Context, ticker string, opts data.FetchOptions) (data.PriceSeries, error) {
+	var errs []error
+	for _, l := range c.chainFor(ticker) {
+		series, err := l.fetcher.FetchPrices(ctx, ticker, opts)
+		if err == nil {
+			series.Provenance.Source = l.name
+			return series, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return data.PriceSeries{}, ctxErr
+		}
+		log.Printf("registry: %s could not serve %s: %v", l.name, ticker, err)
+		errs = append(errs, fmt.Errorf("%s: %w", l.name, err))
+	}
+	return data.PriceSeries{}, fmt.Errorf("registry: no provider served %s: %w", ticker, errors.Join(errs...))
+}
+
+// ParseRoutes reads routes written as "pattern=provider,provider;pattern=provider",
+// e.g. "*USD=tiingo;BTC*=csv,tiingo". Blank entries are skipped.
+func ParseRoutes(spec string) ([]Route, error) {
+	var routes []Route
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pattern, providers, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("registry: route %q is not pattern=providers", entry)
+		}
+		routes = append(routes, Route{Pattern: strings.TrimSpace(pattern), Providers: ParseChain(providers)})
+	}
+	return routes, nil
+}
+
+// ParseChain splits a comma-separated list of provider names.
+func ParseChain(spec string) []string {
+	var names []string
+	for _, name := range strings.Split(spec, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,106 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"portfolio-simulator/backend/internal/data"
+)
+
+// stubFetcher serves the tickers it has and fails for the rest.
+type stubFetcher struct {
+	closes map[string][]float64
+	calls  []string
+}
+
+func (s *stubFetcher) FetchPrices(_ context.Context, ticker string, _ data.FetchOptions) (data.PriceSeries, error) {
+	s.calls = append(s.calls, ticker)
+	closes, ok := s.closes[ticker]
+	if !ok {
+		return data.PriceSeries{}, errors.New("unknown ticker")
+	}
+	return data.PriceSeries{Ticker: ticker, Closes: closes, Provenance: data.Provenance{Source: "stub"}}, nil
+}
+
+func TestComposite_FallsBack(t *testing.T) {
+	local := &stubFetcher{closes: map[string][]float64{"FUND": {1, 2}}}
+	remote := &stubFetcher{closes: map[string][]float64{"FUND": {3, 4}, "SPY": {5, 6}}}
+	r := New()
+	require.NoError(t, r.Register("csv", local))
+	require.NoError(t, r.Register("tiingo", remote))
+	c, err := r.Composite([]string{"csv", "tiingo"}, nil)
+	require.NoError(t, err)
+
+	series, err := c.FetchPrices(context.Background(), "FUND", data.FetchOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []float64{1, 2}, series.Closes)
+	require.Equal(t, "csv", series.Provenance.Source)
+	require.Empty(t, remote.calls, "Later providers are not asked once one serves the ticker")
+
+	series, err = c.FetchPrices(context.Background(), "SPY", data.FetchOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "tiingo", series.Provenance.Source)
+
+	_, err = c.FetchPrices(context.Background(), "NONE", data.FetchOptions{})
+	require.ErrorContains(t, err, "no provider served NONE")
+	require.ErrorContains(t, err, "csv: unknown ticker")
+	require.ErrorContains(t, err, "tiingo: unknown ticker")
+}
+
+func TestComposite_Routes(t *testing.T) {
+	stocks := &stubFetcher{closes: map[string][]float64{"BTCUSD": {1}}}
+	crypto := &stubFetcher{closes: map[string][]float64{"BTCUSD": {2}}}
+	r := New()
+	require.NoError(t, r.Register("stocks", stocks))
+	require.NoError(t, r.Register("crypto", crypto))
+	c, err := r.Composite([]string{"stocks"}, []Route{{Pattern: "*USD", Providers: []string{"crypto"}}})
+	require.NoError(t, err)
+
+	series, err := c.FetchPrices(context.Background(), "BTCUSD", data.FetchOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "crypto", series.Provenance.Source)
+	require.Empty(t, stocks.calls)
+}
+
+func TestComposite_CancelledContextStopsChain(t *testing.T) {
+	first, second := &stubFetcher{}, &stubFetcher{}
+	r := New()
+	require.NoError(t, r.Register("first", first))
+	require.NoError(t, r.Register("second", second))
+	c, err := r.Composite([]string{"first", "second"}, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.FetchPrices(ctx, "SPY", data.FetchOptions{})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Empty(t, second.calls)
+}
+
+func TestRegistry_Errors(t *testing.T) {
+	r := New()
+	require.NoError(t, r.Register("csv", &stubFetcher{}))
+	require.ErrorContains(t, r.Register("csv", &stubFetcher{}), "already registered")
+	require.Equal(t, []string{"csv"}, r.Names())
+
+	_, err := r.Composite([]string{"tiingo"}, nil)
+	require.ErrorContains(t, err, `unknown provider "tiingo"`)
+	_, err = r.Composite(nil, nil)
+	require.ErrorContains(t, err, "at least one provider")
+	_, err = r.Composite([]string{"csv"}, []Route{{Pattern: "[", Providers: []string{"csv"}}})
+	require.ErrorContains(t, err, "invalid route pattern")
+}
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes(" *USD=tiingo ; BTC*=csv, tiingo;")
+	require.NoError(t, err)
+	require.Equal(t, []Route{
+		{Pattern: "*USD", Providers: []string{"tiingo"}},
+		{Pattern: "BTC*", Providers: []string{"csv", "tiingo"}},
+	}, routes)
+
+	_, err = ParseRoutes("*USD")
+	require.ErrorContains(t, err, "not pattern=providers")
+}
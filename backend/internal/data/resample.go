@@ -0,0 +1,111 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Additional frequencies produced by Resample.
+const (
+	FrequencyQuarterly Frequency = "quarterly"
+	FrequencyYearly    Frequency = "yearly"
+)
+
+// periodOf returns the first day of the period of t at frequency f.
+func periodOf(t time.Time, f Frequency) time.Time {
+	month := MonthOf(t)
+	switch f {
+	case FrequencyQuarterly:
+		return month.AddDate(0, -int(month.Month()-1)%3, 0)
+	case FrequencyYearly:
+		return month.AddDate(0, -int(month.Month()-1), 0)
+	case FrequencyDaily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	default:
+		return month
+	}
+}
+
+// Resample turns a daily series into one price per month, quarter or year, taken at the
+// last date of each period; dividends are added up over the period. Resampling to daily
+// keeps every date and only converts the prices.
+//
+// The price type of the result picks the variant. PriceClose gives a price-return
+// series: the raw closes, chained across stock splits so a split is not a loss.
+// PriceAdjusted gives a total-return series, in which each dividend is reinvested at the
+// close of its ex-date. A series that is already adjusted keeps its prices, as its
+// provider has reinvested the dividends. Both variants start at the first close.
+func Resample(s PriceSeries, to Frequency, priceType PriceType) (PriceSeries, error) {
+	switch to {
+	case FrequencyDaily, FrequencyMonthly, FrequencyQuarterly, FrequencyYearly:
+	default:
+		return PriceSeries{}, fmt.Errorf("data: cannot resample to %q", to)
+	}
+	if s.Dates == nil {
+		return PriceSeries{}, errors.New("data: undated prices cannot be resampled")
+	}
+	if s.PriceType == "" {
+		s.PriceType = PriceAdjusted
+	}
+	if priceType == "" {
+		priceType = PriceAdjusted
+	}
+	if s.PriceType == PriceAdjusted && priceType == PriceClose {
+		return PriceSeries{}, fmt.Errorf("data: price returns of %s cannot be recovered from adjusted prices", s.Ticker)
+	}
+
+	index := s.Closes
+	if s.PriceType == PriceClose {
+		index = chain(s, priceType == PriceAdjusted)
+	}
+
+	out := PriceSeries{
+		Ticker:     s.Ticker,
+		Frequency:  to,
+		PriceType:  priceType,
+		Dates:      []time.Time{},
+		Provenance: s.Provenance,
+	}
+	for i, date := range s.Dates {
+		dividend := 0.0
+		if s.Dividends != nil {
+			dividend = s.Dividends[i]
+		}
+		if n := len(out.Dates); n > 0 && periodOf(out.Dates[n-1], to).Equal(periodOf(date, to)) {
+			out.Dates[n-1] = date
+			out.Closes[n-1] = index[i]
+			out.Dividends[n-1] += dividend
+			continue
+		}
+		out.Dates = append(out.Dates, date)
+		out.Closes = append(out.Closes, index[i])
+		out.Dividends = append(out.Dividends, dividend)
+	}
+	return out, nil
+}
+
+// chain builds an index from raw closes that carries across splits and, with
+// reinvest, reinvests dividends. Dates must be ascending.
+func chain(s PriceSeries, reinvest bool) []float64 {
+	index := make([]float64, len(s.Closes))
+	if len(index) == 0 {
+		return index
+	}
+	index[0] = s.Closes[0]
+	for i := 1; i < len(index); i++ {
+		if s.Closes[i-1] == 0 {
+			index[i] = index[i-1] // A zero close has no return to carry.
+			continue
+		}
+		value := s.Closes[i]
+		if s.SplitFactors != nil && s.SplitFactors[i] > 0 {
+			value *= s.SplitFactors[i]
+		}
+		if reinvest && s.Dividends != nil {
+			value += s.Dividends[i]
+		}
+		index[i] = index[i-1] * value / s.Closes[i-1]
+	}
+	return index
+}
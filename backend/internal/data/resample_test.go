@@ -0,0 +1,70 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func day(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+// dailyBars is a raw-close series with a dividend in January and a 2-for-1 split in April.
+func dailyBars() PriceSeries {
+	return PriceSeries{
+		Ticker:       "DIV",
+		Frequency:    FrequencyDaily,
+		PriceType:    PriceClose,
+		Dates:        []time.Time{day(2023, 12, 29), day(2024, 1, 15), day(2024, 1, 31), day(2024, 3, 28), day(2024, 4, 1), day(2024, 4, 30)},
+		Closes:       []float64{100, 98, 100, 110, 55, 56},
+		Dividends:    []float64{0, 2, 0, 0, 0, 0},
+		SplitFactors: []float64{1, 1, 1, 1, 2, 1},
+	}
+}
+
+func TestResample_PriceReturn(t *testing.T) {
+	monthly, err := Resample(dailyBars(), FrequencyMonthly, PriceClose)
+	require.NoError(t, err)
+	require.Equal(t, []time.Time{day(2023, 12, 29), day(2024, 1, 31), day(2024, 3, 28), day(2024, 4, 30)}, monthly.Dates)
+	// The split halves the close but not the index.
+	require.InDeltaSlice(t, []float64{100, 100, 110, 112}, monthly.Closes, 1e-9)
+	require.Equal(t, []float64{0, 2, 0, 0}, monthly.Dividends)
+	require.Equal(t, FrequencyMonthly, monthly.Frequency)
+}
+
+func TestResample_TotalReturn(t *testing.T) {
+	monthly, err := Resample(dailyBars(), FrequencyMonthly, PriceAdjusted)
+	require.NoError(t, err)
+	// The $2 dividend on the 15th is reinvested at 98: 100 * (98+2)/100 * 100/98.
+	require.InDelta(t, 100*100.0/98, monthly.Closes[1], 1e-9)
+	returns, err := monthly.MonthlyReturns()
+	require.NoError(t, err)
+	require.InDelta(t, 100.0/98-1, returns.Returns[0], 1e-12, "January's total return includes the dividend")
+	require.InDelta(t, 112.0/110-1, returns.Returns[2], 1e-12)
+}
+
+func TestResample_QuarterAndYear(t *testing.T) {
+	quarterly, err := Resample(dailyBars(), FrequencyQuarterly, PriceClose)
+	require.NoError(t, err)
+	require.Equal(t, []time.Time{day(2023, 12, 29), day(2024, 3, 28), day(2024, 4, 30)}, quarterly.Dates)
+	require.Equal(t, []float64{0, 2, 0}, quarterly.Dividends)
+
+	yearly, err := Resample(dailyBars(), FrequencyYearly, PriceClose)
+	require.NoError(t, err)
+	require.Equal(t, []time.Time{day(2023, 12, 29), day(2024, 4, 30)}, yearly.Dates)
+
+	_, err = yearly.MonthlyReturns()
+	require.ErrorContains(t, err, "not monthly")
+}
+
+func TestResample_AdjustedInput(t *testing.T) {
+	adjusted := PriceSeries{Ticker: "ADJ", PriceType: PriceAdjusted, Dates: []time.Time{day(2024, 1, 2), day(2024, 1, 31)}, Closes: []float64{10, 11}}
+	monthly, err := Resample(adjusted, FrequencyMonthly, PriceAdjusted)
+	require.NoError(t, err)
+	require.Equal(t, []float64{11}, monthly.Closes, "Adjusted prices are taken as they are")
+
+	_, err = Resample(adjusted, FrequencyMonthly, PriceClose)
+	require.ErrorContains(t, err, "cannot be recovered")
+	_, err = Resample(PriceSeries{Closes: []float64{1}}, FrequencyMonthly, PriceClose)
+	require.ErrorContains(t, err, "undated")
+}
@@ -0,0 +1,85 @@
+package data
+
+import "time"
+
+// ReturnSeries holds monthly returns labelled by the calendar month they were earned in.
+type ReturnSeries struct {
+	Months  []time.Time // First day of each return's month in UTC, ascending; nil when undated.
+	Returns []float64   // Return for each month.
+}
+
+// Dated reports whether the returns are labelled with their months.
+func (s ReturnSeries) Dated() bool {
+	return s.Months != nil
+}
+
+// Window is a range of calendar months, inclusive at both ends. A zero Start or End
+// leaves that side open.
+type Window struct {
+	Start time.Time // First month; zero for no lower bound.
+	End   time.Time // Last month; zero for no upper bound.
+}
+
+// Contains reports whether the month of t falls within the window.
+func (w Window) Contains(t time.Time) bool {
+	month := MonthOf(t)
+	if !w.Start.IsZero() && month.Before(MonthOf(w.Start)) {
+		return false
+	}
+	return w.End.IsZero() || !month.After(MonthOf(w.End))
+}
+
+// Within returns the returns of the months inside w. Undated series are returned as is.
+func (s ReturnSeries) Within(w Window) ReturnSeries {
+	return s.filter(w.Contains)
+}
+
+// Excluding returns the returns of the months outside every one of the windows.
+// Undated series are returned as is.
+func (s ReturnSeries) Excluding(windows []Window) ReturnSeries {
+	return s.filter(func(t time.Time) bool {
+		for _, w := range windows {
+			if w.Contains(t) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// filter keeps the months for which keep is true.
+func (s ReturnSeries) filter(keep func(time.Time) bool) ReturnSeries {
+	if !s.Dated() {
+		return s
+	}
+	filtered := ReturnSeries{Months: []time.Time{}}
+	for i, month := range s.Months {
+		if keep(month) {
+			filtered.Months = append(filtered.Months, month)
+			filtered.Returns = append(filtered.Returns, s.Returns[i])
+		}
+	}
+	return filtered
+}
+
+// MonthOf returns the first day of t's calendar month in UTC.
+func MonthOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// ToMonthlyReturnSeries calculates sequential percentage returns from period-end prices,
+// labelling each return with the month of the price it ends at. Prices are sorted by
+// date first, as ToMonthlyReturns does. Fewer than two prices give a dated series
+// without returns, such as the history of a fund launched this month.
+func ToMonthlyReturnSeries(prices []PriceData) ReturnSeries {
+	returns := ToMonthlyReturns(prices)
+	if returns == nil {
+		return ReturnSeries{Months: []time.Time{}}
+	}
+	months := make([]time.Time, len(returns))
+	for i := range returns {
+		months[i] = MonthOf(prices[i+1].Date)
+	}
+	return ReturnSeries{Months: months, Returns: returns}
+}
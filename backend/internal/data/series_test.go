@@ -0,0 +1,50 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToMonthlyReturnSeries(t *testing.T) {
+	prices := []PriceData{
+		{Date: time.Date(2023, 2, 28, 0, 0, 0, 0, time.UTC), Close: 110},
+		{Date: time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC), Close: 100},
+		{Date: time.Date(2023, 3, 31, 0, 0, 0, 0, time.UTC), Close: 99},
+	}
+	series := ToMonthlyReturnSeries(prices)
+	require.True(t, series.Dated())
+	require.Equal(t, []time.Time{
+		time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC),
+	}, series.Months)
+	require.InDeltaSlice(t, []float64{0.1, -0.1}, series.Returns, 1e-12)
+
+	single := ToMonthlyReturnSeries(prices[:1])
+	require.True(t, single.Dated(), "One price gives no returns, but they are still dated")
+	require.Empty(t, single.Returns)
+}
+
+func TestMonthOf(t *testing.T) {
+	local := time.FixedZone("UTC+3", 3*60*60)
+	require.Equal(t, time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC), MonthOf(time.Date(2023, 1, 1, 1, 0, 0, 0, local)))
+}
+
+func TestReturnSeries_WithinExcluding(t *testing.T) {
+	month := func(y int, m time.Month) time.Time { return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC) }
+	series := ReturnSeries{
+		Months:  []time.Time{month(2019, 12), month(2020, 1), month(2020, 2), month(2020, 3)},
+		Returns: []float64{0.1, 0.2, 0.3, 0.4},
+	}
+
+	within := series.Within(Window{Start: month(2020, 1)})
+	require.Equal(t, []float64{0.2, 0.3, 0.4}, within.Returns)
+
+	excluded := series.Excluding([]Window{{Start: month(2020, 1), End: time.Date(2020, 2, 15, 0, 0, 0, 0, time.UTC)}})
+	require.Equal(t, []time.Time{month(2019, 12), month(2020, 3)}, excluded.Months)
+	require.Equal(t, []float64{0.1, 0.4}, excluded.Returns)
+
+	undated := ReturnSeries{Returns: []float64{0.1}}
+	require.Equal(t, undated, undated.Excluding([]Window{{End: month(2030, 1)}}))
+}
@@ -0,0 +1,86 @@
+package tiingo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Errors an APIError can be matched against with errors.Is.
+var (
+	ErrNotFound     = errors.New("tiingo: ticker not found")
+	ErrUnauthorized = errors.New("tiingo: API key rejected")
+	ErrRateLimited  = errors.New("tiingo: rate limited")
+)
+
+// APIError is a non-200 response from Tiingo, with the detail from its error body.
+type APIError struct {
+	Ticker     string
+	StatusCode int
+	Detail     string        // Tiingo's "detail" message, or the start of the body.
+	RetryAfter time.Duration // From the Retry-After header; zero when absent.
+}
+
+func (e *APIError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("tiingo: status %d for %s", e.StatusCode, e.Ticker)
+	}
+	return fmt.Sprintf("tiingo: status %d for %s: %s", e.StatusCode, e.Ticker, e.Detail)
+}
+
+// Unwrap lets errors.Is match the error against ErrNotFound, ErrUnauthorized and
+// ErrRateLimited.
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// retryable reports whether the request may succeed if sent again.
+func (e *APIError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// maxErrorBody caps how much of an error response is read.
+const maxErrorBody = 4 << 10
+
+// newAPIError builds an APIError from a response body such as {"detail": "Not found."}.
+func newAPIError(ticker string, resp *http.Response, body []byte, now time.Time) *APIError {
+	e := &APIError{Ticker: ticker, StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp.Header.Get("Retry-After"), now)}
+	var parsed struct {
+		Detail string `json:"detail"`
+	}
+	if err := json.Unmarshal(body, &parsed); err == nil && parsed.Detail != "" {
+		e.Detail = parsed.Detail
+	} else {
+		e.Detail = strings.TrimSpace(string(body))
+		if len(e.Detail) > 200 {
+			e.Detail = e.Detail[:200] + "..."
+		}
+	}
+	return e
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	var seconds int
+	if _, err := fmt.Sscanf(header, "%d", &seconds); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
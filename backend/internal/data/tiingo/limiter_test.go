@@ -0,0 +1,27 @@
+package tiingo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_SpacesRequestsAfterBurst(t *testing.T) {
+	l := NewLimiter(50*3600, 2) // 50 a second, i.e. one every 20ms.
+	start := time.Now()
+	for range 4 {
+		require.NoError(t, l.Wait(context.Background()))
+	}
+	require.GreaterOrEqual(t, time.Since(start), 35*time.Millisecond, "Two requests pass at once, the others wait for tokens")
+}
+
+func TestLimiter_StopsWaitingWhenCancelled(t *testing.T) {
+	l := NewLimiter(1, 1)
+	require.NoError(t, l.Wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, l.Wait(ctx), context.DeadlineExceeded)
+}
@@ -1,58 +1,216 @@
 package tiingo
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"time"
 
 	"portfolio-simulator/backend/internal/data" // Adjust import path as per your module structure
 )
 
+// requestTimeout bounds a single Tiingo request, so a stalled connection cannot hang
+// callers that pass a context without a deadline.
+const requestTimeout = 30 * time.Second
+
 // Service fetches historical price data from the Tiingo API.
 type Service struct {
-	APIKey string
-	Client *http.Client
+	APIKey  string
+	Client  *http.Client
+	Limiter *Limiter    // Spaces requests out to the plan's limits; nil does not wait.
+	Retry   RetryPolicy // Zero sends each request once.
 }
 
 // NewService creates a new Tiingo Service instance.
-// It reads the TIINGO_API_KEY from environment variables.
+// It reads the TIINGO_API_KEY from environment variables, and TIINGO_REQUESTS_PER_HOUR
+// for the rate limit. Rate-limited and failed requests are retried.
 func NewService() *Service {
 	apiKey := os.Getenv("TIINGO_API_KEY")
 	if apiKey == "" {
 		log.Println("Warning: TIINGO_API_KEY environment variable not set. Tiingo API calls may fail.")
 	}
 	return &Service{
-		APIKey: apiKey,
-		Client: http.DefaultClient,
+		APIKey:  apiKey,
+		Client:  &http.Client{Timeout: requestTimeout},
+		Limiter: newPlanLimiter(),
+		Retry:   DefaultRetryPolicy,
 	}
 }
 
-// GetMonthlyReturns fetches and calculates monthly percentage returns for a given ticker.
-func (s *Service) GetMonthlyReturns(ticker string) ([]float64, error) {
-	prices, err := s.GetMonthlyPrices(ticker)
+// defaultRequestsPerHour is the hourly request limit of Tiingo's free plan.
+const defaultRequestsPerHour = 50
+
+// newPlanLimiter returns a limiter for the hourly limit in TIINGO_REQUESTS_PER_HOUR
+// (default: the free plan's), or nil when it is "off". A whole hour's requests may be
+// sent at once, as Tiingo counts them per hour.
+func newPlanLimiter() *Limiter {
+	perHour := defaultRequestsPerHour
+	if raw := os.Getenv("TIINGO_REQUESTS_PER_HOUR"); raw == "off" {
+		return nil
+	} else if raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			log.Printf("Warning: invalid TIINGO_REQUESTS_PER_HOUR %q, using %d", raw, defaultRequestsPerHour)
+		} else {
+			perHour = parsed
+		}
+	}
+	return NewLimiter(perHour, perHour)
+}
+
+// GetMonthlyReturns fetches and calculates monthly percentage returns for a given ticker
+// over the months in window.
+func (s *Service) GetMonthlyReturns(ticker string, window data.Window) ([]float64, error) {
+	series, err := s.GetMonthlyReturnSeries(ticker, window)
 	if err != nil {
-		return nil, fmt.Errorf("tiingo: GetMonthlyPrices for %s failed: %w", ticker, err)
+		return nil, err
 	}
-	return data.ToMonthlyReturns(prices), nil
+	return series.Returns, nil
 }
 
-// GetMonthlyPrices fetches historical monthly closing prices for a ticker from Tiingo.
-// Data is resampled to monthly frequency by the Tiingo API.
-func (s *Service) GetMonthlyPrices(ticker string) ([]data.PriceData, error) {
+// GetMonthlyReturnSeries fetches monthly percentage returns for a ticker over the months
+// in window, labelled with the month each return ends in.
+func (s *Service) GetMonthlyReturnSeries(ticker string, window data.Window) (data.ReturnSeries, error) {
+	prices, err := s.GetMonthlyPrices(ticker, window)
+	if err != nil {
+		return data.ReturnSeries{}, fmt.Errorf("tiingo: GetMonthlyPrices for %s failed: %w", ticker, err)
+	}
+	return data.ToMonthlyReturnSeries(prices).Within(window), nil
+}
+
+// GetMonthlyPrices fetches historical monthly adjusted closing prices for a ticker from
+// Tiingo. It is FetchPrices without a context or options beyond the window.
+func (s *Service) GetMonthlyPrices(ticker string, window data.Window) ([]data.PriceData, error) {
+	series, err := s.FetchPrices(context.Background(), ticker, data.FetchOptions{Window: window})
+	if err != nil {
+		return nil, err
+	}
+	prices := make([]data.PriceData, len(series.Closes))
+	for i, c := range series.Closes {
+		prices[i] = data.PriceData{Date: series.Dates[i], Close: c}
+	}
+	return prices, nil
+}
+
+// FetchPrices fetches a ticker's daily bars from Tiingo and converts them locally with
+// data.Resample, so month ends, dividends and splits are treated as for every other
+// provider. The prices start a month before the window so that its first month has a
+// return; an open start defaults to 2000 and an open end to today.
+func (s *Service) FetchPrices(ctx context.Context, ticker string, opts data.FetchOptions) (data.PriceSeries, error) {
 	if s.APIKey == "" {
-		return nil, fmt.Errorf("tiingo: API key is not configured")
+		return data.PriceSeries{}, fmt.Errorf("tiingo: API key is not configured")
+	}
+	if err := ctx.Err(); err != nil {
+		return data.PriceSeries{}, fmt.Errorf("tiingo: request for %s cancelled: %w", ticker, err)
 	}
+	opts = opts.Normalized()
+	window := opts.Window
 
 	startDate := "2000-01-01"
+	if !window.Start.IsZero() {
+		startDate = data.MonthOf(window.Start).AddDate(0, -1, 0).Format("2006-01-02")
+	}
 	endDate := time.Now().Format("2006-01-02")
+	if !window.End.IsZero() {
+		endDate = data.MonthOf(window.End).AddDate(0, 1, -1).Format("2006-01-02")
+	}
+
+	query := url.Values{}
+	query.Set("startDate", startDate)
+	query.Set("endDate", endDate)
+	query.Set("token", s.APIKey)
+	endpoint := fmt.Sprintf("https://api.tiingo.com/tiingo/daily/%s/prices?%s", url.PathEscape(ticker), query.Encode())
+
+	rawTiingoPrices, fetchedAt, err := s.get(ctx, ticker, endpoint)
+	if err != nil {
+		return data.PriceSeries{}, err
+	}
+	if len(rawTiingoPrices) == 0 {
+		return data.PriceSeries{}, fmt.Errorf("tiingo: no price data returned for %s (period: %s to %s)", ticker, startDate, endDate)
+	}
+
+	bars := data.PriceSeries{
+		Ticker:     ticker,
+		Frequency:  data.FrequencyDaily,
+		PriceType:  data.PriceClose,
+		Dates:      []time.Time{},
+		Provenance: data.Provenance{Source: "tiingo", FetchedAt: fetchedAt},
+	}
+	for _, rawPrice := range rawTiingoPrices {
+		parsedDate, err := time.Parse(time.RFC3339, rawPrice.Date)
+		if err != nil {
+			log.Printf("tiingo: skipping price record for %s due to unparseable date '%s': %v", ticker, rawPrice.Date, err)
+			continue
+		}
+		split := rawPrice.SplitFactor
+		if split == 0 {
+			split = 1 // Omitted when there is no split.
+		}
+		bars.Dates = append(bars.Dates, parsedDate)
+		bars.Closes = append(bars.Closes, rawPrice.Close)
+		bars.Dividends = append(bars.Dividends, rawPrice.DivCash)
+		bars.SplitFactors = append(bars.SplitFactors, split)
+	}
+	series, err := data.Resample(bars, opts.Frequency, opts.PriceType)
+	if err != nil {
+		return data.PriceSeries{}, fmt.Errorf("tiingo: %w", err)
+	}
+	return series, nil
+}
 
-	url := fmt.Sprintf("https://api.tiingo.com/tiingo/daily/%s/prices?startDate=%s&endDate=%s&resampleFreq=monthly&token=%s",
-		ticker, startDate, endDate, s.APIKey)
+// dailyBar is one daily bar as Tiingo returns it.
+type dailyBar struct {
+	Date        string  `json:"date"`
+	Close       float64 `json:"close"`
+	DivCash     float64 `json:"divCash"`
+	SplitFactor float64 `json:"splitFactor"`
+}
 
-	resp, err := s.Client.Get(url)
+// get requests endpoint, waiting for the limiter first and retrying as s.Retry allows.
+// It returns the bars and when the successful request was sent.
+func (s *Service) get(ctx context.Context, ticker, endpoint string) ([]dailyBar, time.Time, error) {
+	for attempt := 0; ; attempt++ {
+		if s.Limiter != nil {
+			if err := s.Limiter.Wait(ctx); err != nil {
+				return nil, time.Time{}, fmt.Errorf("tiingo: waiting to request %s: %w", ticker, err)
+			}
+		}
+		fetchedAt := time.Now()
+		prices, err := s.send(ctx, ticker, endpoint)
+		if err == nil {
+			return prices, fetchedAt, nil
+		}
+		delay, ok := s.Retry.delay(attempt, err)
+		if !ok || ctx.Err() != nil {
+			return nil, time.Time{}, err
+		}
+		log.Printf("tiingo: retrying %s in %s: %v", ticker, delay.Round(time.Millisecond), err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, time.Time{}, fmt.Errorf("tiingo: request for %s cancelled: %w", ticker, ctx.Err())
+		}
+	}
+}
+
+// send makes a single request for endpoint. Non-200 responses become an *APIError.
+func (s *Service) send(ctx context.Context, ticker, endpoint string) ([]dailyBar, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("tiingo: building request for %s failed: %w", ticker, err)
+	}
+	resp, err := s.Client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("tiingo: request for %s failed: %w", ticker, err)
 	}
@@ -64,34 +222,58 @@ func (s *Service) GetMonthlyPrices(ticker string) ([]data.PriceData, error) {
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		// TODO: Attempt to read and log the error message from Tiingo's response body.
-		return nil, fmt.Errorf("tiingo: unexpected status code %d for %s", resp.StatusCode, ticker)
-	}
-
-	var rawTiingoPrices []struct {
-		Date     string  `json:"date"`
-		AdjClose float64 `json:"adjClose"`
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, newAPIError(ticker, resp, body, time.Now())
 	}
 
-	if err = json.NewDecoder(resp.Body).Decode(&rawTiingoPrices); err != nil {
+	var prices []dailyBar
+	if err = json.NewDecoder(resp.Body).Decode(&prices); err != nil {
 		return nil, fmt.Errorf("tiingo: failed to decode JSON response for %s: %w", ticker, err)
 	}
+	return prices, nil
+}
 
-	if len(rawTiingoPrices) == 0 {
-		return nil, fmt.Errorf("tiingo: no price data returned for %s (period: %s to %s)", ticker, startDate, endDate)
-	}
+// RetryPolicy controls how rate-limited (429) and failed (5xx or network) requests are
+// sent again.
+type RetryPolicy struct {
+	Retries   int           // Attempts after the first; zero sends each request once.
+	BaseDelay time.Duration // Wait before the first retry, doubling after each one.
+	MaxDelay  time.Duration // Longest single wait. A longer Retry-After gives up instead.
+}
 
-	var prices []data.PriceData
-	for _, rawPrice := range rawTiingoPrices {
-		parsedDate, err := time.Parse(time.RFC3339, rawPrice.Date)
-		if err != nil {
-			log.Printf("tiingo: skipping price record for %s due to unparseable date '%s': %v", ticker, rawPrice.Date, err)
-			continue
+// DefaultRetryPolicy is the policy NewService uses.
+var DefaultRetryPolicy = RetryPolicy{Retries: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+
+// delay returns how long to wait before retrying after err on the given attempt, and
+// false if the request should not be retried. Backoff delays are jittered between half
+// and all of the exponential delay; a server's Retry-After is used as given.
+func (p RetryPolicy) delay(attempt int, err error) (time.Duration, bool) {
+	if attempt >= p.Retries {
+		return 0, false
+	}
+	var apiErr *APIError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &apiErr):
+		if !apiErr.retryable() {
+			return 0, false
 		}
-		prices = append(prices, data.PriceData{
-			Date:  parsedDate,
-			Close: rawPrice.AdjClose,
-		})
+		if apiErr.RetryAfter > 0 {
+			if p.MaxDelay > 0 && apiErr.RetryAfter > p.MaxDelay {
+				return 0, false
+			}
+			return apiErr.RetryAfter, true
+		}
+	case errors.As(err, &netErr):
+	default:
+		return 0, false // E.g. a malformed response, which a retry would not fix.
 	}
-	return prices, nil
+	d := p.BaseDelay << attempt
+	if p.MaxDelay > 0 && (d > p.MaxDelay || d <= 0) {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0, true
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1)), true
 }
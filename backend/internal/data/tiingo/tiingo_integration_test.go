@@ -7,6 +7,7 @@ import (
 	"os"
 	"testing"
 
+	"portfolio-simulator/backend/internal/data"
 	"portfolio-simulator/backend/internal/data/tiingo" // Import the package to be tested
 
 	"github.com/stretchr/testify/require"
@@ -58,7 +59,7 @@ func getReturns(t *testing.T, ticker string) []float64 {
 	}
 
 	t.Logf("Fetching live Tiingo data for ticker: %s", ticker)
-	returns, err := testService.GetMonthlyReturns(ticker)
+	returns, err := testService.GetMonthlyReturns(ticker, data.Window{})
 	require.NoError(t, err, "Fetching returns for %s should not produce an error", ticker)
 
 	// It's good to have some data, but exact length can vary.
@@ -99,7 +100,7 @@ func TestGetMonthlyReturns_InvalidTicker(t *testing.T) {
 
 	invalidTicker := "THISISNOTAVALIDTICKERXYZ"
 	t.Logf("Fetching live Tiingo data for invalid ticker: %s", invalidTicker)
-	_, err := testService.GetMonthlyReturns(invalidTicker)
+	_, err := testService.GetMonthlyReturns(invalidTicker, data.Window{})
 
 	// Tiingo should return an error for an invalid ticker.
 	// The exact error message might vary, so checking for a non-nil error is a good start.
@@ -2,11 +2,15 @@ package tiingo
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"portfolio-simulator/backend/internal/data"
 )
 
 // mockClient returns a fake HTTP client with canned response.
@@ -32,13 +36,13 @@ func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
 func TestGetMonthlyReturns_Mocked(t *testing.T) {
 	mockJSON := `[{
 		"date": "2023-01-31T00:00:00.000Z",
-		"adjClose": 100.0
+		"close": 100.0
 	}, {
 		"date": "2023-02-28T00:00:00.000Z",
-		"adjClose": 110.0
+		"close": 110.0
 	}, {
 		"date": "2023-03-31T00:00:00.000Z",
-		"adjClose": 121.0
+		"close": 121.0
 	}]`
 
 	service := &Service{
@@ -46,7 +50,7 @@ func TestGetMonthlyReturns_Mocked(t *testing.T) {
 		Client: mockClient(http.StatusOK, mockJSON),
 	}
 
-	returns, err := service.GetMonthlyReturns("MOCK")
+	returns, err := service.GetMonthlyReturns("MOCK", data.Window{})
 	require.NoError(t, err)
 	require.Len(t, returns, 2)
 	if len(returns) == 2 {
@@ -55,17 +59,194 @@ func TestGetMonthlyReturns_Mocked(t *testing.T) {
 	}
 }
 
+func TestGetMonthlyReturnSeries_Window(t *testing.T) {
+	mockJSON := `[{"date": "2019-12-31T00:00:00.000Z", "close": 100.0},
+		{"date": "2020-01-31T00:00:00.000Z", "close": 110.0},
+		{"date": "2020-02-29T00:00:00.000Z", "close": 99.0},
+		{"date": "2020-03-31T00:00:00.000Z", "close": 198.0}]`
+	var requested url.Values
+	client := mockClient(http.StatusOK, mockJSON)
+	transport := client.Transport
+	client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		requested = r.URL.Query()
+		return transport.RoundTrip(r)
+	})
+	service := &Service{APIKey: "mock_api_key", Client: client}
+
+	window := data.Window{
+		Start: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2020, time.February, 1, 0, 0, 0, 0, time.UTC),
+	}
+	series, err := service.GetMonthlyReturnSeries("MOCK", window)
+	require.NoError(t, err)
+	// December's price is fetched so January has a return; March falls outside the window.
+	require.Equal(t, "2019-12-01", requested.Get("startDate"))
+	require.Equal(t, "2020-02-29", requested.Get("endDate"))
+	require.Equal(t, []time.Time{window.Start, window.End}, series.Months)
+	require.InDeltaSlice(t, []float64{0.10, -0.10}, series.Returns, 1e-12)
+}
+
+func TestFetchPrices_ResamplesDailyBars(t *testing.T) {
+	mockJSON := `[{"date": "2024-01-30T00:00:00.000Z", "close": 100.0, "divCash": 0.0, "splitFactor": 1.0},
+		{"date": "2024-01-31T00:00:00.000Z", "close": 99.0, "divCash": 1.0, "splitFactor": 1.0},
+		{"date": "2024-02-28T00:00:00.000Z", "close": 101.0, "divCash": 0.0, "splitFactor": 1.0},
+		{"date": "2024-02-29T00:00:00.000Z", "close": 52.0, "divCash": 0.0, "splitFactor": 2.0}]`
+	var requested url.Values
+	client := mockClient(http.StatusOK, mockJSON)
+	transport := client.Transport
+	client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		requested = r.URL.Query()
+		return transport.RoundTrip(r)
+	})
+	service := &Service{APIKey: "mock_api_key", Client: client}
+
+	series, err := service.FetchPrices(context.Background(), "MOCK", data.FetchOptions{})
+	require.NoError(t, err)
+	require.False(t, requested.Has("resampleFreq"), "Daily bars are resampled locally")
+	require.Equal(t, []time.Time{time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)}, series.Dates)
+	// The dividend on the 31st is reinvested, and the split does not halve the index.
+	require.InDeltaSlice(t, []float64{100, 100 * 104.0 / 99}, series.Closes, 1e-9)
+	require.Equal(t, []float64{1, 0}, series.Dividends)
+	require.Equal(t, data.PriceAdjusted, series.PriceType)
+	require.Equal(t, "tiingo", series.Provenance.Source)
+
+	price, err := service.FetchPrices(context.Background(), "MOCK", data.FetchOptions{PriceType: data.PriceClose})
+	require.NoError(t, err)
+	require.InDeltaSlice(t, []float64{99, 104}, price.Closes, 1e-9, "Price returns leave the dividend out")
+
+	daily, err := service.FetchPrices(context.Background(), "MOCK", data.FetchOptions{Frequency: data.FrequencyDaily, PriceType: data.PriceClose})
+	require.NoError(t, err)
+	require.Len(t, daily.Closes, 4)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = service.FetchPrices(ctx, "MOCK", data.FetchOptions{})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+// scriptedResponse is one canned reply of scriptedClient.
+type scriptedResponse struct {
+	status     int
+	body       string
+	retryAfter string
+}
+
+// scriptedClient returns the responses in order, repeating the last, and counts calls.
+func scriptedClient(calls *int, responses ...scriptedResponse) *http.Client {
+	return &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			r := responses[min(*calls, len(responses)-1)]
+			*calls++
+			header := make(http.Header)
+			if r.retryAfter != "" {
+				header.Set("Retry-After", r.retryAfter)
+			}
+			return &http.Response{StatusCode: r.status, Body: io.NopCloser(bytes.NewBufferString(r.body)), Header: header}, nil
+		}),
+	}
+}
+
+var fastRetries = RetryPolicy{Retries: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+func TestFetchPrices_RetriesTransientErrors(t *testing.T) {
+	var calls int
+	service := &Service{
+		APIKey: "mock_api_key",
+		Client: scriptedClient(&calls,
+			scriptedResponse{status: http.StatusServiceUnavailable, body: `{"detail": "Service unavailable"}`},
+			scriptedResponse{status: http.StatusTooManyRequests, retryAfter: "0"},
+			scriptedResponse{status: http.StatusOK, body: `[{"date": "2024-01-31T00:00:00.000Z", "close": 100.0}, {"date": "2024-02-29T00:00:00.000Z", "close": 101.0}]`},
+		),
+		Retry: fastRetries,
+	}
+
+	series, err := service.FetchPrices(context.Background(), "MOCK", data.FetchOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+	require.Equal(t, []float64{100, 101}, series.Closes)
+}
+
+func TestFetchPrices_GivesUpAfterRetries(t *testing.T) {
+	var calls int
+	service := &Service{
+		APIKey: "mock_api_key",
+		Client: scriptedClient(&calls, scriptedResponse{status: http.StatusBadGateway, body: "<html>Bad Gateway</html>"}),
+		Retry:  fastRetries,
+	}
+
+	_, err := service.FetchPrices(context.Background(), "MOCK", data.FetchOptions{})
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	require.Equal(t, "<html>Bad Gateway</html>", apiErr.Detail, "Bodies that are not Tiingo's JSON are kept as text")
+	require.Equal(t, 4, calls, "The first attempt and three retries")
+}
+
+func TestFetchPrices_TypedErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		resp   scriptedResponse
+		target error
+		calls  int
+	}{
+		{"not found", scriptedResponse{status: http.StatusNotFound, body: `{"detail": "Error: Ticker 'NOPE' not found"}`}, ErrNotFound, 1},
+		{"unauthorized", scriptedResponse{status: http.StatusUnauthorized, body: `{"detail": "Invalid token."}`}, ErrUnauthorized, 1},
+		{"rate limited past the longest wait", scriptedResponse{status: http.StatusTooManyRequests, body: `{"detail": "Hourly limit reached"}`, retryAfter: "3600"}, ErrRateLimited, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			service := &Service{APIKey: "mock_api_key", Client: scriptedClient(&calls, tt.resp), Retry: fastRetries}
+
+			_, err := service.FetchPrices(context.Background(), "NOPE", data.FetchOptions{})
+			require.ErrorIs(t, err, tt.target)
+			require.Equal(t, tt.calls, calls, "The request is not retried")
+			var apiErr *APIError
+			require.ErrorAs(t, err, &apiErr)
+			require.Contains(t, err.Error(), apiErr.Detail)
+			require.NotEmpty(t, apiErr.Detail)
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, 30*time.Second, retryAfter("30", now))
+	require.Equal(t, 90*time.Second, retryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now))
+	require.Zero(t, retryAfter("", now))
+	require.Zero(t, retryAfter("soon", now))
+}
+
+func TestRetryPolicy_DelayIsJitteredBackoff(t *testing.T) {
+	policy := RetryPolicy{Retries: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	failure := &APIError{StatusCode: http.StatusInternalServerError}
+	for range 20 {
+		d, ok := policy.delay(1, failure)
+		require.True(t, ok)
+		require.GreaterOrEqual(t, d, 100*time.Millisecond)
+		require.LessOrEqual(t, d, 200*time.Millisecond)
+
+		d, _ = policy.delay(4, failure)
+		require.LessOrEqual(t, d, 300*time.Millisecond, "Delays are capped at MaxDelay")
+	}
+	_, ok := policy.delay(5, failure)
+	require.False(t, ok, "No retries are left")
+	d, ok := policy.delay(0, &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 250 * time.Millisecond})
+	require.True(t, ok)
+	require.Equal(t, 250*time.Millisecond, d, "Retry-After is used as given")
+}
+
 func TestGetMonthlyReturns_InvalidJSON(t *testing.T) {
 	// Removed unused 'badJSON' variable.
 	// Using malformedJSON to ensure a parsing error within the data processing chain.
-	malformedJSON := `[{"date": "2023-01-31T00:00:00.000Z", "adjClose": "not-a-float"}]`
+	malformedJSON := `[{"date": "2023-01-31T00:00:00.000Z", "close": "not-a-float"}]`
 
 	service := &Service{
 		APIKey: "mock_api_key",
 		Client: mockClient(http.StatusOK, malformedJSON),
 	}
 
-	_, err := service.GetMonthlyReturns("MOCK_INVALID_JSON_DATA")
+	_, err := service.GetMonthlyReturns("MOCK_INVALID_JSON_DATA", data.Window{})
 	require.Error(t, err)
 }
 
@@ -75,7 +256,7 @@ func TestGetMonthlyReturns_HTTPError(t *testing.T) {
 		Client: mockClient(http.StatusInternalServerError, `{"error": "Internal Server Error"}`),
 	}
 
-	_, err := service.GetMonthlyReturns("MOCK_HTTP_ERROR")
+	_, err := service.GetMonthlyReturns("MOCK_HTTP_ERROR", data.Window{})
 	require.Error(t, err)
 }
 
@@ -85,7 +266,7 @@ func TestGetMonthlyReturns_NoAPIKey(t *testing.T) {
 		Client: mockClient(http.StatusOK, "[]"),
 	}
 
-	_, err := service.GetMonthlyReturns("MOCK_NO_KEY")
+	_, err := service.GetMonthlyReturns("MOCK_NO_KEY", data.Window{})
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "API key is not configured")
 }
@@ -98,7 +279,7 @@ func TestGetMonthlyReturns_NoDataReturned(t *testing.T) {
 		Client: mockClient(http.StatusOK, mockJSON),
 	}
 
-	_, err := service.GetMonthlyReturns("MOCK_NO_DATA")
+	_, err := service.GetMonthlyReturns("MOCK_NO_DATA", data.Window{})
 	require.Error(t, err)
 	// Test asserts that GetMonthlyPrices returns an error if Tiingo provides no price data.
 	require.Contains(t, err.Error(), "no price data returned")
@@ -107,7 +288,7 @@ func TestGetMonthlyReturns_NoDataReturned(t *testing.T) {
 func TestGetMonthlyReturns_InsufficientDataForReturns(t *testing.T) {
 	mockJSON := `[{
 		"date": "2023-01-31T00:00:00.000Z",
-		"adjClose": 100.0
+		"close": 100.0
 	}]`
 
 	service := &Service{
@@ -115,7 +296,11 @@ func TestGetMonthlyReturns_InsufficientDataForReturns(t *testing.T) {
 		Client: mockClient(http.StatusOK, mockJSON),
 	}
 
-	returns, err := service.GetMonthlyReturns("MOCK_ONE_POINT")
+	returns, err := service.GetMonthlyReturns("MOCK_ONE_POINT", data.Window{})
 	require.NoError(t, err)
 	require.Nil(t, returns) // common.ToMonthlyReturns returns nil for < 2 prices.
 }
+
+func TestNewService_HasTimeout(t *testing.T) {
+	require.Equal(t, requestTimeout, NewService().Client.Timeout, "The default client must not wait forever")
+}
@@ -0,0 +1,34 @@
+package mortality
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// gompertz describes a Gompertz force of mortality mu(x) = A * exp(B * x).
+type gompertz struct {
+	A, B float64
+}
+
+// Parameters fitted to recent US period life tables at ages 65 and 85. They reproduce
+// a remaining life expectancy at 65 of roughly 18 years for men and 21 years for women.
+var builtinLaws = map[string]gompertz{
+	"male":   {A: 5.63e-5, B: 0.0869},
+	"female": {A: 2.48e-5, B: 0.0926},
+}
+
+// Builtin returns the built-in life table for the given sex ("male" or "female").
+func Builtin(sex string) (*Table, error) {
+	law, ok := builtinLaws[strings.ToLower(sex)]
+	if !ok {
+		return nil, fmt.Errorf("mortality: no built-in table for sex %q, expected 'male' or 'female'", sex)
+	}
+	t := &Table{StartAge: 0, Qx: make([]float64, MaxAge)}
+	for age := range t.Qx {
+		// Integrate the force of mortality over the year of age.
+		cumulativeHazard := law.A / law.B * math.Exp(law.B*float64(age)) * (math.Exp(law.B) - 1)
+		t.Qx[age] = 1 - math.Exp(-cumulativeHazard)
+	}
+	return t, nil
+}
@@ -0,0 +1,93 @@
+package mortality
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseCSV reads a life table from CSV with an age column and a q_x column.
+// A header row naming the columns "age" and "qx" is optional; without one the
+// first two columns are used in that order.
+func ParseCSV(r io.Reader) (*Table, error) {
+	ages, values, err := parseAgeCSV(r, "qx", "q_x")
+	if err != nil {
+		return nil, err
+	}
+	rates := make([]Rate, len(ages))
+	for i, age := range ages {
+		rates[i] = Rate{Age: age, Qx: values[i]}
+	}
+	return NewTable(rates)
+}
+
+// ParseDivisorCSV reads a distribution divisor table from CSV with an age column and
+// a divisor column. A header row naming the columns "age" and "divisor" is optional;
+// without one the first two columns are used in that order.
+func ParseDivisorCSV(r io.Reader) (*DivisorTable, error) {
+	ages, values, err := parseAgeCSV(r, "divisor")
+	if err != nil {
+		return nil, err
+	}
+	divisors := make([]Divisor, len(ages))
+	for i, age := range ages {
+		divisors[i] = Divisor{Age: age, Divisor: values[i]}
+	}
+	return NewDivisorTable(divisors)
+}
+
+// parseAgeCSV reads rows of an integer age and a float value. The value column may
+// be named by any of names in an optional header row; names[0] is used in errors.
+func parseAgeCSV(r io.Reader, names ...string) (ages []int, values []float64, err error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("mortality: failed to read CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil, errors.New("mortality: CSV table is empty")
+	}
+
+	ageCol, valueCol := 0, 1
+	if _, err := strconv.Atoi(strings.TrimSpace(records[0][0])); err != nil {
+		ageCol, valueCol = -1, -1
+		for i, name := range records[0] {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "age" {
+				ageCol = i
+			}
+			for _, valueName := range names {
+				if name == valueName {
+					valueCol = i
+				}
+			}
+		}
+		if ageCol < 0 || valueCol < 0 {
+			return nil, nil, fmt.Errorf("mortality: CSV header must contain 'age' and '%s' columns", names[0])
+		}
+		records = records[1:]
+	}
+
+	ages = make([]int, 0, len(records))
+	values = make([]float64, 0, len(records))
+	for line, record := range records {
+		if len(record) <= ageCol || len(record) <= valueCol {
+			return nil, nil, fmt.Errorf("mortality: CSV row %d has too few columns", line+1)
+		}
+		age, err := strconv.Atoi(strings.TrimSpace(record[ageCol]))
+		if err != nil {
+			return nil, nil, fmt.Errorf("mortality: invalid age %q: %w", record[ageCol], err)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[valueCol]), 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("mortality: invalid %s %q for age %d: %w", names[0], record[valueCol], age, err)
+		}
+		ages = append(ages, age)
+		values = append(values, value)
+	}
+	return ages, values, nil
+}
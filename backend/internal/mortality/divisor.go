@@ -0,0 +1,69 @@
+package mortality
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DivisorTable holds the life-expectancy divisors used for required minimum
+// distributions: the distribution for a year is the prior year-end balance divided
+// by the divisor for the owner's age. Ages past the end of the table use its last divisor.
+type DivisorTable struct {
+	StartAge int
+	Divisors []float64
+}
+
+// Divisor is a single row of a divisor table.
+type Divisor struct {
+	Age     int     // Integer age in years.
+	Divisor float64 // Distribution period in years, greater than 0.
+}
+
+//go:embed uniform_lifetime.csv
+var uniformLifetimeCSV string
+
+// NewDivisorTable builds a DivisorTable from rows that must cover consecutive ages in ascending order.
+func NewDivisorTable(divisors []Divisor) (*DivisorTable, error) {
+	if len(divisors) == 0 {
+		return nil, errors.New("mortality: divisor table must contain at least one age")
+	}
+	t := &DivisorTable{StartAge: divisors[0].Age, Divisors: make([]float64, len(divisors))}
+	for i, d := range divisors {
+		if d.Age < 0 || d.Age > MaxAge {
+			return nil, fmt.Errorf("mortality: age %d is outside 0 to %d", d.Age, MaxAge)
+		}
+		if d.Age != t.StartAge+i {
+			return nil, fmt.Errorf("mortality: ages must be consecutive and ascending, found %d after %d", d.Age, t.StartAge+i-1)
+		}
+		if d.Divisor <= 0 || math.IsNaN(d.Divisor) {
+			return nil, fmt.Errorf("mortality: divisor for age %d must be greater than 0", d.Age)
+		}
+		t.Divisors[i] = d.Divisor
+	}
+	return t, nil
+}
+
+// UniformLifetime returns the IRS Uniform Lifetime Table in effect from 2022, which
+// applies to account owners whose spouse is not more than ten years younger.
+func UniformLifetime() *DivisorTable {
+	t, err := ParseDivisorCSV(strings.NewReader(uniformLifetimeCSV))
+	if err != nil {
+		panic(fmt.Sprintf("mortality: invalid embedded uniform lifetime table: %v", err))
+	}
+	return t
+}
+
+// Divisor returns the divisor at the given age. Ages before the table use its first
+// divisor and ages after it use its last.
+func (t *DivisorTable) Divisor(age int) float64 {
+	i := min(max(age-t.StartAge, 0), len(t.Divisors)-1)
+	return t.Divisors[i]
+}
+
+// Covers reports whether the table has a divisor for the given age.
+func (t *DivisorTable) Covers(age int) bool {
+	return age >= t.StartAge && age < t.StartAge+len(t.Divisors)
+}
@@ -0,0 +1,95 @@
+// Package mortality provides life tables and lifespan sampling for
+// mortality-weighted retirement simulations.
+package mortality
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// MaxAge is the age at which every life table assumes certain death.
+const MaxAge = 120
+
+// Table holds annual probabilities of death (q_x) for consecutive integer ages.
+// Qx[i] is the probability that a person aged StartAge+i dies before reaching
+// StartAge+i+1. Ages past the end of the table are treated as certain death.
+type Table struct {
+	StartAge int
+	Qx       []float64
+}
+
+// Rate is a single row of a life table.
+type Rate struct {
+	Age int     // Integer age in years.
+	Qx  float64 // Probability of dying within the year, between 0 and 1.
+}
+
+// NewTable builds a Table from rows that must cover consecutive ages in ascending order.
+func NewTable(rates []Rate) (*Table, error) {
+	if len(rates) == 0 {
+		return nil, errors.New("mortality: table must contain at least one age")
+	}
+	t := &Table{StartAge: rates[0].Age, Qx: make([]float64, len(rates))}
+	for i, r := range rates {
+		if r.Age < 0 || r.Age > MaxAge {
+			return nil, fmt.Errorf("mortality: age %d is outside 0 to %d", r.Age, MaxAge)
+		}
+		if r.Age != t.StartAge+i {
+			return nil, fmt.Errorf("mortality: ages must be consecutive and ascending, found %d after %d", r.Age, t.StartAge+i-1)
+		}
+		if r.Qx < 0 || r.Qx > 1 || math.IsNaN(r.Qx) {
+			return nil, fmt.Errorf("mortality: death probability for age %d must be between 0 and 1", r.Age)
+		}
+		t.Qx[i] = r.Qx
+	}
+	return t, nil
+}
+
+// Q returns the annual probability of death at the given age.
+func (t *Table) Q(age int) float64 {
+	i := age - t.StartAge
+	if i < 0 || i >= len(t.Qx) || age >= MaxAge {
+		// Outside the table: assume the earliest rate below it and certain death above it.
+		if i < 0 && len(t.Qx) > 0 {
+			return t.Qx[0]
+		}
+		return 1
+	}
+	return t.Qx[i]
+}
+
+// Covers reports whether the table has a rate for the given age.
+func (t *Table) Covers(age int) bool {
+	return age >= t.StartAge && age < t.StartAge+len(t.Qx)
+}
+
+// LifeExpectancy returns the curtate-plus-half expected remaining lifetime in years at the given age.
+func (t *Table) LifeExpectancy(age int) float64 {
+	expected := 0.0
+	alive := 1.0
+	for a := age; a < MaxAge && alive > 0; a++ {
+		q := t.Q(a)
+		// Deaths within the year are assumed to occur mid-year on average.
+		expected += alive * (1 - q/2)
+		alive *= 1 - q
+	}
+	return expected
+}
+
+// SampleDeathMonth draws the month of death for a person currently aged age.
+// The result counts months from now, starting at 1 for death within the first month.
+// Within the year of death the month is uniformly distributed. Someone who survives to
+// MaxAge dies in the year before it, as death is certain by then.
+func (t *Table) SampleDeathMonth(age int, rng *rand.Rand) int {
+	years := 0
+	for a := age; a < MaxAge; a++ {
+		if rng.Float64() < t.Q(a) {
+			break
+		}
+		years++
+	}
+	years = max(0, min(years, MaxAge-age-1))
+	return years*12 + rng.Intn(12) + 1
+}
@@ -0,0 +1,125 @@
+package mortality
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltin_LifeExpectancy(t *testing.T) {
+	male, err := Builtin("male")
+	require.NoError(t, err)
+	female, err := Builtin("Female")
+	require.NoError(t, err)
+
+	require.InDelta(t, 18.0, male.LifeExpectancy(65), 1.0)
+	require.InDelta(t, 21.0, female.LifeExpectancy(65), 1.0)
+	require.Greater(t, male.Q(90), male.Q(70), "mortality must increase with age")
+	require.Equal(t, 1.0, male.Q(MaxAge))
+
+	_, err = Builtin("unknown")
+	require.Error(t, err)
+}
+
+func TestNewTable_Validation(t *testing.T) {
+	_, err := NewTable(nil)
+	require.Error(t, err)
+
+	_, err = NewTable([]Rate{{Age: 65, Qx: 0.01}, {Age: 67, Qx: 0.02}})
+	require.ErrorContains(t, err, "consecutive")
+
+	_, err = NewTable([]Rate{{Age: 65, Qx: 1.5}})
+	require.ErrorContains(t, err, "between 0 and 1")
+
+	table, err := NewTable([]Rate{{Age: 65, Qx: 0.01}, {Age: 66, Qx: 0.02}})
+	require.NoError(t, err)
+	require.True(t, table.Covers(66))
+	require.False(t, table.Covers(67))
+	require.Equal(t, 0.02, table.Q(66))
+	require.Equal(t, 1.0, table.Q(67), "ages past the table end are certain death")
+}
+
+func TestParseCSV(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{"with header", "qx,age\n0.1,90\n0.2,91\n"},
+		{"without header", "90,0.1\n91,0.2\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			table, err := ParseCSV(strings.NewReader(tc.input))
+			require.NoError(t, err)
+			require.Equal(t, 90, table.StartAge)
+			require.Equal(t, []float64{0.1, 0.2}, table.Qx)
+		})
+	}
+
+	_, err := ParseCSV(strings.NewReader("years,prob\n90,0.1\n"))
+	require.ErrorContains(t, err, "header must contain")
+
+	_, err = ParseCSV(strings.NewReader("90,abc\n"))
+	require.ErrorContains(t, err, "invalid qx")
+}
+
+func TestSampleDeathMonth_MatchesLifeExpectancy(t *testing.T) {
+	table, err := Builtin("female")
+	require.NoError(t, err)
+
+	rng := rand.New(rand.NewSource(7))
+	const n = 20000
+	total := 0
+	for i := 0; i < n; i++ {
+		month := table.SampleDeathMonth(70, rng)
+		require.GreaterOrEqual(t, month, 1)
+		require.LessOrEqual(t, month, (MaxAge-70)*12)
+		total += month
+	}
+	meanYears := float64(total) / n / 12
+	// Uniform death months within the year average to mid-year, plus half a month of offset.
+	require.InDelta(t, table.LifeExpectancy(70), meanYears, 0.3)
+}
+
+func TestSampleDeathMonth_DiesByMaxAge(t *testing.T) {
+	// A custom table in which nobody dies before MaxAge.
+	rates := make([]Rate, 0, MaxAge-100+1)
+	for age := 100; age <= MaxAge; age++ {
+		rates = append(rates, Rate{Age: age, Qx: 0})
+	}
+	table, err := NewTable(rates)
+	require.NoError(t, err)
+
+	rng := rand.New(rand.NewSource(7))
+	for i := 0; i < 100; i++ {
+		month := table.SampleDeathMonth(110, rng)
+		require.Greater(t, month, (MaxAge-110-1)*12, "Death comes in the last year")
+		require.LessOrEqual(t, month, (MaxAge-110)*12)
+	}
+	require.LessOrEqual(t, table.SampleDeathMonth(MaxAge, rng), 12)
+}
+
+func TestUniformLifetime(t *testing.T) {
+	table := UniformLifetime()
+	require.Equal(t, 72, table.StartAge)
+	require.Equal(t, 26.5, table.Divisor(73))
+	require.Equal(t, 2.0, table.Divisor(120))
+	require.Equal(t, 2.0, table.Divisor(125), "ages past the table use its last divisor")
+	require.True(t, table.Covers(75))
+	require.False(t, table.Covers(70))
+}
+
+func TestParseDivisorCSV(t *testing.T) {
+	table, err := ParseDivisorCSV(strings.NewReader("divisor,age\n27.4,72\n26.5,73\n"))
+	require.NoError(t, err)
+	require.Equal(t, 72, table.StartAge)
+	require.Equal(t, []float64{27.4, 26.5}, table.Divisors)
+
+	_, err = ParseDivisorCSV(strings.NewReader("72,0\n"))
+	require.ErrorContains(t, err, "must be greater than 0")
+
+	_, err = ParseDivisorCSV(strings.NewReader("age,qx\n72,27.4\n"))
+	require.ErrorContains(t, err, "'age' and 'divisor' columns")
+}
@@ -0,0 +1,50 @@
+package portfolio
+
+import "portfolio-simulator/backend/internal/portfolio/model"
+
+// Combine merges the accounts into a single portfolio holding every ticker once, in
+// order of first appearance, weighted by the accounts' balances. An asset's expense
+// ratio, synthetic returns and assumptions are taken from its first appearance.
+func Combine(accounts []model.Account) model.Portfolio {
+	var combined model.Portfolio
+	index := make(map[string]int)
+	total := 0.0
+	for _, account := range accounts {
+		total += account.Balance
+	}
+	for _, account := range accounts {
+		for _, asset := range account.Portfolio.Assets {
+			i, ok := index[asset.Ticker]
+			if !ok {
+				i = len(combined.Assets)
+				index[asset.Ticker] = i
+				combined.Assets = append(combined.Assets, model.Asset{
+					Ticker:       asset.Ticker,
+					ExpenseRatio: asset.ExpenseRatio,
+					Class:        asset.Class,
+					Synthetic:    asset.Synthetic,
+					Assumptions:  asset.Assumptions,
+					Backfill:     asset.Backfill,
+				})
+			}
+			if total > 0 {
+				combined.Assets[i].Weight += asset.Weight * account.Balance / total
+			}
+		}
+	}
+	return combined
+}
+
+// AlignedWeights returns the weights of p's assets in the order of order's assets.
+// Tickers that p does not hold get a weight of 0.
+func AlignedWeights(p, order model.Portfolio) []float64 {
+	weights := make([]float64, len(order.Assets))
+	for i, o := range order.Assets {
+		for _, asset := range p.Assets {
+			if asset.Ticker == o.Ticker {
+				weights[i] += asset.Weight
+			}
+		}
+	}
+	return weights
+}
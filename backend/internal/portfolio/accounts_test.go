@@ -0,0 +1,31 @@
+package portfolio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"portfolio-simulator/backend/internal/portfolio/model"
+)
+
+func TestCombine(t *testing.T) {
+	accounts := []model.Account{
+		{Name: "brokerage", Balance: 300, Portfolio: model.Portfolio{Assets: []model.Asset{
+			{Ticker: "VTI", Weight: 1.0, ExpenseRatio: 0.0003},
+		}}},
+		{Name: "401k", Balance: 700, Portfolio: model.Portfolio{Assets: []model.Asset{
+			{Ticker: "BND", Weight: 0.5},
+			{Ticker: "VTI", Weight: 0.5},
+		}}},
+	}
+
+	combined := Combine(accounts)
+	require.Len(t, combined.Assets, 2)
+	require.Equal(t, "VTI", combined.Assets[0].Ticker)
+	require.InDelta(t, 0.3+0.35, combined.Assets[0].Weight, 1e-12)
+	require.Equal(t, 0.0003, combined.Assets[0].ExpenseRatio)
+	require.Equal(t, "BND", combined.Assets[1].Ticker)
+	require.InDelta(t, 0.35, combined.Assets[1].Weight, 1e-12)
+
+	require.Equal(t, []float64{1.0, 0.0}, AlignedWeights(accounts[0].Portfolio, combined))
+	require.Equal(t, []float64{0.5, 0.5}, AlignedWeights(accounts[1].Portfolio, combined))
+}
@@ -0,0 +1,117 @@
+package portfolio
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"portfolio-simulator/backend/internal/data"
+	"portfolio-simulator/backend/internal/portfolio/model"
+)
+
+// Alignment selects which calendar months make up the aligned history.
+type Alignment string
+
+const (
+	// AlignIntersect keeps only the months in which every asset has a return. It is the default.
+	AlignIntersect Alignment = "intersect"
+	// AlignUnion keeps every month in which any asset has a return; an asset's missing
+	// months are filled with its mean monthly return.
+	AlignUnion Alignment = "union"
+)
+
+// DateRange is the span of calendar months in an aligned history.
+type DateRange struct {
+	Start  time.Time // First month.
+	End    time.Time // Last month.
+	Months int       // Number of months, which is less than the span if months are missing.
+}
+
+// AlignByDate lines the assets' return series up by calendar month, so that each index of
+// the returned series refers to the same month for every asset. The result can be passed
+// to WeightedMonthlyReturns and AlignedReturns. Synthetic assets are skipped, as their
+// returns are generated for the aligned months.
+//
+// Undated series cannot be aligned by month; they are returned unchanged with a nil
+// range, leaving the index alignment of WeightedMonthlyReturns to truncate them.
+func AlignByDate(p model.Portfolio, seriesByAsset map[string]data.ReturnSeries, alignment Alignment) (map[string][]float64, *DateRange, error) {
+	var tickers []string
+	dated := true
+	for _, asset := range p.Assets {
+		if asset.Synthetic != nil {
+			continue
+		}
+		series, ok := seriesByAsset[asset.Ticker]
+		if !ok {
+			return nil, nil, fmt.Errorf("missing returns for asset %s", asset.Ticker)
+		}
+		if series.Dated() && len(series.Months) != len(series.Returns) {
+			return nil, nil, fmt.Errorf("asset %s has %d months for %d returns", asset.Ticker, len(series.Months), len(series.Returns))
+		}
+		dated = dated && series.Dated()
+		tickers = append(tickers, asset.Ticker)
+	}
+
+	returnsByAsset := make(map[string][]float64, len(tickers))
+	if !dated || len(tickers) == 0 {
+		for _, ticker := range tickers {
+			returnsByAsset[ticker] = seriesByAsset[ticker].Returns
+		}
+		return returnsByAsset, nil, nil
+	}
+
+	byMonth := make(map[string]map[time.Time]float64, len(tickers))
+	counts := make(map[time.Time]int)
+	for _, ticker := range tickers {
+		series := seriesByAsset[ticker]
+		months := make(map[time.Time]float64, len(series.Returns))
+		for i, month := range series.Months {
+			months[data.MonthOf(month)] = series.Returns[i]
+		}
+		for month := range months {
+			counts[month]++
+		}
+		byMonth[ticker] = months
+	}
+
+	var months []time.Time
+	for month, n := range counts {
+		switch alignment {
+		case AlignUnion:
+			months = append(months, month)
+		case AlignIntersect, "":
+			if n == len(tickers) {
+				months = append(months, month)
+			}
+		default:
+			return nil, nil, fmt.Errorf("unknown alignment %q", alignment)
+		}
+	}
+	if len(months) == 0 {
+		return nil, nil, fmt.Errorf("assets have no months of returns in common")
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Before(months[j]) })
+
+	for _, ticker := range tickers {
+		returns := byMonth[ticker]
+		fill := 0.0
+		for _, r := range returns {
+			fill += r
+		}
+		if len(returns) == 0 {
+			return nil, nil, fmt.Errorf("asset %s has no returns to fill missing months with", ticker)
+		}
+		fill /= float64(len(returns))
+
+		aligned := make([]float64, len(months))
+		for i, month := range months {
+			r, ok := returns[month]
+			if !ok {
+				r = fill
+			}
+			aligned[i] = r
+		}
+		returnsByAsset[ticker] = aligned
+	}
+	return returnsByAsset, &DateRange{Start: months[0], End: months[len(months)-1], Months: len(months)}, nil
+}
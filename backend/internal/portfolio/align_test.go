@@ -0,0 +1,80 @@
+package portfolio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"portfolio-simulator/backend/internal/data"
+	"portfolio-simulator/backend/internal/portfolio/model"
+)
+
+// monthly returns a series with consecutive months starting at year/month.
+func monthly(year int, month time.Month, returns ...float64) data.ReturnSeries {
+	months := make([]time.Time, len(returns))
+	for i := range returns {
+		months[i] = time.Date(year, month+time.Month(i), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return data.ReturnSeries{Months: months, Returns: returns}
+}
+
+func TestAlignByDate_Intersect(t *testing.T) {
+	p := model.Portfolio{Assets: []model.Asset{{Ticker: "OLD", Weight: 0.5}, {Ticker: "NEW", Weight: 0.5}}}
+	series := map[string]data.ReturnSeries{
+		"OLD": monthly(2020, time.January, 0.01, 0.02, 0.03, 0.04),
+		"NEW": monthly(2020, time.March, 0.10, 0.20, 0.30),
+	}
+
+	aligned, dates, err := AlignByDate(p, series, AlignIntersect)
+	require.NoError(t, err)
+	require.Equal(t, []float64{0.03, 0.04}, aligned["OLD"], "March and April 2020, not the first two months of each")
+	require.Equal(t, []float64{0.10, 0.20}, aligned["NEW"])
+	require.Equal(t, &DateRange{
+		Start:  time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC),
+		End:    time.Date(2020, time.April, 1, 0, 0, 0, 0, time.UTC),
+		Months: 2,
+	}, dates)
+
+	weighted, err := WeightedMonthlyReturns(p, aligned)
+	require.NoError(t, err)
+	require.InDeltaSlice(t, []float64{0.065, 0.12}, weighted, 1e-12)
+}
+
+func TestAlignByDate_Union(t *testing.T) {
+	p := model.Portfolio{Assets: []model.Asset{
+		{Ticker: "OLD", Weight: 0.5},
+		{Ticker: "NEW", Weight: 0.3},
+		{Ticker: "CASH", Weight: 0.2, Synthetic: &model.Synthetic{MonthlyMean: 0.001}},
+	}}
+	series := map[string]data.ReturnSeries{
+		"OLD": monthly(2020, time.January, 0.01, 0.02, 0.03),
+		"NEW": monthly(2020, time.March, 0.10, 0.20),
+	}
+
+	aligned, dates, err := AlignByDate(p, series, AlignUnion)
+	require.NoError(t, err)
+	require.InDeltaSlice(t, []float64{0.01, 0.02, 0.03, 0.02}, aligned["OLD"], 1e-12, "April is filled with the mean")
+	require.InDeltaSlice(t, []float64{0.15, 0.15, 0.10, 0.20}, aligned["NEW"], 1e-12)
+	require.Equal(t, 4, dates.Months)
+	require.NotContains(t, aligned, "CASH")
+}
+
+func TestAlignByDate_Undated(t *testing.T) {
+	p := model.Portfolio{Assets: []model.Asset{{Ticker: "A", Weight: 1}}}
+	aligned, dates, err := AlignByDate(p, map[string]data.ReturnSeries{"A": {Returns: []float64{0.01, 0.02}}}, AlignIntersect)
+	require.NoError(t, err)
+	require.Nil(t, dates)
+	require.Equal(t, []float64{0.01, 0.02}, aligned["A"])
+}
+
+func TestAlignByDate_Errors(t *testing.T) {
+	p := model.Portfolio{Assets: []model.Asset{{Ticker: "A", Weight: 0.5}, {Ticker: "B", Weight: 0.5}}}
+	_, _, err := AlignByDate(p, map[string]data.ReturnSeries{"A": monthly(2020, time.January, 0.01)}, AlignIntersect)
+	require.ErrorContains(t, err, "missing returns for asset B")
+
+	_, _, err = AlignByDate(p, map[string]data.ReturnSeries{
+		"A": monthly(2020, time.January, 0.01),
+		"B": monthly(2021, time.January, 0.01),
+	}, AlignIntersect)
+	require.ErrorContains(t, err, "no months of returns in common")
+}
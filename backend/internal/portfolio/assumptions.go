@@ -0,0 +1,59 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+
+	"portfolio-simulator/backend/internal/portfolio/model"
+)
+
+// applyAssumptions shifts and rescales the asset's returns to its capital market
+// assumptions. Each month keeps its standardised value, so the shape of the
+// distribution and the correlations with other assets are those of the history.
+// Without assumptions the returns are passed through unchanged. A volatility so high
+// that a month would lose everything is an error, as a holding cannot fall below zero.
+func applyAssumptions(asset model.Asset, returns []float64) ([]float64, error) {
+	a := asset.Assumptions
+	if a == nil || (a.MonthlyMean == nil && a.MonthlyStdDev == nil) {
+		return returns, nil
+	}
+	if len(returns) == 0 {
+		return returns, nil
+	}
+
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	scale := 1.0
+	if a.MonthlyStdDev != nil {
+		if len(returns) < 2 {
+			return nil, fmt.Errorf("asset %s needs at least 2 months of returns to rescale its volatility", asset.Ticker)
+		}
+		variance := 0.0
+		for _, r := range returns {
+			variance += (r - mean) * (r - mean)
+		}
+		std := math.Sqrt(variance / float64(len(returns)-1))
+		if std == 0 {
+			return nil, fmt.Errorf("asset %s has constant returns, so its volatility cannot be rescaled", asset.Ticker)
+		}
+		scale = *a.MonthlyStdDev / std
+	}
+
+	target := mean
+	if a.MonthlyMean != nil {
+		target = *a.MonthlyMean
+	}
+
+	adjusted := make([]float64, len(returns))
+	for i, r := range returns {
+		adjusted[i] = target + (r-mean)*scale
+		if adjusted[i] <= -1 {
+			return nil, fmt.Errorf("asset %s: its assumptions turn a month's return of %.1f%% into %.1f%%, a loss of everything; lower its volatility", asset.Ticker, r*100, adjusted[i]*100)
+		}
+	}
+	return adjusted, nil
+}
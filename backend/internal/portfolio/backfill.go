@@ -0,0 +1,162 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"portfolio-simulator/backend/internal/data"
+	"portfolio-simulator/backend/internal/portfolio/model"
+)
+
+// Source is a run of an asset's monthly returns that came from one ticker.
+type Source struct {
+	Ticker          string    // The asset itself or one of its proxies.
+	Start           time.Time // First month taken from the ticker.
+	End             time.Time // Last month taken from the ticker.
+	Months          int       // Number of months taken.
+	VolatilityScale float64   // Factor applied to the ticker's deviations from its mean; 1 when unscaled.
+}
+
+// Backfill extends the returns of p's assets that have proxies, splicing each proxy's
+// returns in before the earliest month of the history so far. The proxies must be in
+// seriesByAsset next to the assets. Assets without proxies are passed through.
+//
+// It returns the extended series and, for each backfilled asset, the sources of its
+// months from earliest to latest.
+func Backfill(p model.Portfolio, seriesByAsset map[string]data.ReturnSeries) (map[string]data.ReturnSeries, map[string][]Source, error) {
+	extended := make(map[string]data.ReturnSeries, len(seriesByAsset))
+	for ticker, series := range seriesByAsset {
+		extended[ticker] = series
+	}
+	sources := make(map[string][]Source)
+	for _, asset := range p.Assets {
+		if asset.Backfill == nil || asset.Synthetic != nil {
+			continue
+		}
+		series, ok := seriesByAsset[asset.Ticker]
+		if !ok {
+			return nil, nil, fmt.Errorf("missing returns for asset %s", asset.Ticker)
+		}
+		if !series.Dated() {
+			return nil, nil, fmt.Errorf("asset %s needs dated returns to be backfilled", asset.Ticker)
+		}
+
+		var spans []Source
+		if len(series.Returns) > 0 {
+			spans = append(spans, Source{
+				Ticker:          asset.Ticker,
+				Start:           series.Months[0],
+				End:             series.Months[len(series.Months)-1],
+				Months:          len(series.Returns),
+				VolatilityScale: 1,
+			})
+		}
+		for _, proxy := range asset.Backfill.Proxies {
+			proxySeries, ok := seriesByAsset[proxy]
+			if !ok {
+				return nil, nil, fmt.Errorf("missing returns for proxy %s of asset %s", proxy, asset.Ticker)
+			}
+			if !proxySeries.Dated() {
+				return nil, nil, fmt.Errorf("proxy %s of asset %s needs dated returns", proxy, asset.Ticker)
+			}
+			scale := 1.0
+			if asset.Backfill.ScaleVolatility && len(series.Returns) > 0 {
+				var err error
+				if scale, err = overlapScale(series, proxySeries); err != nil {
+					return nil, nil, fmt.Errorf("proxy %s of asset %s: %w", proxy, asset.Ticker, err)
+				}
+			}
+
+			// Months of the proxy before the history so far; all of them if there is none yet.
+			n := len(proxySeries.Returns)
+			if len(series.Months) > 0 {
+				n = 0
+				for n < len(proxySeries.Months) && data.MonthOf(proxySeries.Months[n]).Before(series.Months[0]) {
+					n++
+				}
+			}
+			if n == 0 {
+				continue // The proxy starts no earlier than the history so far.
+			}
+			// Scaling keeps the mean of the spliced months and only narrows or widens their spread.
+			mean, _ := meanStdDev(proxySeries.Returns[:n])
+
+			months := make([]time.Time, 0, n+len(series.Months))
+			returns := make([]float64, 0, n+len(series.Returns))
+			for i := range n {
+				months = append(months, data.MonthOf(proxySeries.Months[i]))
+				r := proxySeries.Returns[i]
+				if scale != 1 {
+					r = mean + (r-mean)*scale
+				}
+				returns = append(returns, r)
+			}
+			series = data.ReturnSeries{
+				Months:  append(months, series.Months...),
+				Returns: append(returns, series.Returns...),
+			}
+			spans = append([]Source{{
+				Ticker:          proxy,
+				Start:           months[0],
+				End:             months[n-1],
+				Months:          n,
+				VolatilityScale: scale,
+			}}, spans...)
+		}
+		extended[asset.Ticker] = series
+		sources[asset.Ticker] = spans
+	}
+	return extended, sources, nil
+}
+
+// overlapScale returns the ratio of the history's volatility to the proxy's over the
+// months they share.
+func overlapScale(history, proxy data.ReturnSeries) (float64, error) {
+	proxyReturns := overlap(history, proxy)
+	if len(proxyReturns) < 2 {
+		return 0, fmt.Errorf("needs at least 2 months overlapping the history to scale its volatility")
+	}
+	historyReturns := overlap(proxy, history)
+	_, proxyStd := meanStdDev(proxyReturns)
+	_, historyStd := meanStdDev(historyReturns)
+	if proxyStd == 0 {
+		return 0, fmt.Errorf("has constant returns over the overlap, so its volatility cannot be scaled")
+	}
+	return historyStd / proxyStd, nil
+}
+
+// overlap returns the returns of series in the months that other also has.
+func overlap(other, series data.ReturnSeries) []float64 {
+	months := make(map[time.Time]bool, len(other.Months))
+	for _, m := range other.Months {
+		months[data.MonthOf(m)] = true
+	}
+	var returns []float64
+	for i, m := range series.Months {
+		if months[data.MonthOf(m)] {
+			returns = append(returns, series.Returns[i])
+		}
+	}
+	return returns
+}
+
+// meanStdDev returns the mean and sample standard deviation of returns.
+func meanStdDev(returns []float64) (float64, float64) {
+	if len(returns) == 0 {
+		return 0, 0
+	}
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	if len(returns) < 2 {
+		return mean, 0
+	}
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(returns)-1))
+}
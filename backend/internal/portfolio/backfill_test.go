@@ -0,0 +1,76 @@
+package portfolio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"portfolio-simulator/backend/internal/data"
+	"portfolio-simulator/backend/internal/portfolio/model"
+)
+
+func TestBackfill_Chain(t *testing.T) {
+	p := model.Portfolio{Assets: []model.Asset{
+		{Ticker: "ETF", Weight: 0.6, Backfill: &model.Backfill{Proxies: []string{"FUND", "INDEX"}}},
+		{Ticker: "BND", Weight: 0.4},
+	}}
+	series := map[string]data.ReturnSeries{
+		"ETF":   monthly(2020, time.March, 0.03, 0.04),
+		"FUND":  monthly(2020, time.January, 0.11, 0.12, 0.13),
+		"INDEX": monthly(2019, time.November, 0.21, 0.22, 0.23, 0.24),
+		"BND":   monthly(2019, time.November, 0, 0, 0, 0, 0, 0),
+	}
+
+	extended, sources, err := Backfill(p, series)
+	require.NoError(t, err)
+	require.Equal(t, monthly(2019, time.November, 0.21, 0.22, 0.11, 0.12, 0.03, 0.04), extended["ETF"])
+	require.Equal(t, series["BND"], extended["BND"])
+	require.Equal(t, []Source{
+		{Ticker: "INDEX", Start: time.Date(2019, time.November, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2019, time.December, 1, 0, 0, 0, 0, time.UTC), Months: 2, VolatilityScale: 1},
+		{Ticker: "FUND", Start: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2020, time.February, 1, 0, 0, 0, 0, time.UTC), Months: 2, VolatilityScale: 1},
+		{Ticker: "ETF", Start: time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2020, time.April, 1, 0, 0, 0, 0, time.UTC), Months: 2, VolatilityScale: 1},
+	}, sources["ETF"])
+	require.NotContains(t, sources, "BND")
+
+	// After backfilling, date alignment keeps the proxies' months.
+	_, dates, err := AlignByDate(p, extended, AlignIntersect)
+	require.NoError(t, err)
+	require.Equal(t, 6, dates.Months)
+}
+
+func TestBackfill_ScaleVolatility(t *testing.T) {
+	p := model.Portfolio{Assets: []model.Asset{
+		{Ticker: "ETF", Weight: 1, Backfill: &model.Backfill{Proxies: []string{"LEVERED"}, ScaleVolatility: true}},
+	}}
+	// Over the overlap the proxy swings twice as far as the ETF.
+	series := map[string]data.ReturnSeries{
+		"ETF":     monthly(2020, time.March, 0.01, -0.01, 0.01, -0.01),
+		"LEVERED": monthly(2020, time.January, 0.05, 0.01, 0.02, -0.02, 0.02, -0.02),
+	}
+
+	extended, sources, err := Backfill(p, series)
+	require.NoError(t, err)
+	require.InDelta(t, 0.5, sources["ETF"][0].VolatilityScale, 1e-12)
+	// The spliced months keep their mean of 0.03 and halve their spread.
+	require.InDeltaSlice(t, []float64{0.04, 0.02, 0.01, -0.01, 0.01, -0.01}, extended["ETF"].Returns, 1e-12)
+}
+
+func TestBackfill_Errors(t *testing.T) {
+	p := model.Portfolio{Assets: []model.Asset{
+		{Ticker: "ETF", Weight: 1, Backfill: &model.Backfill{Proxies: []string{"FUND"}, ScaleVolatility: true}},
+	}}
+	_, _, err := Backfill(p, map[string]data.ReturnSeries{"ETF": monthly(2020, time.March, 0.01)})
+	require.ErrorContains(t, err, "missing returns for proxy FUND of asset ETF")
+
+	_, _, err = Backfill(p, map[string]data.ReturnSeries{
+		"ETF":  monthly(2020, time.March, 0.01, 0.02),
+		"FUND": monthly(2019, time.January, 0.01, 0.02),
+	})
+	require.ErrorContains(t, err, "needs at least 2 months overlapping")
+
+	_, _, err = Backfill(p, map[string]data.ReturnSeries{
+		"ETF":  {Returns: []float64{0.01}},
+		"FUND": monthly(2019, time.January, 0.01),
+	})
+	require.ErrorContains(t, err, "needs dated returns")
+}
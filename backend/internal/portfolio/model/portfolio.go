@@ -1,13 +1,53 @@
 package model
 
 // Asset represents a single asset within a portfolio, defined by its
-// ticker symbol and its allocation weight.
+// ticker symbol, its allocation weight and the annual cost of holding it.
 type Asset struct {
-	Ticker string  // Ticker symbol (e.g., "AAPL", "SPY").
-	Weight float64 // Allocation weight within the portfolio (e.g., 0.6 for 60%).
+	Ticker       string  // Ticker symbol (e.g., "AAPL", "SPY").
+	Weight       float64 // Allocation weight within the portfolio (e.g., 0.6 for 60%).
+	ExpenseRatio float64 // Annual fund expense ratio (e.g., 0.0003 for 0.03%).
+	Class        string  // "equity", "bond" or "cash": the returns it earns in a stress scenario.
+
+	// Synthetic, when set, generates the asset's returns instead of fetching them.
+	Synthetic *Synthetic
+	// Assumptions, when set, moves the fetched returns to forward-looking expectations.
+	Assumptions *Assumptions
+	// Backfill, when set, extends the fetched returns to before the asset's inception.
+	Backfill *Backfill
+}
+
+// Backfill extends an asset's short history with the returns of proxy tickers, such as
+// an index fund standing in for a newer ETF that tracks the same index.
+type Backfill struct {
+	Proxies         []string // Proxy tickers in order of preference; each fills the months before the history so far.
+	ScaleVolatility bool     // Rescale each proxy to the volatility of the history so far over the months they overlap.
+}
+
+// Assumptions are capital market assumptions for an asset. The historical returns keep
+// their shape and correlations but are shifted and rescaled to this mean and volatility.
+type Assumptions struct {
+	MonthlyMean   *float64 // Expected monthly return; nil keeps the historical mean.
+	MonthlyStdDev *float64 // Monthly standard deviation; nil keeps the historical volatility.
+}
+
+// Synthetic describes the monthly returns of an asset without price history, such as
+// cash at a fixed rate or bonds with an assumed mean and volatility.
+type Synthetic struct {
+	MonthlyMean   float64            // Mean monthly return.
+	MonthlyStdDev float64            // Monthly standard deviation; 0 gives a constant return.
+	Correlations  map[string]float64 // Correlation with other assets by ticker; assets left out are uncorrelated.
 }
 
 // Portfolio represents a collection of assets.
 type Portfolio struct {
 	Assets []Asset
 }
+
+// Account is a portfolio held in a single account with its own tax treatment.
+type Account struct {
+	Name      string    // Label for the account (e.g., "401k").
+	TaxType   string    // "taxable", "tax-deferred" or "roth".
+	Balance   float64   // Current balance.
+	CostBasis float64   // Cost basis of a taxable account.
+	Portfolio Portfolio // Allocation within the account.
+}
@@ -7,6 +7,29 @@ import (
 
 // WeightedMonthlyReturns computes the weighted sum of monthly returns for the portfolio.
 func WeightedMonthlyReturns(p model.Portfolio, returnsByAsset map[string][]float64) ([]float64, error) {
+	aligned, err := AlignedReturns(p, returnsByAsset)
+	if err != nil || aligned == nil {
+		return nil, err
+	}
+
+	weightedReturns := make([]float64, len(aligned))
+	for i, row := range aligned {
+		sum := 0.0
+		for j, asset := range p.Assets {
+			sum += asset.Weight * row[j]
+		}
+		weightedReturns[i] = sum
+	}
+
+	return weightedReturns, nil
+}
+
+// AlignedReturns arranges the assets' monthly returns side by side: one row per month,
+// with columns in the order of p.Assets. Series are truncated to the shortest one.
+// Fetched series are adjusted to any capital market assumptions. Synthetic assets are
+// not looked up in returnsByAsset; their returns are generated for the same months,
+// correlated with the other assets as requested.
+func AlignedReturns(p model.Portfolio, returnsByAsset map[string][]float64) ([][]float64, error) {
 	if len(p.Assets) == 0 {
 		return nil, nil
 	}
@@ -14,6 +37,9 @@ func WeightedMonthlyReturns(p model.Portfolio, returnsByAsset map[string][]float
 	// Find minimum length among all assets' returns
 	minMonths := -1
 	for _, asset := range p.Assets {
+		if asset.Synthetic != nil {
+			continue
+		}
 		ret, ok := returnsByAsset[asset.Ticker]
 		if !ok {
 			return nil, fmt.Errorf("missing returns for asset %s", asset.Ticker)
@@ -23,17 +49,43 @@ func WeightedMonthlyReturns(p model.Portfolio, returnsByAsset map[string][]float
 		}
 	}
 
-	// Compute weighted returns up to minMonths
-	weightedReturns := make([]float64, minMonths)
-	for i := 0; i < minMonths; i++ {
-		sum := 0.0
-		for _, asset := range p.Assets {
-			sum += asset.Weight * returnsByAsset[asset.Ticker][i]
+	if minMonths == -1 {
+		// Only synthetic assets: there is no history to align with.
+		minMonths = defaultSyntheticMonths
+	}
+
+	columns := make([][]float64, len(p.Assets))
+	for j, asset := range p.Assets {
+		if asset.Synthetic != nil {
+			continue
 		}
-		weightedReturns[i] = sum
+		column, err := applyAssumptions(asset, returnsByAsset[asset.Ticker][:minMonths])
+		if err != nil {
+			return nil, err
+		}
+		columns[j] = column
+	}
+	for j, asset := range p.Assets {
+		if asset.Synthetic == nil {
+			continue
+		}
+		series, err := syntheticReturns(p, j, columns, minMonths)
+		if err != nil {
+			return nil, err
+		}
+		columns[j] = series
 	}
 
-	return weightedReturns, nil
+	aligned := make([][]float64, minMonths)
+	for i := range aligned {
+		row := make([]float64, len(p.Assets))
+		for j := range p.Assets {
+			row[j] = columns[j][i]
+		}
+		aligned[i] = row
+	}
+
+	return aligned, nil
 }
 
 // ComputePortfolioReturns fetches monthly returns for each asset in the portfolio.
@@ -2,6 +2,7 @@ package portfolio
 
 import (
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -150,3 +151,129 @@ func TestWeightedMonthlyReturns_VaryingLengths(t *testing.T) {
 	const epsilon = 1e-9
 	require.InEpsilonSlice(t, expected, result, epsilon)
 }
+
+func TestAlignedReturns(t *testing.T) {
+	p := model.Portfolio{
+		Assets: []model.Asset{
+			{Ticker: "BBB", Weight: 0.5},
+			{Ticker: "AAA", Weight: 0.5},
+		},
+	}
+
+	returnsByAsset := map[string][]float64{
+		"AAA": {0.01, 0.02, 0.03},
+		"BBB": {0.04, 0.05},
+	}
+
+	result, err := AlignedReturns(p, returnsByAsset)
+	require.NoError(t, err)
+	require.Equal(t, [][]float64{{0.04, 0.01}, {0.05, 0.02}}, result)
+
+	_, err = AlignedReturns(model.Portfolio{Assets: []model.Asset{{Ticker: "CCC"}}}, returnsByAsset)
+	require.ErrorContains(t, err, "missing returns for asset CCC")
+}
+
+func TestAlignedReturns_SyntheticAssets(t *testing.T) {
+	fetched := make([]float64, 120)
+	for i := range fetched {
+		fetched[i] = 0.01 * float64(i%7-3)
+	}
+	p := model.Portfolio{
+		Assets: []model.Asset{
+			{Ticker: "STOCKS", Weight: 0.5},
+			{Ticker: "CASH", Weight: 0.2, Synthetic: &model.Synthetic{MonthlyMean: 0.0025}},
+			{Ticker: "BONDS", Weight: 0.3, Synthetic: &model.Synthetic{
+				MonthlyMean:   0.003,
+				MonthlyStdDev: 0.02,
+				Correlations:  map[string]float64{"STOCKS": 0.3},
+			}},
+		},
+	}
+
+	aligned, err := AlignedReturns(p, map[string][]float64{"STOCKS": fetched})
+	require.NoError(t, err)
+	require.Len(t, aligned, len(fetched))
+
+	stocks, bonds := make([]float64, len(aligned)), make([]float64, len(aligned))
+	for i, row := range aligned {
+		require.Equal(t, fetched[i], row[0])
+		require.Equal(t, 0.0025, row[1])
+		stocks[i], bonds[i] = row[0], row[2]
+	}
+	mean, std := 0.0, 0.0
+	for _, v := range bonds {
+		mean += v
+	}
+	mean /= float64(len(bonds))
+	for _, v := range bonds {
+		std += (v - mean) * (v - mean)
+	}
+	std = math.Sqrt(std / float64(len(bonds)-1))
+	require.InDelta(t, 0.003, mean, 1e-12, "The sample mean is exact")
+	require.InDelta(t, 0.02, std, 1e-12, "The sample volatility is exact")
+	require.InDelta(t, 0.3, dot(unitCentered(stocks), unitCentered(bonds)), 1e-9, "The sample correlation is exact")
+
+	again, err := AlignedReturns(p, map[string][]float64{"STOCKS": fetched})
+	require.NoError(t, err)
+	require.Equal(t, aligned, again, "Generated returns are reproducible")
+}
+
+func TestAlignedReturns_SyntheticOnly(t *testing.T) {
+	p := model.Portfolio{Assets: []model.Asset{{Ticker: "CASH", Weight: 1, Synthetic: &model.Synthetic{MonthlyMean: 0.002}}}}
+	returns, err := WeightedMonthlyReturns(p, nil)
+	require.NoError(t, err)
+	require.Len(t, returns, defaultSyntheticMonths)
+	require.Equal(t, 0.002, returns[0])
+}
+
+func TestAlignedReturns_SyntheticInconsistentCorrelations(t *testing.T) {
+	p := model.Portfolio{
+		Assets: []model.Asset{
+			{Ticker: "A", Weight: 0.5},
+			{Ticker: "B", Weight: 0.3},
+			{Ticker: "S", Weight: 0.2, Synthetic: &model.Synthetic{
+				MonthlyStdDev: 0.01,
+				Correlations:  map[string]float64{"A": 0.9, "B": -0.9},
+			}},
+		},
+	}
+	// A and B move together, so S cannot be strongly correlated with one and against the other.
+	a := []float64{0.01, 0.02, -0.01, 0.03, 0.0, -0.02}
+	b := []float64{0.011, 0.019, -0.012, 0.031, 0.001, -0.019}
+	_, err := AlignedReturns(p, map[string][]float64{"A": a, "B": b})
+	require.ErrorContains(t, err, "inconsistent")
+}
+
+func TestAlignedReturns_Assumptions(t *testing.T) {
+	mean, std := 0.005, 0.04
+	p := model.Portfolio{
+		Assets: []model.Asset{
+			{Ticker: "AAA", Weight: 0.5, Assumptions: &model.Assumptions{MonthlyMean: &mean, MonthlyStdDev: &std}},
+			{Ticker: "BBB", Weight: 0.5, Assumptions: &model.Assumptions{MonthlyMean: &mean}},
+		},
+	}
+	history := []float64{0.01, 0.03, -0.02, 0.02}
+	aligned, err := AlignedReturns(p, map[string][]float64{"AAA": history, "BBB": history})
+	require.NoError(t, err)
+
+	// The history has mean 0.01 and standard deviation sqrt(0.0014/3).
+	scale := std / math.Sqrt(0.0014/3)
+	for i, row := range aligned {
+		require.InDelta(t, mean+(history[i]-0.01)*scale, row[0], 1e-12)
+		require.InDelta(t, history[i]-0.005, row[1], 1e-12, "Only the mean is shifted")
+	}
+	require.Equal(t, []float64{0.01, 0.03, -0.02, 0.02}, history, "The fetched returns are not modified")
+
+	_, err = AlignedReturns(p, map[string][]float64{"AAA": {0.01, 0.01}, "BBB": history})
+	require.ErrorContains(t, err, "constant returns")
+}
+
+func TestAlignedReturns_AssumptionsCannotLoseEverything(t *testing.T) {
+	// An annual volatility of 2 is about 58% a month, so the month almost 3 deviations down falls below -100%.
+	std := 2 / math.Sqrt(12)
+	p := model.Portfolio{Assets: []model.Asset{{Ticker: "AAA", Weight: 1, Assumptions: &model.Assumptions{MonthlyStdDev: &std}}}}
+	history := []float64{0.01, 0.01, 0.01, 0.01, 0.01, 0.01, 0.01, 0.01, 0.01, -0.09}
+
+	_, err := AlignedReturns(p, map[string][]float64{"AAA": history})
+	require.ErrorContains(t, err, "a loss of everything")
+}
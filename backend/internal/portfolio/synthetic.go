@@ -0,0 +1,166 @@
+package portfolio
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+
+	"portfolio-simulator/backend/internal/portfolio/model"
+)
+
+// defaultSyntheticMonths is the length of generated histories when no asset has fetched returns.
+const defaultSyntheticMonths = 360
+
+// syntheticReturns generates the monthly returns of the synthetic asset at index k.
+// The series has exactly the requested sample mean and standard deviation, and exactly
+// the requested sample correlations with the columns already known (fetched series and
+// synthetic ones generated earlier). Nil columns are not yet known and are skipped;
+// a later synthetic asset picks up the correlation from either side of the pair.
+// The noise is seeded by the ticker, so the same request always yields the same series.
+func syntheticReturns(p model.Portfolio, k int, columns [][]float64, months int) ([]float64, error) {
+	asset := p.Assets[k]
+	s := asset.Synthetic
+	series := make([]float64, months)
+	if s.MonthlyStdDev == 0 {
+		for i := range series {
+			series[i] = s.MonthlyMean
+		}
+		return series, nil
+	}
+	if months < 3 {
+		return nil, fmt.Errorf("synthetic asset %s needs at least 3 months of history to align with", asset.Ticker)
+	}
+
+	known := make(map[string]bool, len(p.Assets))
+	for j, other := range p.Assets {
+		known[other.Ticker] = known[other.Ticker] || columns[j] != nil
+	}
+	for ticker := range s.Correlations {
+		if _, ok := known[ticker]; !ok || ticker == asset.Ticker {
+			return nil, fmt.Errorf("synthetic asset %s is correlated with unknown asset %s", asset.Ticker, ticker)
+		}
+	}
+
+	// Orthonormalise the known columns (Gram-Schmidt) and solve for the loadings w on
+	// the basis that reproduce the target correlations.
+	var basis [][]float64
+	var loadings []float64
+	for j, column := range columns {
+		if column == nil || j == k {
+			continue
+		}
+		z := unitCentered(column)
+		if z == nil {
+			continue // A constant series cannot be correlated with.
+		}
+		target := correlation(p, k, j)
+		for b, q := range basis {
+			r := dot(q, z)
+			target -= r * loadings[b]
+			for i := range z {
+				z[i] -= r * q[i]
+			}
+		}
+		norm := math.Sqrt(dot(z, z))
+		if norm < 1e-8 {
+			continue // Linearly dependent on earlier columns; its correlation is already implied.
+		}
+		for i := range z {
+			z[i] /= norm
+		}
+		basis = append(basis, z)
+		loadings = append(loadings, target/norm)
+	}
+
+	explained := dot(loadings, loadings)
+	if explained > 1 {
+		return nil, fmt.Errorf("synthetic asset %s has correlations that are inconsistent with the other assets", asset.Ticker)
+	}
+	if months <= len(basis)+1 {
+		return nil, fmt.Errorf("synthetic asset %s needs more months of history than correlated assets", asset.Ticker)
+	}
+
+	// Noise orthogonal to every basis vector carries the unexplained variance.
+	h := fnv.New64a()
+	h.Write([]byte(asset.Ticker))
+	rng := rand.New(rand.NewSource(int64(h.Sum64())))
+	var noise []float64
+	for noise == nil {
+		draws := make([]float64, months)
+		for i := range draws {
+			draws[i] = rng.NormFloat64()
+		}
+		noise = unitCentered(draws)
+		for _, q := range basis {
+			r := dot(q, noise)
+			for i := range noise {
+				noise[i] -= r * q[i]
+			}
+		}
+		if norm := math.Sqrt(dot(noise, noise)); norm > 1e-8 {
+			for i := range noise {
+				noise[i] /= norm
+			}
+		} else {
+			noise = nil
+		}
+	}
+
+	// The unit-norm centred combination has a sample standard deviation of 1/sqrt(months-1).
+	scale := s.MonthlyStdDev * math.Sqrt(float64(months-1))
+	residual := math.Sqrt(1 - explained)
+	for i := range series {
+		v := residual * noise[i]
+		for b, q := range basis {
+			v += loadings[b] * q[i]
+		}
+		series[i] = s.MonthlyMean + scale*v
+	}
+	return series, nil
+}
+
+// correlation returns the requested correlation between the assets at indices k and j.
+func correlation(p model.Portfolio, k, j int) float64 {
+	a, b := p.Assets[k], p.Assets[j]
+	if a.Synthetic != nil {
+		if c, ok := a.Synthetic.Correlations[b.Ticker]; ok {
+			return c
+		}
+	}
+	if b.Synthetic != nil {
+		if c, ok := b.Synthetic.Correlations[a.Ticker]; ok {
+			return c
+		}
+	}
+	return 0
+}
+
+// unitCentered returns values minus their mean, scaled to unit length, or nil if they are constant.
+func unitCentered(values []float64) []float64 {
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	out := make([]float64, len(values))
+	for i, v := range values {
+		out[i] = v - mean
+	}
+	norm := math.Sqrt(dot(out, out))
+	if norm < 1e-12 {
+		return nil
+	}
+	for i := range out {
+		out[i] /= norm
+	}
+	return out
+}
+
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
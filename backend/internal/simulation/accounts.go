@@ -0,0 +1,426 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// TaxType is the tax treatment of an account.
+type TaxType string
+
+const (
+	// TaxTypeTaxable accounts pay capital gains tax on the gain portion of each sale.
+	TaxTypeTaxable TaxType = "taxable"
+	// TaxTypeDeferred accounts pay ordinary income tax on every withdrawal.
+	TaxTypeDeferred TaxType = "tax-deferred"
+	// TaxTypeRoth accounts are withdrawn tax-free.
+	TaxTypeRoth TaxType = "roth"
+)
+
+// WithdrawalOrder selects which accounts fund spending first.
+type WithdrawalOrder string
+
+const (
+	// WithdrawalConventional draws from taxable, then tax-deferred, then Roth accounts. It is the default.
+	WithdrawalConventional WithdrawalOrder = "conventional"
+	// WithdrawalDeferredFirst draws from tax-deferred, then taxable, then Roth accounts.
+	WithdrawalDeferredFirst WithdrawalOrder = "deferred-first"
+	// WithdrawalProportional draws from every account in proportion to its balance.
+	WithdrawalProportional WithdrawalOrder = "proportional"
+)
+
+// Account is one pot of money with its own allocation and tax treatment.
+type Account struct {
+	Name      string
+	Type      TaxType
+	Balance   float64   // Starting balance.
+	CostBasis float64   // Starting cost basis of a taxable account; ignored for other types.
+	Weights   []float64 // Allocation in the column order of Params.AssetReturns, rebalanced monthly.
+}
+
+// TaxModel holds the flat tax rates applied to withdrawals.
+type TaxModel struct {
+	OrdinaryRate     float64 // Rate on tax-deferred withdrawals (e.g. 0.22).
+	CapitalGainsRate float64 // Rate on gains realised in taxable accounts (e.g. 0.15).
+}
+
+// MultiAccount splits the portfolio over several accounts. Withdrawals are treated as
+// after-tax spending: each account is drawn down by enough to cover the spending and
+// the tax due on the withdrawal.
+type MultiAccount struct {
+	Accounts []Account
+	Order    WithdrawalOrder // Empty means WithdrawalConventional.
+	Tax      TaxModel
+	RMD      *RMD // Optional: required minimum distributions from tax-deferred accounts.
+}
+
+// AccountStats reports after-tax results of a multi-account run.
+type AccountStats struct {
+	AfterTaxSpending  []float64    // Total after-tax spending on each path.
+	TaxesPaid         []float64    // Total taxes paid on each path.
+	Spending          SummaryStats // Summary of AfterTaxSpending.
+	Taxes             SummaryStats // Summary of TaxesPaid.
+	AfterTaxTerminal  SummaryStats // Final value net of the tax due if every account were liquidated.
+	ExcessRMD         SummaryStats // Total distributions forced by RMDs beyond spending, per path.
+	MeanFinalBalances []float64    // Mean final balance of each account, in MultiAccount order.
+}
+
+// validate checks the accounts against the number of assets.
+func (m *MultiAccount) validate(assets int) error {
+	if len(m.Accounts) == 0 {
+		return errors.New("simulation: at least one account is required")
+	}
+	for _, a := range m.Accounts {
+		switch a.Type {
+		case TaxTypeTaxable, TaxTypeDeferred, TaxTypeRoth:
+		default:
+			return fmt.Errorf("simulation: account %s has unknown tax type %q", a.Name, a.Type)
+		}
+		if a.Balance < 0 || a.CostBasis < 0 {
+			return fmt.Errorf("simulation: account %s cannot have a negative balance or cost basis", a.Name)
+		}
+		if len(a.Weights) != assets {
+			return fmt.Errorf("simulation: account %s has %d weights, expected %d", a.Name, len(a.Weights), assets)
+		}
+	}
+	switch m.Order {
+	case "", WithdrawalConventional, WithdrawalDeferredFirst, WithdrawalProportional:
+	default:
+		return fmt.Errorf("simulation: unknown withdrawal order %q", m.Order)
+	}
+	if m.Tax.OrdinaryRate < 0 || m.Tax.OrdinaryRate >= 1 || m.Tax.CapitalGainsRate < 0 || m.Tax.CapitalGainsRate >= 1 {
+		return errors.New("simulation: tax rates must be at least 0 and below 1")
+	}
+	if m.RMD != nil {
+		return m.RMD.validate(m.Accounts)
+	}
+	return nil
+}
+
+// sequence returns the account indices in the order they are drawn down.
+func (m *MultiAccount) sequence() []int {
+	priority := []TaxType{TaxTypeTaxable, TaxTypeDeferred, TaxTypeRoth}
+	if m.Order == WithdrawalDeferredFirst {
+		priority = []TaxType{TaxTypeDeferred, TaxTypeTaxable, TaxTypeRoth}
+	}
+	var order []int
+	for _, taxType := range priority {
+		for i, a := range m.Accounts {
+			if a.Type == taxType {
+				order = append(order, i)
+			}
+		}
+	}
+	return order
+}
+
+// accountState is an account's balance and cost basis during a path.
+type accountState struct {
+	taxType TaxType
+	balance float64
+	basis   float64
+
+	yearStart     float64 // Balance at the start of the simulation year.
+	yearWithdrawn float64 // Amount withdrawn so far in the simulation year.
+}
+
+// netFraction returns the share of a withdrawal from the account left after tax.
+func (a *accountState) netFraction(tax TaxModel) float64 {
+	switch a.taxType {
+	case TaxTypeDeferred:
+		return 1 - tax.OrdinaryRate
+	case TaxTypeTaxable:
+		if a.balance <= 0 {
+			return 1
+		}
+		gain := math.Max(1-a.basis/a.balance, 0)
+		return 1 - gain*tax.CapitalGainsRate
+	default:
+		return 1
+	}
+}
+
+// withdraw sells enough of the account to deliver net after tax, or everything if the
+// balance falls short. It returns the amount delivered and the tax paid.
+func (a *accountState) withdraw(net float64, tax TaxModel) (delivered, taxPaid float64) {
+	if net <= 0 || a.balance <= 0 {
+		return 0, 0
+	}
+	f := a.netFraction(tax)
+	gross := net / f
+	if gross >= a.balance {
+		gross = a.balance
+	}
+	if a.taxType == TaxTypeTaxable {
+		a.basis -= a.basis * gross / a.balance
+	}
+	a.balance -= gross
+	a.yearWithdrawn += gross
+	taxPaid = gross * (1 - f)
+	return gross - taxPaid, taxPaid
+}
+
+// liquidationValue returns the balance net of the tax due if it were all withdrawn.
+func (a *accountState) liquidationValue(tax TaxModel) float64 {
+	return a.balance * a.netFraction(tax)
+}
+
+// simulateAccounts runs the multi-account engine: each account earns the returns of its
+// own allocation, drawn from params.AssetReturns with the given method.
+func simulateAccounts(params Params, method string) (*Result, error) {
+	if params.GlidePath != nil || params.Annuity != nil || params.Bucket != nil || params.Leverage != nil {
+		return nil, errors.New("simulation: accounts cannot be combined with a glide path, annuity, cash bucket or leverage")
+	}
+	assets, numAssets, err := newAssetSource(params, method)
+	if err != nil {
+		return nil, err
+	}
+	if assets, err = stressAssets(params, assets, numAssets); err != nil {
+		return nil, err
+	}
+	m := params.Accounts
+	if err := m.validate(numAssets); err != nil {
+		return nil, err
+	}
+	if params.Fees != nil {
+		if err := params.Fees.validate(params); err != nil {
+			return nil, err
+		}
+	}
+
+	w := &accountWalker{
+		params:   params,
+		assets:   assets,
+		returns:  make([]float64, numAssets),
+		order:    m.sequence(),
+		reinvest: reinvestmentAccount(m.Accounts),
+		states:   make([]accountState, len(m.Accounts)),
+		expense:  make([]float64, len(m.Accounts)),
+	}
+	for i, a := range m.Accounts {
+		switch {
+		case params.Fees == nil:
+		case params.Fees.AssetExpenseRatios != nil:
+			for j, weight := range a.Weights {
+				w.expense[i] += weight * params.Fees.AssetExpenseRatios[j] / 12
+			}
+		default:
+			w.expense[i] = params.Fees.ExpenseRatio / 12
+		}
+	}
+
+	tracker := &accountTracker{balances: make([]float64, len(m.Accounts))}
+	result, err := runPathsWith(params, func(withdrawals []float64) pathOutcome {
+		outcome := w.walk(withdrawals)
+		tracker.record(outcome)
+		return outcome
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Accounts = tracker.stats()
+	return result, nil
+}
+
+// accountWalker simulates paths of a multi-account portfolio.
+type accountWalker struct {
+	params   Params
+	assets   vectorSource
+	returns  []float64
+	order    []int          // Account indices in draw-down order.
+	reinvest int            // Index of the taxable account receiving excess RMDs, or -1.
+	states   []accountState // Reused across paths.
+	expense  []float64      // Monthly expense ratio of each account.
+}
+
+// walk simulates a single path. A path is depleted in the first month its accounts
+// cannot cover the spending.
+func (w *accountWalker) walk(withdrawals []float64) pathOutcome {
+	params := w.params
+	m := params.Accounts
+	periods := params.Periods
+	path := make([]float64, periods+1)
+	for i, a := range m.Accounts {
+		w.states[i] = accountState{taxType: a.Type, balance: a.Balance, basis: a.CostBasis}
+	}
+	path[0] = w.total()
+	depletedAt := 0
+	shortfall := 0.0
+	spending, taxes, feesPaid, excessRMD := 0.0, 0.0, 0.0, 0.0
+	w.assets.startPath()
+
+	for t := 1; t <= periods; t++ {
+		if (t-1)%12 == 0 {
+			for i := range w.states {
+				w.states[i].yearStart = w.states[i].balance
+				w.states[i].yearWithdrawn = 0
+			}
+		}
+		w.assets.next(w.returns)
+		for i, a := range m.Accounts {
+			r := 0.0
+			for j, weight := range a.Weights {
+				r += weight * w.returns[j]
+			}
+			w.states[i].balance *= 1 + r
+		}
+
+		if fees := params.Fees; fees != nil {
+			total := w.total()
+			advisory := fees.advisoryFee(total) / 12
+			for i := range w.states {
+				s := &w.states[i]
+				fee := s.balance * w.expense[i]
+				if total > 0 {
+					fee += advisory * s.balance / total
+				}
+				fee = math.Min(fee, s.balance)
+				s.balance -= fee
+				feesPaid += fee
+			}
+		}
+
+		if withdrawals != nil {
+			need := withdrawals[t]
+			delivered, taxPaid := w.withdraw(need)
+			spending += delivered
+			taxes += taxPaid
+			// A tiny relative tolerance absorbs the rounding of the tax gross-up.
+			if delivered < need*(1-1e-9) {
+				for i := range w.states {
+					w.states[i].balance = 0
+				}
+				depletedAt = t
+				shortfall = need - delivered
+			}
+		}
+
+		if m.RMD != nil && t%12 == 0 && depletedAt == 0 {
+			distributed, taxPaid := w.distributeRMD(t/12 - 1)
+			excessRMD += distributed
+			taxes += taxPaid
+		}
+
+		path[t] = w.total()
+		if depletedAt > 0 {
+			break
+		}
+	}
+
+	outcome := pathOutcome{values: path, depletedAt: depletedAt, shortfall: shortfall, feesPaid: feesPaid}
+	outcome.accounts = &accountOutcome{
+		spending:  spending,
+		taxes:     taxes,
+		excessRMD: excessRMD,
+		balances:  make([]float64, len(w.states)),
+	}
+	for i := range w.states {
+		outcome.accounts.balances[i] = w.states[i].balance
+		outcome.accounts.afterTaxTerminal += w.states[i].liquidationValue(m.Tax)
+	}
+	return outcome
+}
+
+// withdraw delivers need after tax following the withdrawal order and returns the
+// amount delivered and the tax paid.
+func (w *accountWalker) withdraw(need float64) (delivered, taxPaid float64) {
+	tax := w.params.Accounts.Tax
+	if w.params.Accounts.Order == WithdrawalProportional {
+		if total := w.total(); total > 0 {
+			for i := range w.states {
+				d, t := w.states[i].withdraw(need*w.states[i].balance/total, tax)
+				delivered += d
+				taxPaid += t
+			}
+		}
+	}
+	// Any remainder, including what proportional accounts could not cover, follows the
+	// conventional order.
+	for _, i := range w.order {
+		d, t := w.states[i].withdraw(need-delivered, tax)
+		delivered += d
+		taxPaid += t
+	}
+	return delivered, taxPaid
+}
+
+// distributeRMD withdraws whatever part of the year's required distributions spending did
+// not cover, pays the tax on it and reinvests the rest in the taxable account. It returns
+// the amount distributed and the tax paid.
+func (w *accountWalker) distributeRMD(year int) (distributed, taxPaid float64) {
+	m := w.params.Accounts
+	for i := range w.states {
+		s := &w.states[i]
+		if s.taxType != TaxTypeDeferred {
+			continue
+		}
+		gross := math.Min(m.RMD.required(year, s.yearStart)-s.yearWithdrawn, s.balance)
+		if gross <= 0 {
+			continue
+		}
+		s.balance -= gross
+		s.yearWithdrawn += gross
+		tax := gross * m.Tax.OrdinaryRate
+		target := &w.states[w.reinvest]
+		target.balance += gross - tax
+		target.basis += gross - tax
+		distributed += gross
+		taxPaid += tax
+	}
+	return distributed, taxPaid
+}
+
+// total returns the combined balance of all accounts.
+func (w *accountWalker) total() float64 {
+	total := 0.0
+	for _, s := range w.states {
+		total += s.balance
+	}
+	return total
+}
+
+// accountOutcome holds the after-tax results of a single multi-account path.
+type accountOutcome struct {
+	spending         float64   // After-tax spending delivered.
+	taxes            float64   // Taxes paid on withdrawals.
+	excessRMD        float64   // Distributions forced by RMDs beyond spending.
+	afterTaxTerminal float64   // Final value net of the tax due on liquidation.
+	balances         []float64 // Final balance of each account.
+}
+
+// accountTracker collects the after-tax results across paths.
+type accountTracker struct {
+	spending, taxes, afterTax []float64
+	excessRMD                 []float64
+	balances                  []float64 // Sum of final balances per account.
+}
+
+func (a *accountTracker) record(outcome pathOutcome) {
+	o := outcome.accounts
+	a.spending = append(a.spending, o.spending)
+	a.taxes = append(a.taxes, o.taxes)
+	a.afterTax = append(a.afterTax, o.afterTaxTerminal)
+	a.excessRMD = append(a.excessRMD, o.excessRMD)
+	for i, b := range o.balances {
+		a.balances[i] += b
+	}
+}
+
+func (a *accountTracker) stats() *AccountStats {
+	means := make([]float64, len(a.balances))
+	if n := len(a.spending); n > 0 {
+		for i, b := range a.balances {
+			means[i] = b / float64(n)
+		}
+	}
+	return &AccountStats{
+		AfterTaxSpending:  a.spending,
+		TaxesPaid:         a.taxes,
+		Spending:          calculateSummary(a.spending),
+		Taxes:             calculateSummary(a.taxes),
+		AfterTaxTerminal:  calculateSummary(a.afterTax),
+		ExcessRMD:         calculateSummary(a.excessRMD),
+		MeanFinalBalances: means,
+	}
+}
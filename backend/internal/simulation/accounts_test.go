@@ -0,0 +1,115 @@
+package simulation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// accountParams returns a flat-return run with a taxable account holding a 50% gain
+// and a tax-deferred account, spending 10 a month.
+func accountParams(order WithdrawalOrder) Params {
+	return Params{
+		InitialValue:   2000,
+		WithdrawalRate: 0.06,
+		Periods:        2,
+		Simulations:    1,
+		AssetReturns:   [][]float64{{0, 0}},
+		Accounts: &MultiAccount{
+			Accounts: []Account{
+				{Name: "brokerage", Type: TaxTypeTaxable, Balance: 1000, CostBasis: 500, Weights: []float64{1, 0}},
+				{Name: "ira", Type: TaxTypeDeferred, Balance: 1000, Weights: []float64{0, 1}},
+			},
+			Order: order,
+			Tax:   TaxModel{OrdinaryRate: 0.25, CapitalGainsRate: 0.2},
+		},
+	}
+}
+
+func TestSimulateBootstrap_AccountsConventionalOrder(t *testing.T) {
+	result, err := SimulateBootstrap(accountParams(WithdrawalConventional))
+	require.NoError(t, err)
+	require.NotNil(t, result.Accounts)
+
+	// Half of each sale is gain taxed at 20%, so 10 of spending costs 10/0.9 from the brokerage.
+	gross := 10 / 0.9
+	require.InDelta(t, 20.0, result.Accounts.AfterTaxSpending[0], 1e-9)
+	require.InDelta(t, 2*(gross-10), result.Accounts.TaxesPaid[0], 1e-9)
+	require.InDeltaSlice(t, []float64{1000 - 2*gross, 1000}, result.Accounts.MeanFinalBalances, 1e-9)
+	require.InDelta(t, 2000-2*gross, result.Paths[0][2], 1e-9)
+	// Liquidating: the brokerage still holds a 50% gain, the IRA pays ordinary tax in full.
+	require.InDelta(t, (1000-2*gross)*0.9+1000*0.75, result.Accounts.AfterTaxTerminal.Mean, 1e-9)
+}
+
+func TestSimulateBootstrap_AccountsDeferredFirst(t *testing.T) {
+	result, err := SimulateBootstrap(accountParams(WithdrawalDeferredFirst))
+	require.NoError(t, err)
+
+	gross := 10 / 0.75
+	require.InDelta(t, 20.0, result.Accounts.AfterTaxSpending[0], 1e-9)
+	require.InDelta(t, 2*(gross-10), result.Accounts.TaxesPaid[0], 1e-9)
+	require.InDeltaSlice(t, []float64{1000, 1000 - 2*gross}, result.Accounts.MeanFinalBalances, 1e-9)
+}
+
+func TestSimulateBootstrap_AccountsProportional(t *testing.T) {
+	result, err := SimulateBootstrap(accountParams(WithdrawalProportional))
+	require.NoError(t, err)
+
+	require.InDelta(t, 20.0, result.Accounts.AfterTaxSpending[0], 1e-9)
+	balances := result.Accounts.MeanFinalBalances
+	require.Less(t, balances[0], 1000.0)
+	require.Less(t, balances[1], 1000.0)
+	// Both accounts fund half of the first month's spending.
+	require.InDelta(t, 5/0.9+5/0.75-10, result.Accounts.TaxesPaid[0]/2, 0.01)
+}
+
+func TestSimulateBootstrap_AccountsDepletion(t *testing.T) {
+	params := accountParams(WithdrawalConventional)
+	params.Periods = 12
+	params.Accounts.Accounts[0].Balance = 15
+	params.Accounts.Accounts[0].CostBasis = 15
+	params.Accounts.Accounts[1].Balance = 0
+
+	result, err := SimulateBootstrap(params)
+	require.NoError(t, err)
+	require.Zero(t, result.SuccessRate)
+	require.InDelta(t, 15.0, result.Accounts.AfterTaxSpending[0], 1e-9, "Only what the accounts held can be spent")
+	require.Zero(t, result.Paths[0][2])
+}
+
+func TestSimulateNormal_AccountsWithFees(t *testing.T) {
+	params := accountParams(WithdrawalConventional)
+	params.AssetReturns = [][]float64{{0.01, 0.004}, {-0.02, 0.003}, {0.03, 0.002}, {0.005, 0.001}}
+	params.Periods = 24
+	params.Simulations = 50
+	params.Seed = 3
+	params.Fees = &Fees{AssetExpenseRatios: []float64{0.001, 0.002}, AdvisoryTiers: []FeeTier{{Rate: 0.01}}}
+
+	result, err := SimulateNormal(params)
+	require.NoError(t, err)
+	require.Len(t, result.Accounts.TaxesPaid, 50)
+	require.Greater(t, result.Fees.AverageFeesPaid, 0.0)
+	require.Greater(t, result.Fees.MeanTerminalDifference, 0.0)
+}
+
+func TestMultiAccount_Validate(t *testing.T) {
+	m := accountParams("").Accounts
+	require.NoError(t, m.validate(2))
+	require.ErrorContains(t, m.validate(3), "expected 3")
+
+	m.Order = "alphabetical"
+	require.ErrorContains(t, m.validate(2), "unknown withdrawal order")
+
+	m = accountParams("").Accounts
+	m.Accounts[1].Type = "hsa"
+	require.ErrorContains(t, m.validate(2), "unknown tax type")
+
+	m = accountParams("").Accounts
+	m.Tax.OrdinaryRate = 1
+	require.ErrorContains(t, m.validate(2), "tax rates")
+
+	params := accountParams("")
+	params.Bucket = &Bucket{Years: 1}
+	_, err := SimulateBootstrap(params)
+	require.ErrorContains(t, err, "cannot be combined")
+}
@@ -0,0 +1,71 @@
+package simulation
+
+import "math"
+
+// Annuity describes the purchase of a single-premium immediate annuity (SPIA).
+// The premium is taken from the portfolio at the end of Period, and level monthly
+// payments start the following period. Payments offset withdrawals; any income in
+// excess of the withdrawal is reinvested in the portfolio.
+type Annuity struct {
+	Period     int     // Period at whose end the annuity is bought; 0 buys it at the start.
+	Fraction   float64 // Share of the portfolio value used as the premium (e.g. 0.3). Ignored if Amount is set.
+	Amount     float64 // Fixed premium, capped at the portfolio value at purchase.
+	PayoutRate float64 // Annual payout as a share of the premium (e.g. 0.065).
+	COLA       float64 // Annual cost-of-living increase applied to the payments (e.g. 0.02).
+}
+
+// AnnuityComparison contrasts a run that buys the annuity with one that keeps everything invested.
+type AnnuityComparison struct {
+	PurchaseRate         float64 // Share of paths still solvent at the purchase period, all of which bought the annuity.
+	AveragePremium       float64 // Mean premium among paths that bought the annuity.
+	AverageMonthlyIncome float64 // Mean first monthly payment among paths that bought the annuity.
+	WithAnnuity          Outcome // Outcome of the run with the annuity.
+	WithoutAnnuity       Outcome // Outcome of the same paths without the annuity.
+}
+
+// premium returns the premium paid out of a portfolio worth value.
+func (a *Annuity) premium(value float64) float64 {
+	if a.Amount > 0 {
+		return math.Min(a.Amount, value)
+	}
+	return a.Fraction * value
+}
+
+// payment returns the monthly annuity income in period t for the given premium.
+func (a *Annuity) payment(premium float64, t int) float64 {
+	if premium <= 0 || t <= a.Period {
+		return 0
+	}
+	yearsSincePurchase := (t - a.Period - 1) / 12
+	return premium * a.PayoutRate / 12 * math.Pow(1+a.COLA, float64(yearsSincePurchase))
+}
+
+// annuityTracker accumulates purchase statistics across paths.
+type annuityTracker struct {
+	annuity    *Annuity
+	paths      int
+	purchases  int
+	premiumSum float64
+	incomeSum  float64
+}
+
+func (a *annuityTracker) record(outcome pathOutcome) {
+	a.paths++
+	if outcome.premium > 0 {
+		a.purchases++
+		a.premiumSum += outcome.premium
+		a.incomeSum += a.annuity.payment(outcome.premium, a.annuity.Period+1)
+	}
+}
+
+func (a *annuityTracker) comparison() *AnnuityComparison {
+	c := &AnnuityComparison{}
+	if a.paths > 0 {
+		c.PurchaseRate = float64(a.purchases) / float64(a.paths)
+	}
+	if a.purchases > 0 {
+		c.AveragePremium = a.premiumSum / float64(a.purchases)
+		c.AverageMonthlyIncome = a.incomeSum / float64(a.purchases)
+	}
+	return c
+}
@@ -0,0 +1,91 @@
+package simulation
+
+import "math"
+
+// brownianBridge maps a vector of independent standard normal draws onto the
+// increments of a Brownian motion with unit time steps. The first draw fixes the
+// terminal value, the second the midpoint, and so on by repeated bisection, so
+// the leading (best-distributed) quasi-random dimensions govern the large-scale
+// shape of each path.
+type brownianBridge struct {
+	size        int
+	bridgeIndex []int
+	leftIndex   []int
+	rightIndex  []int
+	leftWeight  []float64
+	rightWeight []float64
+	stdDev      []float64
+	path        []float64 // Scratch buffer for the cumulative path.
+}
+
+// newBrownianBridge precomputes the construction order for a path of size steps.
+func newBrownianBridge(size int) *brownianBridge {
+	b := &brownianBridge{
+		size:        size,
+		bridgeIndex: make([]int, size),
+		leftIndex:   make([]int, size),
+		rightIndex:  make([]int, size),
+		leftWeight:  make([]float64, size),
+		rightWeight: make([]float64, size),
+		stdDev:      make([]float64, size),
+		path:        make([]float64, size),
+	}
+	// Time of step i is i+1.
+	at := func(i int) float64 { return float64(i + 1) }
+
+	filled := make([]int, size)
+	filled[size-1] = 1
+	b.bridgeIndex[0] = size - 1
+	b.stdDev[0] = math.Sqrt(at(size - 1))
+
+	j := 0
+	for i := 1; i < size; i++ {
+		for filled[j] != 0 {
+			j++
+		}
+		k := j
+		for filled[k] == 0 {
+			k++
+		}
+		// l is the midpoint of the unfilled run [j, k-1].
+		l := j + ((k - 1 - j) >> 1)
+		filled[l] = i
+		b.bridgeIndex[i] = l
+		b.leftIndex[i] = j
+		b.rightIndex[i] = k
+
+		left := 0.0
+		if j != 0 {
+			left = at(j - 1)
+		}
+		span := at(k) - left
+		b.leftWeight[i] = (at(k) - at(l)) / span
+		b.rightWeight[i] = (at(l) - left) / span
+		b.stdDev[i] = math.Sqrt((at(l) - left) * (at(k) - at(l)) / span)
+
+		j = k + 1
+		if j >= size {
+			j = 0
+		}
+	}
+	return b
+}
+
+// transform converts the standard normal draws z into per-step increments,
+// written to out. Each increment is itself standard normal.
+func (b *brownianBridge) transform(z, out []float64) {
+	p := b.path
+	p[b.size-1] = b.stdDev[0] * z[0]
+	for i := 1; i < b.size; i++ {
+		j, k, l := b.leftIndex[i], b.rightIndex[i], b.bridgeIndex[i]
+		if j != 0 {
+			p[l] = b.leftWeight[i]*p[j-1] + b.rightWeight[i]*p[k] + b.stdDev[i]*z[i]
+		} else {
+			p[l] = b.rightWeight[i]*p[k] + b.stdDev[i]*z[i]
+		}
+	}
+	out[0] = p[0]
+	for i := 1; i < b.size; i++ {
+		out[i] = p[i] - p[i-1]
+	}
+}
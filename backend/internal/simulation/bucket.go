@@ -0,0 +1,96 @@
+package simulation
+
+import "math"
+
+// RefillRule selects when the cash bucket is topped up from the invested portfolio.
+type RefillRule string
+
+const (
+	// RefillMonthly tops the bucket up every month.
+	RefillMonthly RefillRule = "monthly"
+	// RefillAnnual tops the bucket up at the end of every year.
+	RefillAnnual RefillRule = "annual"
+	// RefillUpYears tops the bucket up at the end of a year only if the invested
+	// portfolio gained over that year, so equities are never sold after a down year.
+	RefillUpYears RefillRule = "up-years"
+)
+
+// Bucket describes a cash reserve strategy: withdrawals are paid from a cash bucket
+// sized to cover Years of spending, and the bucket is refilled from the invested
+// portfolio according to Refill. When the bucket runs dry, the shortfall is sold
+// from the invested portfolio.
+type Bucket struct {
+	Years  float64    // Years of withdrawals held in cash (e.g. 2).
+	Yield  float64    // Annual yield earned by the cash bucket (e.g. 0.03).
+	Refill RefillRule // When the bucket is refilled.
+}
+
+// BucketComparison contrasts the bucket strategy with withdrawing directly from the portfolio.
+type BucketComparison struct {
+	EmptiedRate        float64 // Share of paths on which the bucket ran dry at least once.
+	AverageEmptyMonths float64 // Mean number of months per path in which the bucket could not cover the withdrawal.
+	WithBucket         Outcome // Outcome with the cash bucket.
+	WithoutBucket      Outcome // Outcome of the same paths withdrawing directly from the portfolio.
+}
+
+// months returns the number of months of withdrawals the bucket holds.
+func (b *Bucket) months() int {
+	return int(math.Round(b.Years * 12))
+}
+
+// monthlyGrowth returns the monthly growth factor of the cash bucket.
+func (b *Bucket) monthlyGrowth() float64 {
+	return math.Pow(1+b.Yield, 1.0/12)
+}
+
+// target returns the cash needed to fund the withdrawals of the months after period t.
+func (b *Bucket) target(withdrawals []float64, t int) float64 {
+	if withdrawals == nil {
+		return 0
+	}
+	end := min(t+b.months(), len(withdrawals)-1)
+	total := 0.0
+	for i := t + 1; i <= end; i++ {
+		total += withdrawals[i]
+	}
+	return total
+}
+
+// refillDue reports whether the bucket is refilled at the end of period t, given
+// the invested portfolio's growth factor over the year to date.
+func (b *Bucket) refillDue(t int, yearGrowth float64) bool {
+	switch b.Refill {
+	case RefillMonthly:
+		return true
+	case RefillAnnual:
+		return t%12 == 0
+	case RefillUpYears:
+		return t%12 == 0 && yearGrowth > 1
+	default:
+		return false
+	}
+}
+
+// bucketTracker accumulates how often the bucket ran dry across paths.
+type bucketTracker struct {
+	paths       int
+	emptiedPath int
+	emptyMonths int
+}
+
+func (b *bucketTracker) record(outcome pathOutcome) {
+	b.paths++
+	b.emptyMonths += outcome.bucketEmptyMonths
+	if outcome.bucketEmptyMonths > 0 {
+		b.emptiedPath++
+	}
+}
+
+func (b *bucketTracker) comparison() *BucketComparison {
+	c := &BucketComparison{}
+	if b.paths > 0 {
+		c.EmptiedRate = float64(b.emptiedPath) / float64(b.paths)
+		c.AverageEmptyMonths = float64(b.emptyMonths) / float64(b.paths)
+	}
+	return c
+}
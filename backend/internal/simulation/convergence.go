@@ -0,0 +1,44 @@
+package simulation
+
+import "math"
+
+// confidenceZ is the standard normal quantile for a two-sided 95% confidence interval.
+const confidenceZ = 1.959963984540054
+
+// ConvergencePoint records the success-rate estimate after a batch of simulated paths.
+type ConvergencePoint struct {
+	Simulations int     // Cumulative number of paths simulated.
+	SuccessRate float64 // Success-rate estimate over all paths so far.
+	StdError    float64 // Standard error of the success-rate estimate.
+	Lower       float64 // Lower bound of the 95% Wilson score interval.
+	Upper       float64 // Upper bound of the 95% Wilson score interval.
+}
+
+// newConvergencePoint summarises successes out of n independent paths.
+//
+// The standard error uses the Agresti-Coull adjusted proportion so that it does not
+// collapse to zero when every path succeeds or fails, which would otherwise stop an
+// adaptive run after its first batch. For quasi-random samplers the binomial error is
+// conservative, as the estimate typically converges faster than for independent paths.
+func newConvergencePoint(successes, n int) ConvergencePoint {
+	if n == 0 {
+		return ConvergencePoint{}
+	}
+	nf := float64(n)
+	p := float64(successes) / nf
+	z2 := confidenceZ * confidenceZ
+
+	adjusted := (float64(successes) + z2/2) / (nf + z2)
+	stdErr := math.Sqrt(adjusted * (1 - adjusted) / nf)
+
+	centre := (p + z2/(2*nf)) / (1 + z2/nf)
+	halfWidth := confidenceZ / (1 + z2/nf) * math.Sqrt(p*(1-p)/nf+z2/(4*nf*nf))
+
+	return ConvergencePoint{
+		Simulations: n,
+		SuccessRate: p,
+		StdError:    stdErr,
+		Lower:       math.Max(0, centre-halfWidth),
+		Upper:       math.Min(1, centre+halfWidth),
+	}
+}
@@ -0,0 +1,108 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// FeeTier is one band of a tiered advisory fee schedule. The rate applies to the
+// part of the balance above Above and below the next tier's threshold.
+type FeeTier struct {
+	Above float64 // Balance at which the tier starts; the first tier must start at 0.
+	Rate  float64 // Annual fee on the balance within the tier (e.g. 0.01 for 1%).
+}
+
+// Fees describes the ongoing costs deducted from the portfolio each month.
+// Expense ratios are charged on the invested portfolio, and the advisory fee on
+// the whole balance including any cash bucket.
+type Fees struct {
+	ExpenseRatio       float64   // Annual expense ratio of the portfolio, weighted by its allocation.
+	AssetExpenseRatios []float64 // Annual expense ratio per asset; with a glide path these are weighted by each period's allocation instead.
+	AdvisoryTiers      []FeeTier // Advisory fee schedule; a single tier starting at 0 is a flat fee on assets under management.
+}
+
+// FeeStats reports the cost of fees across all paths.
+type FeeStats struct {
+	AverageFeesPaid          float64 // Mean total fees paid per path.
+	MedianFeesPaid           float64 // Median total fees paid per path.
+	MeanTerminalDifference   float64 // Mean terminal value without fees minus with fees.
+	MedianTerminalDifference float64 // Median terminal value without fees minus with fees.
+	WithFees                 Outcome // Outcome with fees.
+	WithoutFees              Outcome // Outcome of the same paths without fees.
+}
+
+// validate checks the fee schedule against the simulation parameters.
+func (f *Fees) validate(params Params) error {
+	if f.ExpenseRatio < 0 {
+		return errors.New("simulation: expense ratio cannot be negative")
+	}
+	for _, r := range f.AssetExpenseRatios {
+		if r < 0 {
+			return errors.New("simulation: expense ratios cannot be negative")
+		}
+	}
+	if f.AssetExpenseRatios != nil && len(params.AssetReturns) > 0 && len(f.AssetExpenseRatios) != len(params.AssetReturns[0]) {
+		return fmt.Errorf("simulation: got %d asset expense ratios, expected %d", len(f.AssetExpenseRatios), len(params.AssetReturns[0]))
+	}
+	for i, tier := range f.AdvisoryTiers {
+		if i == 0 && tier.Above != 0 {
+			return errors.New("simulation: the first advisory fee tier must start at 0")
+		}
+		if i > 0 && tier.Above <= f.AdvisoryTiers[i-1].Above {
+			return errors.New("simulation: advisory fee tiers must have increasing thresholds")
+		}
+		if tier.Rate < 0 {
+			return errors.New("simulation: advisory fee rates cannot be negative")
+		}
+	}
+	return nil
+}
+
+// advisoryFee returns the annual advisory fee on the given balance.
+func (f *Fees) advisoryFee(balance float64) float64 {
+	fee := 0.0
+	for i, tier := range f.AdvisoryTiers {
+		if balance <= tier.Above {
+			break
+		}
+		upper := balance
+		if i+1 < len(f.AdvisoryTiers) {
+			upper = math.Min(balance, f.AdvisoryTiers[i+1].Above)
+		}
+		fee += (upper - tier.Above) * tier.Rate
+	}
+	return fee
+}
+
+// monthlyExpenseRatios returns the monthly expense ratio charged in each period from 1 to periods.
+func (f *Fees) monthlyExpenseRatios(params Params) []float64 {
+	ratios := make([]float64, params.Periods+1)
+	if params.GlidePath != nil && f.AssetExpenseRatios != nil {
+		allocations := params.GlidePath.Allocations(params.Periods)
+		for t := 1; t <= params.Periods; t++ {
+			for i, w := range allocations[t-1] {
+				ratios[t] += w * f.AssetExpenseRatios[i] / 12
+			}
+		}
+		return ratios
+	}
+	for t := 1; t <= params.Periods; t++ {
+		ratios[t] = f.ExpenseRatio / 12
+	}
+	return ratios
+}
+
+// feeTracker collects the fees paid on each path.
+type feeTracker struct {
+	paid []float64
+}
+
+func (f *feeTracker) record(outcome pathOutcome) {
+	f.paid = append(f.paid, outcome.feesPaid)
+}
+
+func (f *feeTracker) stats() *FeeStats {
+	summary := calculateSummary(f.paid)
+	return &FeeStats{AverageFeesPaid: summary.Mean, MedianFeesPaid: summary.Median}
+}
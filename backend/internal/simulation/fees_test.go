@@ -0,0 +1,96 @@
+package simulation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFees_AdvisoryFee(t *testing.T) {
+	flat := &Fees{AdvisoryTiers: []FeeTier{{Above: 0, Rate: 0.01}}}
+	require.InDelta(t, 5000.0, flat.advisoryFee(500000), 1e-9)
+
+	tiered := &Fees{AdvisoryTiers: []FeeTier{
+		{Above: 0, Rate: 0.01},
+		{Above: 1000000, Rate: 0.005},
+	}}
+	require.InDelta(t, 5000.0, tiered.advisoryFee(500000), 1e-9)
+	require.InDelta(t, 10000.0+2500.0, tiered.advisoryFee(1500000), 1e-9, "Each tier charges only the balance within it")
+	require.Zero(t, tiered.advisoryFee(0))
+}
+
+func TestFees_Validate(t *testing.T) {
+	require.ErrorContains(t, (&Fees{AdvisoryTiers: []FeeTier{{Above: 100, Rate: 0.01}}}).validate(Params{}), "start at 0")
+	require.ErrorContains(t, (&Fees{AdvisoryTiers: []FeeTier{
+		{Above: 0, Rate: 0.01},
+		{Above: 0, Rate: 0.005},
+	}}).validate(Params{}), "increasing thresholds")
+	require.Error(t, (&Fees{ExpenseRatio: -0.001}).validate(Params{}))
+	require.ErrorContains(t, (&Fees{AssetExpenseRatios: []float64{0.001}}).validate(Params{
+		AssetReturns: [][]float64{{0.01, 0.02}},
+	}), "expected 2")
+}
+
+func TestRunSimulationPaths_FeesDeductedMonthly(t *testing.T) {
+	// Flat returns, 1.2% expense ratio and 1.2% advisory fee: 0.2% of the balance a month.
+	params := Params{
+		InitialValue: 1000,
+		Simulations:  1,
+		Periods:      2,
+		Fees: &Fees{
+			ExpenseRatio:  0.012,
+			AdvisoryTiers: []FeeTier{{Above: 0, Rate: 0.012}},
+		},
+	}
+	result, err := runSimulationPaths(params, func() float64 { return 0 })
+	require.NoError(t, err)
+
+	// Both fees are charged on the balance after the month's return.
+	month1 := 1000 * 0.998
+	require.InDelta(t, month1, result.Paths[0][1], 1e-9)
+	month2 := month1 * 0.998
+	require.InDelta(t, month2, result.Paths[0][2], 1e-9)
+	require.NotNil(t, result.Fees)
+	require.InDelta(t, 1000-month2, result.Fees.AverageFeesPaid, 1e-9)
+}
+
+func TestSimulateBootstrap_FeeComparison(t *testing.T) {
+	params := Params{
+		InitialValue:   1000000,
+		Returns:        []float64{0.01, -0.02, 0.015, 0.005, 0.02, -0.01},
+		WithdrawalRate: 0.04,
+		Periods:        120,
+		Simulations:    200,
+		Seed:           7,
+		Fees:           &Fees{ExpenseRatio: 0.005, AdvisoryTiers: []FeeTier{{Above: 0, Rate: 0.01}}},
+	}
+	result, err := SimulateBootstrap(params)
+	require.NoError(t, err)
+	require.NotNil(t, result.Fees)
+
+	fees := result.Fees
+	require.Greater(t, fees.AverageFeesPaid, 0.0)
+	require.Equal(t, result.FinalStats.Mean, fees.WithFees.MeanTerminal)
+	require.Greater(t, fees.MeanTerminalDifference, 0.0)
+	require.GreaterOrEqual(t, fees.WithoutFees.SuccessRate, fees.WithFees.SuccessRate)
+	require.InDelta(t, fees.WithoutFees.MeanTerminal-fees.WithFees.MeanTerminal, fees.MeanTerminalDifference, 1e-6)
+}
+
+func TestSimulateBootstrap_GlidePathAssetExpenseRatios(t *testing.T) {
+	// Only the second asset has a fee, and the glide path moves fully into it at period 1.
+	params := Params{
+		InitialValue: 1000,
+		AssetReturns: [][]float64{{0, 0}},
+		Simulations:  1,
+		Periods:      2,
+		GlidePath: &GlidePath{Points: []GlidePoint{
+			{Period: 0, Weights: []float64{1, 0}},
+			{Period: 1, Weights: []float64{0, 1}},
+		}, Interpolation: InterpolationStep},
+		Fees: &Fees{AssetExpenseRatios: []float64{0, 0.012}},
+	}
+	result, err := SimulateBootstrap(params)
+	require.NoError(t, err)
+	require.InDelta(t, 1000.0, result.Paths[0][1], 1e-9, "No fee while fully in the fee-free asset")
+	require.InDelta(t, 999.0, result.Paths[0][2], 1e-9)
+}
@@ -0,0 +1,85 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Interpolation selects how a glide path moves between its breakpoints.
+type Interpolation string
+
+const (
+	// InterpolationLinear moves the allocation linearly from one breakpoint to the next.
+	InterpolationLinear Interpolation = "linear"
+	// InterpolationStep holds each breakpoint's allocation until the next breakpoint.
+	InterpolationStep Interpolation = "step"
+)
+
+// GlidePoint fixes the portfolio allocation at a given period.
+type GlidePoint struct {
+	Period  int       // Period at which the allocation applies.
+	Weights []float64 // Weight of each asset, in the column order of Params.AssetReturns.
+}
+
+// GlidePath describes a time-varying allocation. Before the first breakpoint the
+// first allocation applies, and after the last breakpoint the last one does. The
+// path may move in either direction, so de-risking and rising-equity paths are
+// both expressed the same way.
+type GlidePath struct {
+	Points        []GlidePoint
+	Interpolation Interpolation
+}
+
+// validate checks the glide path against the number of assets.
+func (g *GlidePath) validate(assets int) error {
+	if len(g.Points) == 0 {
+		return errors.New("simulation: glide path must have at least one point")
+	}
+	for i, p := range g.Points {
+		if len(p.Weights) != assets {
+			return fmt.Errorf("simulation: glide path point %d has %d weights, expected %d", i, len(p.Weights), assets)
+		}
+		if i > 0 && p.Period <= g.Points[i-1].Period {
+			return errors.New("simulation: glide path periods must be strictly increasing")
+		}
+	}
+	switch g.Interpolation {
+	case "", InterpolationLinear, InterpolationStep:
+		return nil
+	default:
+		return fmt.Errorf("simulation: unknown glide path interpolation %q", g.Interpolation)
+	}
+}
+
+// Allocations returns the allocation at each period from 0 to periods inclusive.
+// The allocation at period t-1 is held through period t, i.e. the portfolio is
+// rebalanced to the glide path at the start of every month.
+func (g *GlidePath) Allocations(periods int) [][]float64 {
+	allocations := make([][]float64, periods+1)
+	for t := range allocations {
+		allocations[t] = g.at(t)
+	}
+	return allocations
+}
+
+// at returns the allocation at period t.
+func (g *GlidePath) at(t int) []float64 {
+	points := g.Points
+	// Index of the first breakpoint strictly after t.
+	next := sort.Search(len(points), func(i int) bool { return points[i].Period > t })
+	switch {
+	case next == 0:
+		return append([]float64(nil), points[0].Weights...)
+	case next == len(points) || g.Interpolation == InterpolationStep:
+		return append([]float64(nil), points[next-1].Weights...)
+	}
+
+	from, to := points[next-1], points[next]
+	frac := float64(t-from.Period) / float64(to.Period-from.Period)
+	weights := make([]float64, len(from.Weights))
+	for i := range weights {
+		weights[i] = from.Weights[i] + frac*(to.Weights[i]-from.Weights[i])
+	}
+	return weights
+}
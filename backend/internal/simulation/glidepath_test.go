@@ -0,0 +1,142 @@
+package simulation
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlidePath_Allocations(t *testing.T) {
+	points := []GlidePoint{
+		{Period: 2, Weights: []float64{0.9, 0.1}},
+		{Period: 6, Weights: []float64{0.5, 0.5}},
+	}
+
+	linear := &GlidePath{Points: points, Interpolation: InterpolationLinear}
+	allocations := linear.Allocations(8)
+	require.Len(t, allocations, 9)
+	require.Equal(t, []float64{0.9, 0.1}, allocations[0], "The first point applies before it")
+	require.Equal(t, []float64{0.9, 0.1}, allocations[2])
+	require.InDeltaSlice(t, []float64{0.8, 0.2}, allocations[3], 1e-12)
+	require.InDeltaSlice(t, []float64{0.6, 0.4}, allocations[5], 1e-12)
+	require.Equal(t, []float64{0.5, 0.5}, allocations[6])
+	require.Equal(t, []float64{0.5, 0.5}, allocations[8], "The last point applies after it")
+
+	step := &GlidePath{Points: points, Interpolation: InterpolationStep}
+	allocations = step.Allocations(8)
+	require.Equal(t, []float64{0.9, 0.1}, allocations[5])
+	require.Equal(t, []float64{0.5, 0.5}, allocations[6])
+}
+
+func TestGlidePath_Validate(t *testing.T) {
+	require.Error(t, (&GlidePath{}).validate(2))
+	require.ErrorContains(t, (&GlidePath{Points: []GlidePoint{{Weights: []float64{1}}}}).validate(2), "expected 2")
+	require.ErrorContains(t, (&GlidePath{Points: []GlidePoint{
+		{Period: 5, Weights: []float64{1, 0}},
+		{Period: 5, Weights: []float64{0, 1}},
+	}}).validate(2), "strictly increasing")
+	require.ErrorContains(t, (&GlidePath{
+		Points:        []GlidePoint{{Weights: []float64{1, 0}}},
+		Interpolation: "cubic",
+	}).validate(2), "unknown glide path interpolation")
+}
+
+func TestSimulateBootstrap_RisingEquityGlidePath(t *testing.T) {
+	// Equity returns 2% a month, bonds nothing; equity rises from 0% to 100% over 10 months.
+	params := Params{
+		InitialValue: 1000,
+		AssetReturns: [][]float64{{0.02, 0.0}},
+		Simulations:  5,
+		Periods:      12,
+		GlidePath: &GlidePath{Points: []GlidePoint{
+			{Period: 0, Weights: []float64{0.0, 1.0}},
+			{Period: 10, Weights: []float64{1.0, 0.0}},
+		}},
+	}
+	result, err := SimulateBootstrap(params)
+	require.NoError(t, err)
+	require.Len(t, result.Allocations, params.Periods+1)
+
+	// The allocation set at the start of each month earns that month's returns.
+	expected := params.InitialValue
+	for t := 1; t <= params.Periods; t++ {
+		expected *= 1 + 0.02*math.Min(float64(t-1)/10, 1)
+	}
+	for _, path := range result.Paths {
+		require.InDelta(t, expected, path[params.Periods], 1e-9)
+	}
+}
+
+func TestSimulateNormal_GlidePathMatchesPortfolioMoments(t *testing.T) {
+	history := [][]float64{
+		{0.03, 0.01}, {-0.02, 0.00}, {0.04, 0.005}, {-0.01, 0.01}, {0.02, -0.005}, {0.00, 0.002},
+	}
+	glide := &GlidePath{Points: []GlidePoint{{Weights: []float64{0.6, 0.4}}}}
+
+	for _, sampler := range []Sampler{SamplerPseudoRandom, SamplerSobol} {
+		t.Run(string(sampler), func(t *testing.T) {
+			params := Params{
+				InitialValue: 1000,
+				AssetReturns: history,
+				Simulations:  2048,
+				Periods:      12,
+				Sampler:      sampler,
+				Seed:         3,
+				GlidePath:    glide,
+			}
+			result, err := SimulateNormal(params)
+			require.NoError(t, err)
+
+			// A static allocation has the mean growth of the weighted historical mean.
+			mean := 0.0
+			for _, row := range history {
+				mean += (0.6*row[0] + 0.4*row[1]) / float64(len(history))
+			}
+			expected := params.InitialValue * math.Pow(1+mean, float64(params.Periods))
+			require.InEpsilon(t, expected, result.FinalStats.Mean, 0.005)
+		})
+	}
+}
+
+func TestSimulateBootstrap_GlidePathRejectsSobol(t *testing.T) {
+	params := Params{
+		InitialValue: 1000,
+		AssetReturns: [][]float64{{0.01, 0.02}},
+		Simulations:  1,
+		Periods:      1,
+		Sampler:      SamplerSobol,
+		GlidePath:    &GlidePath{Points: []GlidePoint{{Weights: []float64{0.5, 0.5}}}},
+	}
+	_, err := SimulateBootstrap(params)
+	require.ErrorContains(t, err, "only supported by parametric methods")
+}
+
+func TestCholesky(t *testing.T) {
+	cov := [][]float64{
+		{4, 2, 0},
+		{2, 5, 0},
+		{0, 0, 0}, // A constant asset contributes no variance.
+	}
+	l := cholesky(cov)
+	for i := range cov {
+		for j := range cov {
+			sum := 0.0
+			for k := range cov {
+				sum += l[i][k] * l[j][k]
+			}
+			require.InDelta(t, cov[i][j], sum, 1e-12, "entry (%d,%d)", i, j)
+		}
+	}
+
+	// A duplicated asset makes the covariance singular but still factorisable.
+	duplicate := [][]float64{{1, 1}, {1, 1}}
+	l = cholesky(duplicate)
+	require.Equal(t, [][]float64{{1, 0}, {1, 0}}, l)
+}
+
+func TestMeanCovariance(t *testing.T) {
+	mean, cov := meanCovariance([][]float64{{1, 2}, {3, 6}})
+	require.Equal(t, []float64{2, 4}, mean)
+	require.Equal(t, [][]float64{{2, 4}, {4, 8}}, cov)
+}
@@ -0,0 +1,83 @@
+package simulation
+
+import (
+	"errors"
+	"math"
+)
+
+// Leverage borrows against the invested portfolio so that it holds Ratio times its
+// equity. Interest accrues monthly on the loan. When withdrawals or losses push equity
+// below MaintenanceRatio of the invested assets, a margin call sells assets to repay
+// debt until the target ratio is restored. The loan is not otherwise rebalanced.
+type Leverage struct {
+	Ratio            float64   // Target assets per unit of equity (e.g. 1.5).
+	BorrowRate       float64   // Fixed annual borrowing rate, used when CashRates is empty.
+	CashRates        []float64 // Optional annual cash rate for each period, starting with period 1; the last value holds afterwards.
+	Spread           float64   // Annual spread over CashRates.
+	MaintenanceRatio float64   // Minimum equity as a share of invested assets (e.g. 0.25).
+}
+
+// LeverageStats reports margin calls and compares leveraged and unleveraged outcomes.
+type LeverageStats struct {
+	MarginCallRate      float64      // Share of paths with at least one margin call.
+	AverageMarginCalls  float64      // Mean number of margin calls per path.
+	LeveragedTerminal   SummaryStats // Final equity with leverage.
+	UnleveragedTerminal SummaryStats // Final value of the same paths without leverage.
+	WithLeverage        Outcome
+	WithoutLeverage     Outcome
+}
+
+// validate checks the leverage settings.
+func (l *Leverage) validate() error {
+	if l.Ratio < 1 {
+		return errors.New("simulation: leverage ratio must be at least 1")
+	}
+	if l.MaintenanceRatio <= 0 || l.MaintenanceRatio >= 1/l.Ratio {
+		return errors.New("simulation: maintenance ratio must be above 0 and below the equity share of the target leverage")
+	}
+	return nil
+}
+
+// monthlyRate returns the borrowing rate for period t as a monthly rate.
+func (l *Leverage) monthlyRate(t int) float64 {
+	if len(l.CashRates) == 0 {
+		return l.BorrowRate / 12
+	}
+	i := min(t-1, len(l.CashRates)-1)
+	return (l.CashRates[i] + l.Spread) / 12
+}
+
+// marginCall reports whether equity has fallen below the maintenance requirement.
+func (l *Leverage) marginCall(assets, debt float64) bool {
+	return debt > 0 && assets > 0 && assets-debt < l.MaintenanceRatio*assets
+}
+
+// deleverage sells assets to repay debt until assets are Ratio times equity, or the
+// debt is repaid. It returns the new assets and debt.
+func (l *Leverage) deleverage(assets, debt float64) (float64, float64) {
+	equity := assets - debt
+	sale := math.Min(math.Max(assets-l.Ratio*equity, 0), math.Min(debt, assets))
+	return assets - sale, debt - sale
+}
+
+// leverageTracker collects margin calls across paths.
+type leverageTracker struct {
+	paths, called, calls int
+}
+
+func (l *leverageTracker) record(outcome pathOutcome) {
+	l.paths++
+	l.calls += outcome.marginCalls
+	if outcome.marginCalls > 0 {
+		l.called++
+	}
+}
+
+func (l *leverageTracker) stats() *LeverageStats {
+	s := &LeverageStats{}
+	if l.paths > 0 {
+		s.MarginCallRate = float64(l.called) / float64(l.paths)
+		s.AverageMarginCalls = float64(l.calls) / float64(l.paths)
+	}
+	return s
+}
@@ -0,0 +1,110 @@
+package simulation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// sequence returns a generator yielding the given returns in order, then zeros.
+func sequence(returns ...float64) func() float64 {
+	i := 0
+	return func() float64 {
+		if i >= len(returns) {
+			return 0
+		}
+		i++
+		return returns[i-1]
+	}
+}
+
+func TestRunSimulationPaths_LeverageAmplifiesReturns(t *testing.T) {
+	params := Params{
+		InitialValue: 1000,
+		Simulations:  1,
+		Periods:      2,
+		Leverage:     &Leverage{Ratio: 2, BorrowRate: 0.12, MaintenanceRatio: 0.25},
+	}
+	result, err := runSimulationPaths(params, sequence(0.01, 0))
+	require.NoError(t, err)
+
+	// 2000 invested on 1000 of debt; the debt accrues 1% a month.
+	require.InDelta(t, 2020-1010.0, result.Paths[0][1], 1e-9)
+	require.InDelta(t, 2020-1010*1.01, result.Paths[0][2], 1e-9)
+	require.Zero(t, result.Leverage.MarginCallRate)
+}
+
+func TestRunSimulationPaths_LeverageCashRateSeries(t *testing.T) {
+	params := Params{
+		InitialValue: 1000,
+		Simulations:  1,
+		Periods:      3,
+		Leverage:     &Leverage{Ratio: 2, CashRates: []float64{0.06, 0.12}, Spread: 0.012, MaintenanceRatio: 0.25},
+	}
+	result, err := runSimulationPaths(params, sequence())
+	require.NoError(t, err)
+
+	debt := 1000 * (1 + 0.072/12)
+	require.InDelta(t, 2000-debt, result.Paths[0][1], 1e-9)
+	debt *= 1 + 0.132/12
+	require.InDelta(t, 2000-debt, result.Paths[0][2], 1e-9)
+	debt *= 1 + 0.132/12
+	require.InDelta(t, 2000-debt, result.Paths[0][3], 1e-9, "The last cash rate holds past the series")
+}
+
+func TestRunSimulationPaths_MarginCallDeleverages(t *testing.T) {
+	params := Params{
+		InitialValue: 1000,
+		Simulations:  1,
+		Periods:      2,
+		Leverage:     &Leverage{Ratio: 2, MaintenanceRatio: 0.3},
+	}
+	// A 30% loss leaves 1400 of assets on 1000 of debt: equity is 28.6% of assets.
+	result, err := runSimulationPaths(params, sequence(-0.3, 0.1))
+	require.NoError(t, err)
+	require.Equal(t, 1.0, result.Leverage.MarginCallRate)
+	require.Equal(t, 1.0, result.Leverage.AverageMarginCalls)
+
+	// 600 of assets are sold to restore 2x leverage on the remaining 400 of equity.
+	require.InDelta(t, 400.0, result.Paths[0][1], 1e-9)
+	require.InDelta(t, 800*1.1-400, result.Paths[0][2], 1e-9)
+}
+
+func TestRunSimulationPaths_LeverageWipeout(t *testing.T) {
+	params := Params{
+		InitialValue: 1000,
+		Simulations:  1,
+		Periods:      3,
+		Leverage:     &Leverage{Ratio: 2, MaintenanceRatio: 0.25},
+	}
+	result, err := runSimulationPaths(params, sequence(-0.6))
+	require.NoError(t, err)
+	require.Zero(t, result.SuccessRate)
+	require.Zero(t, result.Paths[0][1])
+}
+
+func TestSimulateBootstrap_LeverageComparison(t *testing.T) {
+	params := Params{
+		InitialValue: 100000,
+		Returns:      []float64{0.02, -0.01, 0.015, 0.01, -0.02, 0.025},
+		Periods:      60,
+		Simulations:  200,
+		Seed:         11,
+		Leverage:     &Leverage{Ratio: 1.5, BorrowRate: 0.04, MaintenanceRatio: 0.25},
+	}
+	result, err := SimulateBootstrap(params)
+	require.NoError(t, err)
+
+	stats := result.Leverage
+	require.NotNil(t, stats)
+	require.Equal(t, result.FinalStats, stats.LeveragedTerminal)
+	require.Greater(t, stats.LeveragedTerminal.Mean, stats.UnleveragedTerminal.Mean)
+	require.Greater(t, stats.LeveragedTerminal.Max-stats.LeveragedTerminal.Min, stats.UnleveragedTerminal.Max-stats.UnleveragedTerminal.Min, "Leverage widens the distribution")
+	require.Equal(t, stats.UnleveragedTerminal.Mean, stats.WithoutLeverage.MeanTerminal)
+}
+
+func TestLeverage_Validate(t *testing.T) {
+	require.ErrorContains(t, (&Leverage{Ratio: 0.5, MaintenanceRatio: 0.25}).validate(), "at least 1")
+	require.ErrorContains(t, (&Leverage{Ratio: 2, MaintenanceRatio: 0.5}).validate(), "maintenance ratio")
+	require.NoError(t, (&Leverage{Ratio: 2, MaintenanceRatio: 0.3}).validate())
+}
@@ -0,0 +1,79 @@
+package simulation
+
+import (
+	"math/rand"
+
+	"portfolio-simulator/backend/internal/mortality"
+)
+
+// Lifespan configures mortality-weighted simulation. A lifespan is drawn for each
+// path from Table, and the path only needs to fund withdrawals until death.
+// Lives extending beyond the simulation horizon are treated as ending at the horizon.
+type Lifespan struct {
+	Table    *mortality.Table // Annual death probabilities by age.
+	StartAge int              // Age in whole years at the start of the simulation.
+}
+
+// LifespanStats summarises mortality-weighted outcomes across all paths.
+type LifespanStats struct {
+	ProbabilityOfRuin          float64 // Share of paths that ran out of money before death.
+	ExpectedBequest            float64 // Mean portfolio value at death, counting depleted paths as zero.
+	ExpectedShortfallYears     float64 // Mean years lived after running out of money, across all paths.
+	ShortfallYearsWhenDepleted float64 // Mean years lived after running out of money, among paths that did.
+	ExpectedLifespanYears      float64 // Mean remaining lifetime drawn, capped at the horizon, in years.
+}
+
+// lifespanTracker draws a death month per path and accumulates mortality-weighted outcomes.
+type lifespanTracker struct {
+	config  Lifespan
+	periods int
+	rng     *rand.Rand
+
+	paths           int
+	ruined          int
+	bequestSum      float64
+	shortfallMonths int
+	lifetimeMonths  int
+}
+
+func newLifespanTracker(config Lifespan, periods int, rng *rand.Rand) *lifespanTracker {
+	return &lifespanTracker{
+		config:  config,
+		periods: periods,
+		rng:     rng,
+	}
+}
+
+// record draws a lifespan for the simulated path and reports whether the
+// portfolio lasted as long as the person did.
+func (l *lifespanTracker) record(outcome pathOutcome) bool {
+	death := min(l.config.Table.SampleDeathMonth(l.config.StartAge, l.rng), l.periods)
+	l.paths++
+	l.lifetimeMonths += death
+	l.bequestSum += outcome.values[death]
+
+	if outcome.depletedAt == 0 || outcome.depletedAt > death {
+		return true
+	}
+	l.ruined++
+	// The month of depletion is itself only partly funded, so it counts as a shortfall month.
+	l.shortfallMonths += death - outcome.depletedAt + 1
+	return false
+}
+
+func (l *lifespanTracker) stats() *LifespanStats {
+	if l.paths == 0 {
+		return &LifespanStats{}
+	}
+	n := float64(l.paths)
+	stats := &LifespanStats{
+		ProbabilityOfRuin:      float64(l.ruined) / n,
+		ExpectedBequest:        l.bequestSum / n,
+		ExpectedShortfallYears: float64(l.shortfallMonths) / 12 / n,
+		ExpectedLifespanYears:  float64(l.lifetimeMonths) / 12 / n,
+	}
+	if l.ruined > 0 {
+		stats.ShortfallYearsWhenDepleted = float64(l.shortfallMonths) / 12 / float64(l.ruined)
+	}
+	return stats
+}
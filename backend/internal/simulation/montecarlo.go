@@ -2,6 +2,7 @@ package simulation
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"math/rand"
 	"sort"
@@ -15,13 +16,58 @@ type Params struct {
 	InflationPerYear float64   // Annual inflation rate (e.g., 0.02 for 2%).
 	Periods          int       // Total number of periods (e.g., months) for the simulation.
 	Simulations      int       // Number of Monte Carlo paths to simulate.
+	Sampler          Sampler   // Draw generator for parametric methods; empty means pseudo-random.
+	TargetStdError   float64   // Optional precision target for the success rate; enables adaptive batching.
+	MaxSimulations   int       // Cap on total paths when TargetStdError is set; Simulations is then the batch size.
+	Lifespan         *Lifespan // Optional: draw a lifespan per path and measure success only up to death.
+	Annuity          *Annuity  // Optional: buy an immediate annuity whose income offsets withdrawals.
+	Bucket           *Bucket   // Optional: pay withdrawals from a cash bucket refilled from the portfolio.
+	Fees             *Fees     // Optional: expense ratios and advisory fees deducted monthly.
+	Leverage         *Leverage // Optional: borrow against the invested portfolio, with margin calls.
+	Scoring          *Scoring  // Optional: score consumption with CRRA utility and spending shortfalls.
+	Seed             int64     // Seed for all random draws; zero picks a random seed. Equal seeds give equal returns.
+
+	// Accounts splits the portfolio over accounts with their own allocation and tax
+	// treatment. It switches to the multi-account engine, which draws per-asset returns
+	// from AssetReturns; InitialValue should equal the sum of the account balances.
+	Accounts *MultiAccount
+
+	// AssetReturns holds historical returns per period (rows) and asset (columns). It is
+	// only used with GlidePath, which switches to the multi-asset engine: per-asset returns
+	// are drawn jointly and weighted by the glide path's allocation for each period.
+	AssetReturns [][]float64
+	GlidePath    *GlidePath
+
+	// Scenarios are stresses reported next to the run: each is spliced into every path
+	// of a separate run on the same simulated returns. The main run is left unstressed.
+	Scenarios []Scenario
+	// AssetClasses gives each asset's class, in the order of the columns of AssetReturns,
+	// so a scenario gives it the returns of its class. AssetWeights are the weights used
+	// to combine the classes' returns in single-series runs, where Returns is already
+	// the portfolio's. Without classes the whole portfolio earns the equity returns.
+	AssetClasses []AssetClass
+	AssetWeights []float64
+
+	scenario *Scenario // Stress spliced into the current run.
 }
 
 // Result holds the outcomes of a Monte Carlo simulation.
 type Result struct {
-	Paths       [][]float64  // Each inner slice represents a single simulated path of portfolio values.
-	FinalStats  SummaryStats // Summary statistics of the final portfolio values across all paths.
-	SuccessRate float64      // Proportion of paths that did not deplete before the end of the simulation period.
+	Paths       [][]float64        // Each inner slice represents a single simulated path of portfolio values.
+	FinalStats  SummaryStats       // Summary statistics of the final portfolio values across all paths.
+	SuccessRate float64            // Proportion of paths that did not deplete before the end of the simulation period.
+	Convergence []ConvergencePoint // Success-rate estimate after each batch of paths.
+	Converged   bool               // Whether TargetStdError was met before hitting MaxSimulations.
+	Lifespan    *LifespanStats     // Mortality-weighted outcomes; nil unless Params.Lifespan was set.
+	Annuity     *AnnuityComparison // Outcomes with and without the annuity; nil unless Params.Annuity was set.
+	Bucket      *BucketComparison  // Outcomes with and without the cash bucket; nil unless Params.Bucket was set.
+	Fees        *FeeStats          // Fees paid and outcomes without fees; nil unless Params.Fees was set.
+	Leverage    *LeverageStats     // Margin calls and outcomes without leverage; nil unless Params.Leverage was set.
+	Allocations [][]float64        // Allocation at each period from 0 to Periods; nil unless Params.GlidePath was set.
+	Accounts    *AccountStats      // After-tax spending and taxes; nil unless Params.Accounts was set.
+	Stress      *StressStats       // Outcomes with each stress scenario; nil unless Params.Scenarios was set.
+	Scores      *ScoreStats        // Utility and shortfall scores; nil unless Params.Scoring was set.
+	Seed        int64              // Seed used for the run, for reproducing it.
 }
 
 // SummaryStats provides descriptive statistics for a set of values, typically final portfolio values.
@@ -32,9 +78,157 @@ type SummaryStats struct {
 	Max    float64 // Maximum value.
 }
 
+// Names of the return models, as used by the multi-asset engine.
+const (
+	methodNormal    = "normal"
+	methodBootstrap = "bootstrap"
+)
+
+// Outcome summarises a run for side-by-side comparisons of strategies.
+type Outcome struct {
+	SuccessRate     float64 // Proportion of successful paths.
+	MedianTerminal  float64 // Median final portfolio value.
+	MeanTerminal    float64 // Mean final portfolio value.
+	ExpectedBequest float64 // Mean value at death when lifespans are drawn, otherwise the mean final value.
+
+	CertaintyEquivalent float64 // Certainty-equivalent consumption from Result.Scores; 0 unless Params.Scoring was set.
+}
+
+// outcomeOf summarises a result for comparisons.
+func outcomeOf(r *Result) Outcome {
+	o := Outcome{
+		SuccessRate:     r.SuccessRate,
+		MedianTerminal:  r.FinalStats.Median,
+		MeanTerminal:    r.FinalStats.Mean,
+		ExpectedBequest: r.FinalStats.Mean,
+	}
+	if r.Lifespan != nil {
+		o.ExpectedBequest = r.Lifespan.ExpectedBequest
+	}
+	if r.Scores != nil {
+		o.CertaintyEquivalent = r.Scores.CertaintyEquivalent
+	}
+	return o
+}
+
 // SimulateNormal runs Monte Carlo simulations assuming returns follow a normal distribution.
 // The distribution is parameterized by the mean and sample standard deviation of the provided historical returns.
 func SimulateNormal(params Params) (*Result, error) {
+	return simulate(params, simulateNormal)
+}
+
+// SimulateBootstrap runs Monte Carlo simulations by randomly sampling from the provided historical returns.
+func SimulateBootstrap(params Params) (*Result, error) {
+	return simulate(params, simulateBootstrap)
+}
+
+// simulate assigns a seed when none was given, then runs fn along with any comparison runs
+// the parameters call for. All runs share the seed and therefore the same random returns.
+func simulate(params Params, fn func(Params) (*Result, error)) (*Result, error) {
+	for params.Seed == 0 {
+		params.Seed = rand.Int63()
+	}
+	for i := range params.Scenarios {
+		if err := params.Scenarios[i].validate(params.Periods); err != nil {
+			return nil, err
+		}
+	}
+	if params.AssetClasses != nil && params.GlidePath == nil && params.Accounts == nil && len(params.AssetWeights) != len(params.AssetClasses) {
+		return nil, errors.New("simulation: asset classes and asset weights must have the same length")
+	}
+
+	result, err := fn(params)
+	if err != nil {
+		return nil, err
+	}
+	result.Seed = params.Seed
+
+	// Comparison runs use exactly as many paths as the main run, even if it was adaptive.
+	baseline := params
+	baseline.TargetStdError = 0
+	baseline.Simulations = len(result.Paths)
+
+	// rerun reruns the simulation with one feature switched off.
+	rerun := func(feature string, disable func(*Params)) (*Result, error) {
+		other := baseline
+		disable(&other)
+		r, err := fn(other)
+		if err != nil {
+			return nil, fmt.Errorf("simulation: comparison run without %s failed: %w", feature, err)
+		}
+		return r, nil
+	}
+	// compare summarises a rerun with one feature switched off.
+	compare := func(feature string, disable func(*Params)) (Outcome, error) {
+		r, err := rerun(feature, disable)
+		if err != nil {
+			return Outcome{}, err
+		}
+		return outcomeOf(r), nil
+	}
+
+	if params.Annuity != nil {
+		result.Annuity.WithAnnuity = outcomeOf(result)
+		result.Annuity.WithoutAnnuity, err = compare("annuity", func(p *Params) { p.Annuity = nil })
+		if err != nil {
+			return nil, err
+		}
+	}
+	if params.Bucket != nil {
+		result.Bucket.WithBucket = outcomeOf(result)
+		result.Bucket.WithoutBucket, err = compare("cash bucket", func(p *Params) { p.Bucket = nil })
+		if err != nil {
+			return nil, err
+		}
+	}
+	if params.Fees != nil {
+		result.Fees.WithFees = outcomeOf(result)
+		result.Fees.WithoutFees, err = compare("fees", func(p *Params) { p.Fees = nil })
+		if err != nil {
+			return nil, err
+		}
+		result.Fees.MeanTerminalDifference = result.Fees.WithoutFees.MeanTerminal - result.Fees.WithFees.MeanTerminal
+		result.Fees.MedianTerminalDifference = result.Fees.WithoutFees.MedianTerminal - result.Fees.WithFees.MedianTerminal
+	}
+	if params.Leverage != nil {
+		unleveraged, err := rerun("leverage", func(p *Params) { p.Leverage = nil })
+		if err != nil {
+			return nil, err
+		}
+		result.Leverage.LeveragedTerminal = result.FinalStats
+		result.Leverage.UnleveragedTerminal = unleveraged.FinalStats
+		result.Leverage.WithLeverage = outcomeOf(result)
+		result.Leverage.WithoutLeverage = outcomeOf(unleveraged)
+	}
+	if len(params.Scenarios) > 0 {
+		result.Stress = &StressStats{Baseline: outcomeOf(result)}
+		for i := range params.Scenarios {
+			s := &params.Scenarios[i]
+			stressed := baseline
+			stressed.Scenarios = nil
+			stressed.scenario = s
+			r, err := fn(stressed)
+			if err != nil {
+				return nil, fmt.Errorf("simulation: stress run for scenario %q failed: %w", s.Name, err)
+			}
+			result.Stress.Scenarios = append(result.Stress.Scenarios, ScenarioOutcome{
+				Name:       s.Name,
+				Start:      s.Start,
+				Outcome:    outcomeOf(r),
+				FinalStats: r.FinalStats,
+			})
+		}
+	}
+	return result, nil
+}
+
+func simulateNormal(params Params) (*Result, error) {
+	if params.Accounts != nil {
+		return simulateAccounts(params, methodNormal)
+	}
+	if params.GlidePath != nil {
+		return simulateGlidePath(params, methodNormal)
+	}
 	if len(params.Returns) == 0 {
 		return nil, errors.New("simulation: returns slice is empty, cannot calculate mean/std for normal distribution")
 	}
@@ -48,36 +242,88 @@ func SimulateNormal(params Params) (*Result, error) {
 		return nil, errors.New("simulation: standard deviation of returns is zero based on provided historical data, cannot reliably perform stochastic normal simulation")
 	}
 
-	generateReturn := func() float64 {
-		return rand.NormFloat64()*std + mean
+	switch params.Sampler {
+	case "", SamplerPseudoRandom:
+		rng := newStreamRand(params.Seed, streamReturns)
+		generateReturn := func() float64 {
+			return rng.NormFloat64()*std + mean
+		}
+		return runPaths(params, newBufferedSource(params.Periods, generateReturn))
+	case SamplerSobol:
+		if params.Periods <= 0 {
+			return nil, errors.New("simulation: number of periods must be positive")
+		}
+		source, err := newSobolSource(params.Periods, mean, std, newStreamRand(params.Seed, streamReturns))
+		if err != nil {
+			return nil, err
+		}
+		return runPaths(params, source)
+	default:
+		return nil, fmt.Errorf("simulation: unknown sampler %q", params.Sampler)
 	}
-	return runSimulationPaths(params, generateReturn)
 }
 
-// SimulateBootstrap runs Monte Carlo simulations by randomly sampling from the provided historical returns.
-func SimulateBootstrap(params Params) (*Result, error) {
+func simulateBootstrap(params Params) (*Result, error) {
+	if params.Accounts != nil {
+		return simulateAccounts(params, methodBootstrap)
+	}
+	if params.GlidePath != nil {
+		return simulateGlidePath(params, methodBootstrap)
+	}
 	if len(params.Returns) == 0 {
 		return nil, errors.New("simulation: returns slice is empty, cannot bootstrap")
 	}
+	if params.Sampler == SamplerSobol {
+		return nil, errors.New("simulation: the Sobol sampler is only supported by parametric methods")
+	}
 
+	rng := newStreamRand(params.Seed, streamReturns)
 	generateReturn := func() float64 {
-		return params.Returns[rand.Intn(len(params.Returns))]
+		return params.Returns[rng.Intn(len(params.Returns))]
 	}
-	return runSimulationPaths(params, generateReturn)
+	return runPaths(params, newBufferedSource(params.Periods, generateReturn))
 }
 
 // runSimulationPaths executes the core Monte Carlo simulation logic for a given return generation function.
 func runSimulationPaths(params Params, generateReturn func() float64) (*Result, error) {
-	N := params.Simulations
+	return runPaths(params, streamSource(generateReturn))
+}
+
+// runPaths executes the core Monte Carlo simulation logic, drawing each path's returns from source.
+func runPaths(params Params, source pathSource) (*Result, error) {
+	var expenseRatios []float64
+	if params.Fees != nil && params.Periods > 0 {
+		// Validated again by runPathsWith; checked here because the ratios index the allocations.
+		if err := params.Fees.validate(params); err != nil {
+			return nil, err
+		}
+		expenseRatios = params.Fees.monthlyExpenseRatios(params)
+	}
+	if s := params.scenario; s != nil && params.GlidePath == nil {
+		// Glide-path runs stress each asset before weighting them; see simulateGlidePath.
+		source = &scenarioSource{source: source, scenario: s, returns: s.portfolioReturns(params.AssetClasses, params.AssetWeights)}
+	}
+	return runPathsWith(params, func(withdrawals []float64) pathOutcome {
+		return simulatePath(params, withdrawals, expenseRatios, source)
+	})
+}
+
+// runPathsWith runs paths produced by walk, which simulates one path given the
+// inflation-adjusted withdrawal for each period, and collects the results.
+// Paths are run in batches of Simulations; when TargetStdError is set, batches continue until the
+// success-rate standard error meets the target or MaxSimulations paths have been run.
+func runPathsWith(params Params, walk func(withdrawals []float64) pathOutcome) (*Result, error) {
 	periods := params.Periods
 
 	if periods <= 0 {
 		return nil, errors.New("simulation: number of periods must be positive")
 	}
 
-	paths := make([][]float64, N)
-	finalVals := make([]float64, N)
-	successCount := 0
+	batchSize := params.Simulations
+	maxPaths := batchSize
+	if params.TargetStdError > 0 && params.MaxSimulations > batchSize {
+		maxPaths = params.MaxSimulations
+	}
 
 	var adjustedMonthlyWithdrawals []float64
 	if params.WithdrawalRate > 0 {
@@ -88,52 +334,287 @@ func runSimulationPaths(params Params, generateReturn func() float64) (*Result,
 
 		for t := 1; t <= periods; t++ {
 			yearFractionForInflation := float64(t-1) / 12.0
-			adjustedMonthlyWithdrawals[t] = baseMonthlyWithdrawal * math.Pow(1.0+params.InflationPerYear, yearFractionForInflation)
+			stressFraction := 0.0
+			if s := params.scenario; s != nil {
+				// Months within the stress grow prices at its inflation instead.
+				stressFraction = float64(s.inflationMonths(t)) / 12.0
+			}
+			adjustedMonthlyWithdrawals[t] = baseMonthlyWithdrawal *
+				math.Pow(1.0+params.InflationPerYear, yearFractionForInflation-stressFraction)
+			if stressFraction > 0 {
+				adjustedMonthlyWithdrawals[t] *= math.Pow(1.0+*params.scenario.Inflation, stressFraction)
+			}
 		}
 	}
 
-	for i := 0; i < N; i++ {
-		path := make([]float64, periods+1)
-		path[0] = params.InitialValue
-		currentSuccess := true
+	var lifespan *lifespanTracker
+	if params.Lifespan != nil {
+		lifespan = newLifespanTracker(*params.Lifespan, periods, newStreamRand(params.Seed, streamLifespan))
+	}
 
-		for t := 1; t <= periods; t++ {
-			monthlyReturn := generateReturn()
-			currentPortfolioValue := path[t-1]
+	var annuity *annuityTracker
+	if params.Annuity != nil {
+		annuity = &annuityTracker{annuity: params.Annuity}
+	}
 
-			currentPortfolioValue = currentPortfolioValue * (1 + monthlyReturn)
+	var bucket *bucketTracker
+	if params.Bucket != nil {
+		bucket = &bucketTracker{}
+	}
 
-			if params.WithdrawalRate > 0 {
-				currentPortfolioValue -= adjustedMonthlyWithdrawals[t]
-				if currentPortfolioValue <= 0 {
-					currentPortfolioValue = 0
-					currentSuccess = false
-				}
+	var fees *feeTracker
+	if params.Fees != nil {
+		if err := params.Fees.validate(params); err != nil {
+			return nil, err
+		}
+		fees = &feeTracker{}
+	}
+
+	var leverage *leverageTracker
+	if params.Leverage != nil {
+		if err := params.Leverage.validate(); err != nil {
+			return nil, err
+		}
+		leverage = &leverageTracker{}
+	}
+
+	var scores *scoreTracker
+	if params.Scoring != nil {
+		if err := params.Scoring.validate(params); err != nil {
+			return nil, err
+		}
+		scores = &scoreTracker{
+			scoring:     params.Scoring,
+			annuity:     params.Annuity,
+			withdrawals: adjustedMonthlyWithdrawals,
+			periods:     periods,
+		}
+	}
+
+	var (
+		paths        [][]float64
+		finalVals    []float64
+		successCount int
+		convergence  []ConvergencePoint
+		converged    bool
+	)
+	for batchSize > 0 && len(paths) < maxPaths && !converged {
+		n := min(batchSize, maxPaths-len(paths))
+		for i := 0; i < n; i++ {
+			outcome := walk(adjustedMonthlyWithdrawals)
+			paths = append(paths, outcome.values)
+			finalVals = append(finalVals, outcome.values[periods])
+			success := outcome.depletedAt == 0
+			if lifespan != nil {
+				success = lifespan.record(outcome)
+			}
+			if annuity != nil {
+				annuity.record(outcome)
+			}
+			if bucket != nil {
+				bucket.record(outcome)
 			}
-			path[t] = currentPortfolioValue
-			if !currentSuccess {
-				break
+			if fees != nil {
+				fees.record(outcome)
+			}
+			if leverage != nil {
+				leverage.record(outcome)
+			}
+			if scores != nil {
+				scores.record(outcome)
+			}
+			if success {
+				successCount++
 			}
 		}
 
-		paths[i] = path
-		finalVals[i] = path[len(path)-1]
-		if currentSuccess {
-			successCount++
-		}
+		point := newConvergencePoint(successCount, len(paths))
+		convergence = append(convergence, point)
+		converged = params.TargetStdError > 0 && point.StdError <= params.TargetStdError
 	}
 
 	summary := calculateSummary(finalVals)
 	successRate := 0.0
-	if N > 0 {
-		successRate = float64(successCount) / float64(N)
+	if len(paths) > 0 {
+		successRate = float64(successCount) / float64(len(paths))
 	}
 
-	return &Result{
+	result := &Result{
 		Paths:       paths,
 		FinalStats:  summary,
 		SuccessRate: successRate,
-	}, nil
+		Convergence: convergence,
+		Converged:   converged,
+	}
+	if lifespan != nil {
+		result.Lifespan = lifespan.stats()
+	}
+	if annuity != nil {
+		result.Annuity = annuity.comparison()
+	}
+	if bucket != nil {
+		result.Bucket = bucket.comparison()
+	}
+	if fees != nil {
+		result.Fees = fees.stats()
+	}
+	if leverage != nil {
+		result.Leverage = leverage.stats()
+	}
+	if scores != nil {
+		result.Scores = scores.stats()
+	}
+	return result, nil
+}
+
+// pathOutcome is the result of simulating a single path.
+type pathOutcome struct {
+	values     []float64 // Portfolio value at the end of each period, starting with the initial value.
+	depletedAt int       // Period in which the portfolio was depleted, or 0 if it never was.
+	premium    float64   // Annuity premium paid, or 0 if no annuity was bought.
+	shortfall  float64   // Part of the withdrawal left unfunded in the period of depletion.
+
+	bucketEmptyMonths int     // Months in which the cash bucket could not cover the withdrawal.
+	feesPaid          float64 // Total expense ratio and advisory fees deducted.
+	marginCalls       int     // Number of margin calls.
+
+	accounts *accountOutcome // After-tax results; nil unless Params.Accounts was set.
+}
+
+// simulatePath runs a single path and records when, if ever, it was depleted.
+// withdrawals holds the inflation-adjusted withdrawal for each period, or is nil when there are none.
+// expenseRatios holds the monthly expense ratio for each period, or is nil when there are no fees.
+// The portfolio value is split into an invested sleeve earning the simulated returns and a
+// cash sleeve, which is only used by the bucket strategy. With leverage the invested sleeve
+// is partly financed by debt, and the recorded value is equity: invested + cash - debt.
+func simulatePath(params Params, withdrawals, expenseRatios []float64, source pathSource) pathOutcome {
+	periods := params.Periods
+	path := make([]float64, periods+1)
+	invested := params.InitialValue
+	cash := 0.0
+	depletedAt := 0
+	premium := 0.0
+	emptyMonths := 0
+	feesPaid := 0.0
+	marginCalls := 0
+	shortfall := 0.0
+	source.startPath()
+
+	annuity := params.Annuity
+	if annuity != nil && annuity.Period == 0 {
+		premium = annuity.premium(invested)
+		invested -= premium
+	}
+	bucket := params.Bucket
+	cashGrowth := 1.0
+	if bucket != nil {
+		cash = math.Min(bucket.target(withdrawals, 0), invested)
+		invested -= cash
+		cashGrowth = bucket.monthlyGrowth()
+	}
+	leverage := params.Leverage
+	debt := 0.0
+	if leverage != nil {
+		debt = invested * (leverage.Ratio - 1)
+		invested += debt
+	}
+	path[0] = invested + cash - debt
+	yearGrowth := 1.0 // Growth of the invested sleeve over the year to date.
+
+	for t := 1; t <= periods; t++ {
+		monthlyReturn := source.next()
+		invested = invested * (1 + monthlyReturn)
+		cash *= cashGrowth
+		yearGrowth *= 1 + monthlyReturn
+		if leverage != nil {
+			debt *= 1 + leverage.monthlyRate(t)
+		}
+
+		if fees := params.Fees; fees != nil {
+			// Expense ratios come out of the funds; the advisory fee is charged on equity,
+			// including any cash, and paid from the invested sleeve first.
+			expense := math.Max(invested, 0) * expenseRatios[t]
+			advisory := fees.advisoryFee(math.Max(invested+cash-debt, 0)) / 12
+			invested -= expense
+			fromInvested := math.Min(advisory, math.Max(invested, 0))
+			invested -= fromInvested
+			cash -= advisory - fromInvested
+			feesPaid += expense + advisory
+		}
+
+		income := 0.0
+		if annuity != nil {
+			income = annuity.payment(premium, t)
+		}
+		if params.WithdrawalRate > 0 || income > 0 {
+			withdrawal := 0.0
+			if withdrawals != nil {
+				withdrawal = withdrawals[t]
+			}
+			// Annuity income covers the withdrawal first; any excess is reinvested.
+			need := withdrawal - income
+			if bucket != nil && need > 0 {
+				fromCash := math.Min(need, cash)
+				cash -= fromCash
+				need -= fromCash
+				if need > 0 {
+					emptyMonths++
+				}
+			}
+			invested -= need
+			// A path runs out only when it still has spending to fund; an annuity that covers
+			// the whole withdrawal leaves nothing invested without depleting the plan.
+			if equity := invested + cash - debt; need > 0 && equity <= 0 {
+				shortfall = math.Min(-equity, math.Max(need, 0))
+				invested, cash, debt = 0, 0, 0
+				depletedAt = t
+			}
+		}
+		if leverage != nil && depletedAt == 0 {
+			switch {
+			case invested+cash-debt <= 0:
+				// Losses wiped out the equity.
+				invested, cash, debt = 0, 0, 0
+				depletedAt = t
+			case leverage.marginCall(invested, debt):
+				invested, debt = leverage.deleverage(invested, debt)
+				if invested <= 0 {
+					// Selling every invested asset did not cover the loan; cash repays the rest.
+					cash -= debt
+					debt = 0
+				}
+				marginCalls++
+			}
+		}
+		if annuity != nil && annuity.Period == t && depletedAt == 0 {
+			premium = annuity.premium(invested + cash - debt)
+			// Pay the premium from the invested sleeve, keeping the cash reserve if possible.
+			fromInvested := math.Min(premium, invested)
+			invested -= fromInvested
+			cash -= premium - fromInvested
+		}
+		if bucket != nil && depletedAt == 0 && invested > 0 && bucket.refillDue(t, yearGrowth) {
+			topUp := math.Min(math.Max(bucket.target(withdrawals, t)-cash, 0), invested)
+			invested -= topUp
+			cash += topUp
+		}
+		if t%12 == 0 {
+			yearGrowth = 1
+		}
+		path[t] = invested + cash - debt
+		if depletedAt > 0 {
+			break
+		}
+	}
+	return pathOutcome{
+		values:            path,
+		depletedAt:        depletedAt,
+		premium:           premium,
+		shortfall:         shortfall,
+		bucketEmptyMonths: emptyMonths,
+		feesPaid:          feesPaid,
+		marginCalls:       marginCalls,
+	}
 }
 
 // meanStd calculates the mean and sample standard deviation of a slice of float64.
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"portfolio-simulator/backend/internal/mortality"
 )
 
 // TestSimulateNormal_NoWithdrawals tests SimulateNormal without withdrawals.
@@ -250,3 +251,372 @@ func TestCalculateSummary(t *testing.T) {
 		})
 	}
 }
+
+func TestSimulateNormal_SobolSampler(t *testing.T) {
+	params := Params{
+		InitialValue: 1000,
+		Returns:      []float64{0.01, -0.02, 0.03, 0.015, -0.005},
+		Simulations:  512,
+		Periods:      24,
+		Sampler:      SamplerSobol,
+	}
+	result, err := SimulateNormal(params)
+	require.NoError(t, err)
+	require.Len(t, result.Paths, params.Simulations)
+	for _, path := range result.Paths {
+		require.Len(t, path, params.Periods+1)
+		require.Equal(t, params.InitialValue, path[0])
+	}
+
+	// Independent normal returns give E[V_T] = V_0 * (1 + mean)^T.
+	mean, _ := meanStd(params.Returns)
+	expected := params.InitialValue * math.Pow(1+mean, float64(params.Periods))
+	require.InEpsilon(t, expected, result.FinalStats.Mean, 0.01)
+}
+
+func TestSimulateNormal_UnknownSampler(t *testing.T) {
+	params := Params{
+		InitialValue: 1000,
+		Returns:      []float64{0.01, -0.02, 0.03},
+		Simulations:  10,
+		Periods:      10,
+		Sampler:      "lattice",
+	}
+	_, err := SimulateNormal(params)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unknown sampler")
+}
+
+func TestSimulateBootstrap_RejectsSobolSampler(t *testing.T) {
+	params := Params{
+		InitialValue: 1000,
+		Returns:      []float64{0.01, -0.02, 0.03},
+		Simulations:  10,
+		Periods:      10,
+		Sampler:      SamplerSobol,
+	}
+	_, err := SimulateBootstrap(params)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "only supported by parametric methods")
+}
+
+func TestBrownianBridge_IncrementsAreOrthonormal(t *testing.T) {
+	// The bridge is linear, so its increments are independent standard normals
+	// exactly when the matrix mapping draws to increments is orthogonal.
+	for _, size := range []int{1, 2, 7, 12, 33} {
+		bridge := newBrownianBridge(size)
+		columns := make([][]float64, size)
+		for i := range columns {
+			z := make([]float64, size)
+			z[i] = 1
+			columns[i] = make([]float64, size)
+			bridge.transform(z, columns[i])
+		}
+		for r := 0; r < size; r++ {
+			for c := 0; c < size; c++ {
+				dot := 0.0
+				for i := 0; i < size; i++ {
+					dot += columns[i][r] * columns[i][c]
+				}
+				expected := 0.0
+				if r == c {
+					expected = 1.0
+				}
+				require.InDelta(t, expected, dot, 1e-9, "size %d, entry (%d,%d)", size, r, c)
+			}
+		}
+	}
+}
+
+func TestNewConvergencePoint(t *testing.T) {
+	point := newConvergencePoint(90, 100)
+	require.Equal(t, 100, point.Simulations)
+	require.InDelta(t, 0.9, point.SuccessRate, 1e-12)
+	require.InDelta(t, 0.0319, point.StdError, 1e-3)
+	require.InDelta(t, 0.8256, point.Lower, 1e-3)
+	require.InDelta(t, 0.9448, point.Upper, 1e-3)
+
+	// A unanimous outcome still carries uncertainty.
+	allSucceed := newConvergencePoint(50, 50)
+	require.Equal(t, 1.0, allSucceed.SuccessRate)
+	require.Greater(t, allSucceed.StdError, 0.0)
+	require.Less(t, allSucceed.Lower, 1.0)
+	require.Equal(t, 1.0, allSucceed.Upper)
+
+	require.Equal(t, ConvergencePoint{}, newConvergencePoint(0, 0))
+}
+
+func TestRunSimulationPaths_SingleBatchWithoutTarget(t *testing.T) {
+	params := Params{
+		InitialValue:   1000,
+		Simulations:    20,
+		MaxSimulations: 1000,
+		Periods:        3,
+	}
+	result, err := runSimulationPaths(params, func() float64 { return 0.01 })
+	require.NoError(t, err)
+	require.Len(t, result.Paths, 20)
+	require.Len(t, result.Convergence, 1)
+	require.False(t, result.Converged)
+}
+
+func TestRunSimulationPaths_AdaptiveStopsAtTarget(t *testing.T) {
+	params := Params{
+		InitialValue:   100,
+		WithdrawalRate: 0.5,
+		Simulations:    100,
+		MaxSimulations: 10000,
+		TargetStdError: 0.01,
+		Periods:        2,
+	}
+	// Alternate between surviving and depleting paths: a 50% success rate needs
+	// n >= 0.25 / 0.01^2 = 2500 paths to reach the target.
+	draws := 0
+	result, err := runSimulationPaths(params, func() float64 {
+		draws++
+		if (draws-1)/2%2 == 0 {
+			return 0.1
+		}
+		return -0.9
+	})
+	require.NoError(t, err)
+	require.True(t, result.Converged)
+	require.Len(t, result.Paths, 2500)
+	require.Len(t, result.Convergence, 25)
+	require.InDelta(t, 0.5, result.SuccessRate, 1e-9)
+
+	for i, point := range result.Convergence {
+		require.Equal(t, (i+1)*params.Simulations, point.Simulations)
+		require.LessOrEqual(t, point.Lower, point.SuccessRate)
+		require.GreaterOrEqual(t, point.Upper, point.SuccessRate)
+	}
+	last := result.Convergence[len(result.Convergence)-1]
+	require.LessOrEqual(t, last.StdError, params.TargetStdError)
+}
+
+func TestRunSimulationPaths_AdaptiveRespectsCap(t *testing.T) {
+	params := Params{
+		InitialValue:   1000,
+		Simulations:    30,
+		MaxSimulations: 100,
+		TargetStdError: 1e-6,
+		Periods:        2,
+	}
+	result, err := runSimulationPaths(params, func() float64 { return 0.0 })
+	require.NoError(t, err)
+	require.False(t, result.Converged)
+	require.Len(t, result.Paths, 100)
+	require.Len(t, result.Convergence, 4)
+	require.Equal(t, 100, result.Convergence[3].Simulations)
+}
+
+func TestRunSimulationPaths_LifespanDeathBeforeDepletion(t *testing.T) {
+	// Certain death within the first year; withdrawals of 100/month deplete the portfolio in month 24.
+	table, err := mortality.NewTable([]mortality.Rate{{Age: 90, Qx: 1.0}})
+	require.NoError(t, err)
+
+	params := Params{
+		InitialValue:   2400,
+		WithdrawalRate: 0.5,
+		Simulations:    50,
+		Periods:        36,
+		Lifespan:       &Lifespan{Table: table, StartAge: 90},
+	}
+	result, err := runSimulationPaths(params, func() float64 { return 0.0 })
+	require.NoError(t, err)
+	require.NotNil(t, result.Lifespan)
+	require.Equal(t, 1.0, result.SuccessRate, "Every path outlives its owner")
+	require.Equal(t, 0.0, result.Lifespan.ProbabilityOfRuin)
+	require.Equal(t, 0.0, result.Lifespan.ExpectedShortfallYears)
+	require.LessOrEqual(t, result.Lifespan.ExpectedLifespanYears, 1.0)
+	// The bequest is what remains after the withdrawals made up to death.
+	expectedBequest := params.InitialValue - 100*12*result.Lifespan.ExpectedLifespanYears
+	require.InDelta(t, expectedBequest, result.Lifespan.ExpectedBequest, 1e-6)
+}
+
+func TestRunSimulationPaths_LifespanOutlivesMoney(t *testing.T) {
+	// Nobody dies before the table ends at 119, so every life runs to the 36-month horizon.
+	rates := make([]mortality.Rate, 0, 30)
+	for age := 90; age < mortality.MaxAge; age++ {
+		rates = append(rates, mortality.Rate{Age: age, Qx: 0.0})
+	}
+	table, err := mortality.NewTable(rates)
+	require.NoError(t, err)
+
+	params := Params{
+		InitialValue:   2400,
+		WithdrawalRate: 0.5,
+		Simulations:    10,
+		Periods:        36,
+		Lifespan:       &Lifespan{Table: table, StartAge: 90},
+	}
+	result, err := runSimulationPaths(params, func() float64 { return 0.0 })
+	require.NoError(t, err)
+	require.Equal(t, 0.0, result.SuccessRate)
+	require.Equal(t, 1.0, result.Lifespan.ProbabilityOfRuin)
+	require.Equal(t, 0.0, result.Lifespan.ExpectedBequest)
+	require.InDelta(t, 3.0, result.Lifespan.ExpectedLifespanYears, 1e-9)
+	// Depleted in month 24 and alive through month 36: months 24 to 36 are unfunded.
+	require.InDelta(t, 13.0/12, result.Lifespan.ExpectedShortfallYears, 1e-9)
+	require.InDelta(t, 13.0/12, result.Lifespan.ShortfallYearsWhenDepleted, 1e-9)
+}
+
+func TestSimulateBootstrap_SeedReproducible(t *testing.T) {
+	params := Params{
+		InitialValue:     10000,
+		Returns:          []float64{0.01, 0.005, 0.02, -0.01, 0.015, 0.008, -0.003},
+		WithdrawalRate:   0.04,
+		InflationPerYear: 0.02,
+		Simulations:      20,
+		Periods:          24,
+		Seed:             12345,
+	}
+	first, err := SimulateBootstrap(params)
+	require.NoError(t, err)
+	second, err := SimulateBootstrap(params)
+	require.NoError(t, err)
+	require.Equal(t, first.Paths, second.Paths)
+	require.Equal(t, int64(12345), first.Seed)
+
+	params.Seed = 0
+	unseeded, err := SimulateBootstrap(params)
+	require.NoError(t, err)
+	require.NotZero(t, unseeded.Seed, "A seed is assigned when none is given")
+}
+
+func TestAnnuity_Payment(t *testing.T) {
+	annuity := &Annuity{Period: 12, Fraction: 0.5, PayoutRate: 0.06, COLA: 0.02}
+	require.Equal(t, 500.0, annuity.premium(1000))
+	require.Equal(t, 0.0, annuity.payment(1200, 12), "No income until after the purchase period")
+	require.InDelta(t, 6.0, annuity.payment(1200, 13), 1e-12)
+	require.InDelta(t, 6.0, annuity.payment(1200, 24), 1e-12)
+	require.InDelta(t, 6.12, annuity.payment(1200, 25), 1e-12, "COLA applies after each full year")
+
+	fixed := &Annuity{Amount: 800, PayoutRate: 0.06}
+	require.Equal(t, 800.0, fixed.premium(1000))
+	require.Equal(t, 500.0, fixed.premium(500), "Premium is capped at the portfolio value")
+}
+
+func TestSimulateNormal_AnnuityComparison(t *testing.T) {
+	params := Params{
+		InitialValue:   100000,
+		Returns:        []float64{0.01, -0.03, 0.02, 0.015, -0.02, 0.01},
+		WithdrawalRate: 0.09,
+		Simulations:    200,
+		Periods:        30 * 12,
+		Seed:           7,
+		// Annuitising 80% at an 11% payout funds almost all of the 9% withdrawal for life.
+		Annuity: &Annuity{Period: 0, Fraction: 0.8, PayoutRate: 0.11},
+	}
+	result, err := SimulateNormal(params)
+	require.NoError(t, err)
+	require.NotNil(t, result.Annuity)
+	require.Equal(t, 1.0, result.Annuity.PurchaseRate)
+	require.InDelta(t, 80000, result.Annuity.AveragePremium, 1e-6)
+	require.InDelta(t, 80000*0.11/12, result.Annuity.AverageMonthlyIncome, 1e-6)
+	require.Equal(t, result.SuccessRate, result.Annuity.WithAnnuity.SuccessRate)
+	require.Greater(t, result.Annuity.WithAnnuity.SuccessRate, result.Annuity.WithoutAnnuity.SuccessRate)
+
+	for _, path := range result.Paths {
+		require.InDelta(t, 20000, path[0], 1e-6, "The premium leaves the portfolio at the start")
+	}
+
+	// The comparison run matches a plain run on the same seed.
+	params.Annuity = nil
+	plain, err := SimulateNormal(params)
+	require.NoError(t, err)
+	require.Equal(t, outcomeOf(plain), result.Annuity.WithoutAnnuity)
+}
+
+func TestSimulateNormal_FullyAnnuitized(t *testing.T) {
+	params := Params{
+		InitialValue:   100000,
+		Returns:        []float64{0.01, -0.03, 0.02, 0.015, -0.02, 0.01},
+		WithdrawalRate: 0.05,
+		Simulations:    50,
+		Periods:        30 * 12,
+		Seed:           7,
+		// Annuitising everything at the withdrawal rate funds the whole withdrawal for life.
+		Annuity: &Annuity{Period: 0, Fraction: 1, PayoutRate: 0.05},
+	}
+	result, err := SimulateNormal(params)
+	require.NoError(t, err)
+	require.NotNil(t, result.Annuity)
+	require.Equal(t, 1.0, result.SuccessRate)
+	require.Equal(t, 1.0, result.Annuity.WithAnnuity.SuccessRate)
+	for _, path := range result.Paths {
+		require.Zero(t, path[len(path)-1], "Nothing is left invested")
+	}
+}
+
+func TestRunSimulationPaths_BucketRefillRules(t *testing.T) {
+	testCases := []struct {
+		name           string
+		refill         RefillRule
+		expEmptyMonths float64
+	}{
+		// A falling market never triggers an up-year refill, so the bucket runs dry in year two.
+		{"up-years", RefillUpYears, 12},
+		{"annual", RefillAnnual, 0},
+		{"monthly", RefillMonthly, 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			params := Params{
+				InitialValue:   12000,
+				WithdrawalRate: 0.1, // 100 per month.
+				Simulations:    3,
+				Periods:        24,
+				Bucket:         &Bucket{Years: 1, Refill: tc.refill},
+			}
+			result, err := runSimulationPaths(params, func() float64 { return -0.01 })
+			require.NoError(t, err)
+			require.NotNil(t, result.Bucket)
+			require.Equal(t, 1.0, result.SuccessRate)
+			require.Equal(t, tc.expEmptyMonths, result.Bucket.AverageEmptyMonths)
+			if tc.expEmptyMonths > 0 {
+				require.Equal(t, 1.0, result.Bucket.EmptiedRate)
+			} else {
+				require.Equal(t, 0.0, result.Bucket.EmptiedRate)
+			}
+			require.Equal(t, params.InitialValue, result.Paths[0][0])
+		})
+	}
+}
+
+func TestRunSimulationPaths_BucketYield(t *testing.T) {
+	params := Params{
+		InitialValue:   12000,
+		WithdrawalRate: 0.1,
+		Simulations:    1,
+		Periods:        12,
+		Bucket:         &Bucket{Years: 1, Yield: 0.12, Refill: RefillAnnual},
+	}
+	result, err := runSimulationPaths(params, func() float64 { return 0.0 })
+	require.NoError(t, err)
+	// 1200 in cash earns one month of a 12% annual yield before paying the 100 withdrawal.
+	expected := 10800 + 1200*math.Pow(1.12, 1.0/12) - 100
+	require.InDelta(t, expected, result.Paths[0][1], 1e-9)
+}
+
+func TestSimulateBootstrap_BucketComparison(t *testing.T) {
+	params := Params{
+		InitialValue:     100000,
+		Returns:          []float64{0.04, -0.05, 0.03, -0.02, 0.01, 0.02, -0.03},
+		WithdrawalRate:   0.06,
+		InflationPerYear: 0.02,
+		Simulations:      100,
+		Periods:          25 * 12,
+		Seed:             99,
+		Bucket:           &Bucket{Years: 2, Yield: 0.02, Refill: RefillUpYears},
+	}
+	result, err := SimulateBootstrap(params)
+	require.NoError(t, err)
+	require.NotNil(t, result.Bucket)
+	require.Equal(t, outcomeOf(result), result.Bucket.WithBucket)
+
+	params.Bucket = nil
+	plain, err := SimulateBootstrap(params)
+	require.NoError(t, err)
+	require.Equal(t, outcomeOf(plain), result.Bucket.WithoutBucket)
+}
@@ -0,0 +1,298 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"portfolio-simulator/backend/internal/simulation/sobol"
+)
+
+// vectorSource yields the per-asset returns of successive simulated paths.
+type vectorSource interface {
+	// startPath prepares the source for a new path.
+	startPath()
+	// next writes the asset returns for the next period of the current path into returns.
+	next(returns []float64)
+}
+
+// allocatedSource turns per-asset returns into portfolio returns using the allocation
+// for each period, modelling a portfolio rebalanced to its target at the start of every month.
+type allocatedSource struct {
+	assets      vectorSource
+	allocations [][]float64 // Allocation at each period; the one at t-1 applies to period t.
+	returns     []float64
+	t           int
+}
+
+func newAllocatedSource(assets vectorSource, allocations [][]float64, numAssets int) *allocatedSource {
+	return &allocatedSource{assets: assets, allocations: allocations, returns: make([]float64, numAssets)}
+}
+
+func (s *allocatedSource) startPath() {
+	s.assets.startPath()
+	s.t = 0
+}
+
+func (s *allocatedSource) next() float64 {
+	s.assets.next(s.returns)
+	weights := s.allocations[s.t]
+	s.t++
+	portfolioReturn := 0.0
+	for i, w := range weights {
+		portfolioReturn += w * s.returns[i]
+	}
+	return portfolioReturn
+}
+
+// bootstrapVectorSource samples whole historical months, so the cross-asset
+// correlation of each month is preserved.
+type bootstrapVectorSource struct {
+	history [][]float64
+	rng     *rand.Rand
+	months  []int
+	t       int
+}
+
+func newBootstrapVectorSource(history [][]float64, periods int, rng *rand.Rand) *bootstrapVectorSource {
+	return &bootstrapVectorSource{history: history, rng: rng, months: make([]int, periods)}
+}
+
+func (s *bootstrapVectorSource) startPath() {
+	for i := range s.months {
+		s.months[i] = s.rng.Intn(len(s.history))
+	}
+	s.t = 0
+}
+
+func (s *bootstrapVectorSource) next(returns []float64) {
+	copy(returns, s.history[s.months[s.t]])
+	s.t++
+}
+
+// normalVectorSource draws multivariate normal returns with the historical means and
+// covariance. A whole path is drawn up front to keep seeded runs on common random numbers.
+type normalVectorSource struct {
+	mean    []float64
+	chol    [][]float64
+	rng     *rand.Rand
+	normals [][]float64 // Standard normal draws per period and asset.
+	t       int
+}
+
+func newNormalVectorSource(history [][]float64, periods int, rng *rand.Rand) *normalVectorSource {
+	mean, cov := meanCovariance(history)
+	normals := make([][]float64, periods)
+	for t := range normals {
+		normals[t] = make([]float64, len(mean))
+	}
+	return &normalVectorSource{mean: mean, chol: cholesky(cov), rng: rng, normals: normals}
+}
+
+func (s *normalVectorSource) startPath() {
+	for _, z := range s.normals {
+		for i := range z {
+			z[i] = s.rng.NormFloat64()
+		}
+	}
+	s.t = 0
+}
+
+func (s *normalVectorSource) next(returns []float64) {
+	correlate(s.mean, s.chol, s.normals[s.t], returns)
+	s.t++
+}
+
+// sobolVectorSource draws multivariate normal returns from a scrambled Sobol sequence.
+// Each asset's draws go through its own Brownian bridge, and the coordinates are
+// interleaved so that the leading dimensions fix the terminal values of all assets.
+type sobolVectorSource struct {
+	seq        *sobol.Sequence
+	bridge     *brownianBridge
+	mean       []float64
+	chol       [][]float64
+	point      []float64
+	normals    []float64
+	increments [][]float64 // Bridged increments per asset and period.
+	draws      []float64
+	t          int
+}
+
+func newSobolVectorSource(history [][]float64, periods int, rng *rand.Rand) (*sobolVectorSource, error) {
+	mean, cov := meanCovariance(history)
+	seq, err := sobol.NewScrambled(periods*len(mean), rng)
+	if err != nil {
+		return nil, fmt.Errorf("simulation: failed to create Sobol sequence: %w", err)
+	}
+	increments := make([][]float64, len(mean))
+	for a := range increments {
+		increments[a] = make([]float64, periods)
+	}
+	return &sobolVectorSource{
+		seq:        seq,
+		bridge:     newBrownianBridge(periods),
+		mean:       mean,
+		chol:       cholesky(cov),
+		point:      make([]float64, seq.Dim()),
+		normals:    make([]float64, periods),
+		increments: increments,
+		draws:      make([]float64, len(mean)),
+	}, nil
+}
+
+func (s *sobolVectorSource) startPath() {
+	s.seq.Next(s.point)
+	assets := len(s.mean)
+	for a := range s.increments {
+		for k := range s.normals {
+			s.normals[k] = inverseNormalCDF(s.point[k*assets+a])
+		}
+		s.bridge.transform(s.normals, s.increments[a])
+	}
+	s.t = 0
+}
+
+func (s *sobolVectorSource) next(returns []float64) {
+	for a := range s.draws {
+		s.draws[a] = s.increments[a][s.t]
+	}
+	correlate(s.mean, s.chol, s.draws, returns)
+	s.t++
+}
+
+// simulateGlidePath runs the multi-asset engine, drawing per-asset returns from
+// params.AssetReturns with the given method and weighting them by the glide path.
+func simulateGlidePath(params Params, method string) (*Result, error) {
+	assets, numAssets, err := newAssetSource(params, method)
+	if err != nil {
+		return nil, err
+	}
+	if err := params.GlidePath.validate(numAssets); err != nil {
+		return nil, err
+	}
+	if assets, err = stressAssets(params, assets, numAssets); err != nil {
+		return nil, err
+	}
+
+	allocations := params.GlidePath.Allocations(params.Periods)
+	result, err := runPaths(params, newAllocatedSource(assets, allocations, numAssets))
+	if err != nil {
+		return nil, err
+	}
+	result.Allocations = allocations
+	return result, nil
+}
+
+// newAssetSource checks params.AssetReturns and returns a source drawing per-asset
+// returns from it with the given method, along with the number of assets.
+func newAssetSource(params Params, method string) (vectorSource, int, error) {
+	history := params.AssetReturns
+	if len(history) == 0 {
+		return nil, 0, errors.New("simulation: asset returns are empty, cannot simulate multiple assets")
+	}
+	numAssets := len(history[0])
+	for _, row := range history {
+		if len(row) != numAssets {
+			return nil, 0, errors.New("simulation: every period of asset returns must have the same number of assets")
+		}
+	}
+	if params.Periods <= 0 {
+		return nil, 0, errors.New("simulation: number of periods must be positive")
+	}
+
+	rng := newStreamRand(params.Seed, streamReturns)
+	switch {
+	case method == methodBootstrap && params.Sampler == SamplerSobol:
+		return nil, 0, errors.New("simulation: the Sobol sampler is only supported by parametric methods")
+	case method == methodBootstrap:
+		return newBootstrapVectorSource(history, params.Periods, rng), numAssets, nil
+	case params.Sampler == SamplerSobol:
+		source, err := newSobolVectorSource(history, params.Periods, rng)
+		if err != nil {
+			return nil, 0, err
+		}
+		return source, numAssets, nil
+	case params.Sampler == "" || params.Sampler == SamplerPseudoRandom:
+		return newNormalVectorSource(history, params.Periods, rng), numAssets, nil
+	default:
+		return nil, 0, fmt.Errorf("simulation: unknown sampler %q", params.Sampler)
+	}
+}
+
+// correlate writes mean + chol*z into out.
+func correlate(mean []float64, chol [][]float64, z, out []float64) {
+	for i := range out {
+		v := mean[i]
+		for j := 0; j <= i; j++ {
+			v += chol[i][j] * z[j]
+		}
+		out[i] = v
+	}
+}
+
+// meanCovariance returns the column means and sample covariance matrix of rows.
+func meanCovariance(rows [][]float64) ([]float64, [][]float64) {
+	n := len(rows[0])
+	mean := make([]float64, n)
+	for _, row := range rows {
+		for i, v := range row {
+			mean[i] += v
+		}
+	}
+	for i := range mean {
+		mean[i] /= float64(len(rows))
+	}
+
+	cov := make([][]float64, n)
+	for i := range cov {
+		cov[i] = make([]float64, n)
+	}
+	if len(rows) < 2 {
+		return mean, cov
+	}
+	for _, row := range rows {
+		for i := 0; i < n; i++ {
+			di := row[i] - mean[i]
+			for j := 0; j <= i; j++ {
+				cov[i][j] += di * (row[j] - mean[j])
+			}
+		}
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			cov[i][j] /= float64(len(rows) - 1)
+			cov[j][i] = cov[i][j]
+		}
+	}
+	return mean, cov
+}
+
+// cholesky returns the lower-triangular factor L with L*L^T = a. Positive
+// semi-definite matrices are supported: columns with no remaining variance, such
+// as a duplicated or constant asset, are left at zero.
+func cholesky(a [][]float64) [][]float64 {
+	n := len(a)
+	l := make([][]float64, n)
+	for i := range l {
+		l[i] = make([]float64, n)
+	}
+	for j := 0; j < n; j++ {
+		d := a[j][j]
+		for k := 0; k < j; k++ {
+			d -= l[j][k] * l[j][k]
+		}
+		if d <= 1e-12*math.Max(a[j][j], 1e-300) {
+			continue
+		}
+		l[j][j] = math.Sqrt(d)
+		for i := j + 1; i < n; i++ {
+			s := a[i][j]
+			for k := 0; k < j; k++ {
+				s -= l[i][k] * l[j][k]
+			}
+			l[i][j] = s / l[j][j]
+		}
+	}
+	return l
+}
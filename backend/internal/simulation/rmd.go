@@ -0,0 +1,53 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+
+	"portfolio-simulator/backend/internal/mortality"
+)
+
+// RMD enforces required minimum distributions from tax-deferred accounts. From
+// StartAge on, each simulated year must withdraw at least the account's balance at
+// the start of the year divided by the divisor for the owner's age. Whatever spending
+// did not already take out is withdrawn at the end of the year, taxed as ordinary
+// income, and the remainder reinvested in the first taxable account.
+type RMD struct {
+	Age      int                     // Owner's age at the start of the simulation.
+	StartAge int                     // Age from which distributions are required (e.g. 73).
+	Table    *mortality.DivisorTable // Divisors by age.
+}
+
+// validate checks the rule against the accounts it applies to.
+func (r *RMD) validate(accounts []Account) error {
+	if r.Table == nil {
+		return errors.New("simulation: RMD rule requires a divisor table")
+	}
+	if r.Age < 0 || r.StartAge < r.Table.StartAge {
+		return fmt.Errorf("simulation: RMD start age must be at least %d, the first age of the divisor table", r.Table.StartAge)
+	}
+	if reinvestmentAccount(accounts) < 0 {
+		return errors.New("simulation: RMDs require a taxable account to reinvest excess distributions")
+	}
+	return nil
+}
+
+// required returns the distribution required in the given simulation year (counted
+// from 0) from an account holding balance at the start of that year.
+func (r *RMD) required(year int, balance float64) float64 {
+	age := r.Age + year
+	if age < r.StartAge {
+		return 0
+	}
+	return balance / r.Table.Divisor(age)
+}
+
+// reinvestmentAccount returns the index of the account receiving excess distributions, or -1 if there is none.
+func reinvestmentAccount(accounts []Account) int {
+	for i, a := range accounts {
+		if a.Type == TaxTypeTaxable {
+			return i
+		}
+	}
+	return -1
+}
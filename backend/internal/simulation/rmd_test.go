@@ -0,0 +1,73 @@
+package simulation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"portfolio-simulator/backend/internal/mortality"
+)
+
+// rmdParams returns a flat-return run of two years with an IRA, an empty brokerage
+// account and no spending.
+func rmdParams(age int) Params {
+	return Params{
+		InitialValue: 100000,
+		Periods:      24,
+		Simulations:  1,
+		AssetReturns: [][]float64{{0}},
+		Accounts: &MultiAccount{
+			Accounts: []Account{
+				{Name: "brokerage", Type: TaxTypeTaxable, Weights: []float64{1}},
+				{Name: "ira", Type: TaxTypeDeferred, Balance: 100000, Weights: []float64{1}},
+			},
+			Tax: TaxModel{OrdinaryRate: 0.25},
+			RMD: &RMD{Age: age, StartAge: 73, Table: mortality.UniformLifetime()},
+		},
+	}
+}
+
+func TestSimulateBootstrap_RMDReinvestedInTaxable(t *testing.T) {
+	result, err := SimulateBootstrap(rmdParams(75))
+	require.NoError(t, err)
+
+	first := 100000 / 24.6
+	second := (100000 - first) / 23.7
+	stats := result.Accounts
+	require.InDelta(t, first+second, stats.ExcessRMD.Mean, 1e-6)
+	require.InDelta(t, 0.25*(first+second), stats.Taxes.Mean, 1e-6)
+	require.Zero(t, stats.Spending.Mean, "Forced distributions are reinvested, not spent")
+	require.InDeltaSlice(t, []float64{0.75 * (first + second), 100000 - first - second}, stats.MeanFinalBalances, 1e-6)
+	// The reinvested amount was already taxed, so it is the brokerage's cost basis.
+	require.InDelta(t, 0.75*(first+second)+0.75*(100000-first-second), stats.AfterTaxTerminal.Mean, 1e-6)
+}
+
+func TestSimulateBootstrap_RMDNotBeforeStartAge(t *testing.T) {
+	result, err := SimulateBootstrap(rmdParams(70))
+	require.NoError(t, err)
+	require.Zero(t, result.Accounts.ExcessRMD.Mean)
+	require.Equal(t, 100000.0, result.Paths[0][24])
+}
+
+func TestSimulateBootstrap_RMDCoveredBySpending(t *testing.T) {
+	// Spending 6% a year from the IRA exceeds the 1/24.6 required at 75.
+	params := rmdParams(75)
+	params.WithdrawalRate = 0.06
+	params.Accounts.Order = WithdrawalDeferredFirst
+
+	result, err := SimulateBootstrap(params)
+	require.NoError(t, err)
+	require.Zero(t, result.Accounts.ExcessRMD.Mean)
+	require.InDelta(t, 12000.0, result.Accounts.Spending.Mean, 1e-6)
+}
+
+func TestRMD_Validate(t *testing.T) {
+	params := rmdParams(75)
+	params.Accounts.Accounts = params.Accounts.Accounts[1:]
+	_, err := SimulateBootstrap(params)
+	require.ErrorContains(t, err, "require a taxable account")
+
+	params = rmdParams(75)
+	params.Accounts.RMD.StartAge = 70
+	_, err = SimulateBootstrap(params)
+	require.ErrorContains(t, err, "at least 72")
+}
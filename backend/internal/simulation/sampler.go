@@ -0,0 +1,128 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"portfolio-simulator/backend/internal/simulation/sobol"
+)
+
+// Sampler selects how the random draws of parametric simulation methods are generated.
+type Sampler string
+
+const (
+	// SamplerPseudoRandom draws independent pseudo-random normals. It is the default.
+	SamplerPseudoRandom Sampler = "pseudo"
+	// SamplerSobol draws from a scrambled Sobol sequence with Brownian-bridge ordering.
+	SamplerSobol Sampler = "sobol"
+)
+
+// pathSource yields the per-period returns of successive simulated paths.
+type pathSource interface {
+	// startPath prepares the source for a new path.
+	startPath()
+	// next returns the return for the next period of the current path.
+	next() float64
+}
+
+// streamSource adapts a stateless return generator to a pathSource.
+type streamSource func() float64
+
+func (s streamSource) startPath()    {}
+func (s streamSource) next() float64 { return s() }
+
+// bufferedSource draws a whole path of returns when the path starts, so every path
+// consumes the same number of draws whether or not it is depleted early. Runs that
+// share a seed therefore see identical returns path by path.
+type bufferedSource struct {
+	generate func() float64
+	returns  []float64
+	t        int
+}
+
+func newBufferedSource(periods int, generate func() float64) *bufferedSource {
+	return &bufferedSource{generate: generate, returns: make([]float64, max(periods, 0))}
+}
+
+func (s *bufferedSource) startPath() {
+	for i := range s.returns {
+		s.returns[i] = s.generate()
+	}
+	s.t = 0
+}
+
+func (s *bufferedSource) next() float64 {
+	r := s.returns[s.t]
+	s.t++
+	return r
+}
+
+// Random streams derived from a run's seed. Each feature draws from its own stream,
+// so enabling one does not shift the draws seen by another.
+const (
+	streamReturns uint64 = iota + 1
+	streamLifespan
+)
+
+// newStreamRand returns a generator for the given stream of a seeded run.
+func newStreamRand(seed int64, stream uint64) *rand.Rand {
+	// SplitMix64 finaliser to decorrelate the streams of nearby seeds.
+	z := uint64(seed) + stream*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z ^= z >> 31
+	return rand.New(rand.NewSource(int64(z)))
+}
+
+// sobolSource generates normally distributed returns from a scrambled Sobol
+// sequence. Each path consumes one point whose dimension equals the number of
+// periods; the Brownian bridge assigns the first coordinates to the coarsest
+// features of the path, where quasi-random points are most evenly spread.
+type sobolSource struct {
+	seq        *sobol.Sequence
+	bridge     *brownianBridge
+	mean, std  float64
+	point      []float64
+	normals    []float64
+	increments []float64
+	t          int
+}
+
+// newSobolSource creates a Sobol-backed source producing returns with the given mean and standard deviation.
+// rng randomises the scrambling.
+func newSobolSource(periods int, mean, std float64, rng *rand.Rand) (*sobolSource, error) {
+	seq, err := sobol.NewScrambled(periods, rng)
+	if err != nil {
+		return nil, fmt.Errorf("simulation: failed to create Sobol sequence: %w", err)
+	}
+	return &sobolSource{
+		seq:        seq,
+		bridge:     newBrownianBridge(periods),
+		mean:       mean,
+		std:        std,
+		point:      make([]float64, periods),
+		normals:    make([]float64, periods),
+		increments: make([]float64, periods),
+	}, nil
+}
+
+func (s *sobolSource) startPath() {
+	s.seq.Next(s.point)
+	for i, u := range s.point {
+		s.normals[i] = inverseNormalCDF(u)
+	}
+	s.bridge.transform(s.normals, s.increments)
+	s.t = 0
+}
+
+func (s *sobolSource) next() float64 {
+	z := s.increments[s.t]
+	s.t++
+	return s.mean + s.std*z
+}
+
+// inverseNormalCDF returns the standard normal quantile of u, which must lie in (0, 1).
+func inverseNormalCDF(u float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*u-1)
+}
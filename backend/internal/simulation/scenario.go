@@ -0,0 +1,268 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// AssetClass is the broad class of an asset, which decides the returns it earns during
+// a stress scenario.
+type AssetClass string
+
+const (
+	ClassEquity AssetClass = "equity"
+	ClassBond   AssetClass = "bond"
+	ClassCash   AssetClass = "cash"
+)
+
+// Scenario is a deterministic stress: a sequence of monthly returns per asset class
+// spliced into every simulated path from a chosen period, replacing the simulated
+// returns of those months. Each asset earns the returns of its class in
+// Params.AssetClasses; without classes the whole portfolio earns the equity returns.
+type Scenario struct {
+	Name      string    // Label for reporting.
+	Start     int       // Period after which the first return applies; 0 means the first month.
+	Returns   []float64 // Monthly equity returns of the stress; months past the horizon are dropped.
+	Bonds     []float64 // Monthly bond returns, as many as Returns; nil holds bonds flat.
+	Cash      []float64 // Monthly cash returns, as many as Returns; nil holds cash flat.
+	Inflation *float64  // Annual inflation during the stress; nil keeps Params.InflationPerYear.
+}
+
+// ScenarioOutcome is the result of the simulation with one scenario spliced in.
+type ScenarioOutcome struct {
+	Name       string
+	Start      int
+	Outcome    Outcome      // Outcome with the scenario, on the same simulated returns as the baseline.
+	FinalStats SummaryStats // Final values with the scenario.
+}
+
+// StressStats compares the baseline run with each stress scenario.
+type StressStats struct {
+	Baseline  Outcome
+	Scenarios []ScenarioOutcome
+}
+
+// validate checks the scenario against the simulation horizon.
+func (s *Scenario) validate(periods int) error {
+	if len(s.Returns) == 0 {
+		return fmt.Errorf("simulation: scenario %q has no returns", s.Name)
+	}
+	if s.Start < 0 || s.Start >= periods {
+		return fmt.Errorf("simulation: scenario %q must start between period 0 and %d", s.Name, periods-1)
+	}
+	for _, class := range []AssetClass{ClassEquity, ClassBond, ClassCash} {
+		returns := s.classReturns(class)
+		if returns != nil && len(returns) != len(s.Returns) {
+			return fmt.Errorf("simulation: scenario %q must have as many %s returns as equity returns", s.Name, class)
+		}
+		for _, r := range returns {
+			if r <= -1 || math.IsNaN(r) {
+				return fmt.Errorf("simulation: scenario %q has a return of -100%% or less", s.Name)
+			}
+		}
+	}
+	if s.Inflation != nil && (*s.Inflation < -0.1 || *s.Inflation > 1) {
+		return fmt.Errorf("simulation: scenario %q inflation must be between -0.1 and 1", s.Name)
+	}
+	return nil
+}
+
+// classReturns returns the stress's monthly returns for an asset class; nil when the
+// class is held flat.
+func (s *Scenario) classReturns(class AssetClass) []float64 {
+	switch class {
+	case ClassBond:
+		return s.Bonds
+	case ClassCash:
+		return s.Cash
+	}
+	return s.Returns
+}
+
+// month returns the month of the stress that period t (1-based) falls in and whether
+// the scenario covers it.
+func (s *Scenario) month(t int) (int, bool) {
+	k := t - 1 - s.Start
+	return k, k >= 0 && k < len(s.Returns)
+}
+
+// classAt returns the class's return in month k of the stress.
+func (s *Scenario) classAt(class AssetClass, k int) float64 {
+	if returns := s.classReturns(class); returns != nil {
+		return returns[k]
+	}
+	return 0
+}
+
+// portfolioReturns returns the stress's monthly returns of a portfolio rebalanced to
+// weights, whose assets are of the given classes. Without classes the portfolio is
+// taken to be all equity.
+func (s *Scenario) portfolioReturns(classes []AssetClass, weights []float64) []float64 {
+	if classes == nil {
+		return s.Returns
+	}
+	returns := make([]float64, len(s.Returns))
+	for k := range returns {
+		for i, class := range classes {
+			returns[k] += weights[i] * s.classAt(class, k)
+		}
+	}
+	return returns
+}
+
+// inflationMonths returns how many of the months before period t (1-based) fall within
+// the scenario, i.e. how many months of price growth use the scenario's inflation.
+func (s *Scenario) inflationMonths(t int) int {
+	if s.Inflation == nil {
+		return 0
+	}
+	end := min(t-1, s.Start+len(s.Returns))
+	return max(end-s.Start, 0)
+}
+
+// scenarioSource overrides the returns of an underlying source during a scenario with
+// the portfolio's stress returns. The underlying source is still drawn every period, so
+// months outside the scenario match the baseline run.
+type scenarioSource struct {
+	source   pathSource
+	scenario *Scenario
+	returns  []float64 // Portfolio return in each month of the stress.
+	t        int
+}
+
+func (s *scenarioSource) startPath() {
+	s.source.startPath()
+	s.t = 0
+}
+
+func (s *scenarioSource) next() float64 {
+	r := s.source.next()
+	s.t++
+	if k, ok := s.scenario.month(s.t); ok {
+		return s.returns[k]
+	}
+	return r
+}
+
+// scenarioVectorSource is scenarioSource for per-asset returns: each asset earns the
+// stress returns of its class.
+type scenarioVectorSource struct {
+	source   vectorSource
+	scenario *Scenario
+	classes  []AssetClass // Class of each asset; nil treats every asset as equity.
+	t        int
+}
+
+func (s *scenarioVectorSource) startPath() {
+	s.source.startPath()
+	s.t = 0
+}
+
+func (s *scenarioVectorSource) next(returns []float64) {
+	s.source.next(returns)
+	s.t++
+	if k, ok := s.scenario.month(s.t); ok {
+		for i := range returns {
+			class := ClassEquity
+			if s.classes != nil {
+				class = s.classes[i]
+			}
+			returns[i] = s.scenario.classAt(class, k)
+		}
+	}
+}
+
+// stressAssets wraps assets in the current run's scenario, if any, after checking that
+// params.AssetClasses covers the assets.
+func stressAssets(params Params, assets vectorSource, numAssets int) (vectorSource, error) {
+	if params.scenario == nil {
+		return assets, nil
+	}
+	if params.AssetClasses != nil && len(params.AssetClasses) != numAssets {
+		return nil, fmt.Errorf("simulation: %d asset classes given for %d assets", len(params.AssetClasses), numAssets)
+	}
+	return &scenarioVectorSource{source: assets, scenario: params.scenario, classes: params.AssetClasses}, nil
+}
+
+// scenarioLibrary holds built-in stresses, approximating the total returns of US equities,
+// intermediate-term US bonds and Treasury bills.
+var scenarioLibrary = map[string]Scenario{
+	// October 2007 to February 2009: equities about -51%, and bonds about +8% spread
+	// evenly over the months.
+	"2008-crash": {
+		Returns: []float64{
+			0.016, -0.042, -0.007, -0.060, -0.033, -0.004, 0.049, 0.013, -0.084,
+			-0.008, 0.013, -0.089, -0.168, -0.072, 0.011, -0.084, -0.107,
+		},
+		Bonds: repeat(0.0045, 17),
+		Cash:  repeat(0.0015, 17),
+	},
+	// 1973 to 1982: annual returns spread evenly over each year, with high inflation.
+	"1970s-stagflation": {
+		Returns:   annualToMonthly([]float64{-0.147, -0.265, 0.372, 0.238, -0.072, 0.066, 0.184, 0.324, -0.049, 0.214}),
+		Bonds:     annualToMonthly([]float64{0.046, 0.057, 0.078, 0.129, 0.014, 0.035, 0.041, 0.039, 0.095, 0.291}),
+		Cash:      annualToMonthly([]float64{0.069, 0.080, 0.058, 0.051, 0.051, 0.072, 0.104, 0.112, 0.147, 0.105}),
+		Inflation: rate(0.087),
+	},
+	// 2000 to 2002.
+	"dot-com-bust": {
+		Returns: annualToMonthly([]float64{-0.091, -0.119, -0.221}),
+		Bonds:   annualToMonthly([]float64{0.116, 0.084, 0.103}),
+		Cash:    annualToMonthly([]float64{0.058, 0.039, 0.017}),
+	},
+	// September to November 1987.
+	"1987-crash": {
+		Returns: []float64{-0.022, -0.215, -0.082},
+		Bonds:   []float64{-0.016, 0.029, 0.006},
+		Cash:    repeat(0.005, 3),
+	},
+}
+
+// rate returns a pointer to r, for optional rates in scenario literals.
+func rate(r float64) *float64 { return &r }
+
+// repeat returns n months of the same return.
+func repeat(r float64, n int) []float64 {
+	returns := make([]float64, n)
+	for i := range returns {
+		returns[i] = r
+	}
+	return returns
+}
+
+// annualToMonthly spreads each annual return evenly over its twelve months.
+func annualToMonthly(annual []float64) []float64 {
+	monthly := make([]float64, 0, 12*len(annual))
+	for _, r := range annual {
+		m := math.Pow(1+r, 1.0/12) - 1
+		for range 12 {
+			monthly = append(monthly, m)
+		}
+	}
+	return monthly
+}
+
+// LibraryScenario returns the built-in scenario with the given name, starting at start.
+func LibraryScenario(name string, start int) (Scenario, error) {
+	s, ok := scenarioLibrary[name]
+	if !ok {
+		return Scenario{}, fmt.Errorf("simulation: unknown scenario %q", name)
+	}
+	s.Name = name
+	s.Start = start
+	s.Returns = append([]float64(nil), s.Returns...)
+	s.Bonds = append([]float64(nil), s.Bonds...)
+	s.Cash = append([]float64(nil), s.Cash...)
+	return s, nil
+}
+
+// ScenarioNames lists the built-in scenarios in alphabetical order.
+func ScenarioNames() []string {
+	names := make([]string, 0, len(scenarioLibrary))
+	for name := range scenarioLibrary {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,163 @@
+package simulation
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulateBootstrap_ScenarioSplicedIntoPaths(t *testing.T) {
+	params := Params{
+		InitialValue: 1000,
+		Returns:      []float64{0.01},
+		Periods:      4,
+		Simulations:  3,
+		Seed:         5,
+		Scenarios:    []Scenario{{Name: "crash", Start: 1, Returns: []float64{-0.5, 0.1}}},
+	}
+	result, err := SimulateBootstrap(params)
+	require.NoError(t, err)
+	require.NotNil(t, result.Stress)
+
+	baseline := 1000 * 1.01 * 1.01 * 1.01 * 1.01
+	require.InDelta(t, baseline, result.FinalStats.Mean, 1e-9, "The main run is not stressed")
+	require.Equal(t, outcomeOf(result), result.Stress.Baseline)
+
+	require.Len(t, result.Stress.Scenarios, 1)
+	stressed := result.Stress.Scenarios[0]
+	require.Equal(t, "crash", stressed.Name)
+	require.Equal(t, 1, stressed.Start)
+	require.InDelta(t, 1000*1.01*0.5*1.1*1.01, stressed.FinalStats.Mean, 1e-9)
+	require.InDelta(t, stressed.FinalStats.Mean, stressed.Outcome.MeanTerminal, 1e-9)
+}
+
+func TestSimulateBootstrap_ScenarioInflation(t *testing.T) {
+	// 12 a year at 0% inflation, except for a stress year at 10%.
+	params := Params{
+		InitialValue:   1200,
+		WithdrawalRate: 0.01,
+		Returns:        []float64{0},
+		Periods:        25,
+		Simulations:    1,
+		Scenarios:      []Scenario{{Name: "inflation", Returns: make([]float64, 12), Inflation: rate(0.1)}},
+	}
+	result, err := SimulateBootstrap(params)
+	require.NoError(t, err)
+
+	// Month 1 withdraws 1; months 2-13 see part of the stress year, the rest a full year of it.
+	spent := 0.0
+	for t := 1; t <= 25; t++ {
+		spent += math.Pow(1.1, float64(min(t-1, 12))/12)
+	}
+	require.InDelta(t, 1200-spent, result.Stress.Scenarios[0].FinalStats.Mean, 1e-9)
+	require.InDelta(t, 1200-25.0, result.FinalStats.Mean, 1e-9)
+}
+
+func TestSimulateBootstrap_ScenarioWithGlidePath(t *testing.T) {
+	params := Params{
+		InitialValue: 1000,
+		AssetReturns: [][]float64{{0.01, 0.02}},
+		Periods:      2,
+		Simulations:  1,
+		GlidePath:    &GlidePath{Points: []GlidePoint{{Period: 0, Weights: []float64{0.5, 0.5}}}},
+		Scenarios:    []Scenario{{Name: "drop", Returns: []float64{-0.2}}},
+	}
+	result, err := SimulateBootstrap(params)
+	require.NoError(t, err)
+	require.InDelta(t, 1000*0.8*1.015, result.Stress.Scenarios[0].FinalStats.Mean, 1e-9)
+}
+
+func TestSimulateBootstrap_ScenarioByAssetClass(t *testing.T) {
+	// 40% equity and 60% bonds: only the equity part takes the crash.
+	params := Params{
+		InitialValue: 1000,
+		Returns:      []float64{0.01},
+		Periods:      2,
+		Simulations:  1,
+		AssetClasses: []AssetClass{ClassEquity, ClassBond},
+		AssetWeights: []float64{0.4, 0.6},
+		Scenarios:    []Scenario{{Name: "crash", Returns: []float64{-0.5}, Bonds: []float64{0.1}}},
+	}
+	result, err := SimulateBootstrap(params)
+	require.NoError(t, err)
+	require.InDelta(t, 1000*(1+0.4*-0.5+0.6*0.1)*1.01, result.Stress.Scenarios[0].FinalStats.Mean, 1e-9)
+
+	// In glide-path runs each asset earns its class's return; cash without returns is held flat.
+	params = Params{
+		InitialValue: 1000,
+		AssetReturns: [][]float64{{0.01, 0.02}},
+		Periods:      2,
+		Simulations:  1,
+		GlidePath:    &GlidePath{Points: []GlidePoint{{Period: 0, Weights: []float64{0.5, 0.5}}}},
+		AssetClasses: []AssetClass{ClassEquity, ClassCash},
+		Scenarios:    []Scenario{{Name: "drop", Returns: []float64{-0.2}}},
+	}
+	result, err = SimulateBootstrap(params)
+	require.NoError(t, err)
+	require.InDelta(t, 1000*0.9*1.015, result.Stress.Scenarios[0].FinalStats.Mean, 1e-9)
+
+	params.AssetClasses = []AssetClass{ClassEquity}
+	_, err = SimulateBootstrap(params)
+	require.ErrorContains(t, err, "1 asset classes given for 2 assets")
+}
+
+func TestSimulateBootstrap_ScenarioZeroInflation(t *testing.T) {
+	// 10% inflation, except for a stress year without any.
+	params := Params{
+		InitialValue:     1200,
+		WithdrawalRate:   0.01,
+		InflationPerYear: 0.1,
+		Returns:          []float64{0},
+		Periods:          13,
+		Simulations:      1,
+		Scenarios:        []Scenario{{Name: "flat prices", Returns: make([]float64, 12), Inflation: rate(0)}},
+	}
+	result, err := SimulateBootstrap(params)
+	require.NoError(t, err)
+	require.InDelta(t, 1200-13.0, result.Stress.Scenarios[0].FinalStats.Mean, 1e-9, "Withdrawals do not grow during the stress")
+	require.Less(t, result.FinalStats.Mean, 1200-13.0)
+}
+
+func TestScenario_Validate(t *testing.T) {
+	require.ErrorContains(t, (&Scenario{Name: "x"}).validate(12), "no returns")
+	require.ErrorContains(t, (&Scenario{Name: "x", Start: 12, Returns: []float64{0}}).validate(12), "between period 0 and 11")
+	require.ErrorContains(t, (&Scenario{Name: "x", Returns: []float64{-1}}).validate(12), "-100%")
+	require.NoError(t, (&Scenario{Name: "x", Start: 11, Returns: []float64{0, 0}}).validate(12), "Months past the horizon are dropped")
+	require.ErrorContains(t, (&Scenario{Name: "x", Returns: []float64{0, 0}, Bonds: []float64{0}}).validate(12), "as many bond returns")
+	require.ErrorContains(t, (&Scenario{Name: "x", Returns: []float64{0}, Cash: []float64{-1}}).validate(12), "-100%")
+
+	_, err := SimulateNormal(Params{Returns: []float64{0.01, 0.02}, Periods: 12, Simulations: 1, Scenarios: []Scenario{{Name: "x"}}})
+	require.ErrorContains(t, err, "no returns")
+}
+
+func TestLibraryScenario(t *testing.T) {
+	s, err := LibraryScenario("2008-crash", 12)
+	require.NoError(t, err)
+	require.Equal(t, 12, s.Start)
+	total := 1.0
+	for _, r := range s.Returns {
+		total *= 1 + r
+	}
+	require.InDelta(t, 0.49, total, 0.02)
+
+	s.Returns[0] = 1
+	again, err := LibraryScenario("2008-crash", 0)
+	require.NoError(t, err)
+	require.NotEqual(t, 1.0, again.Returns[0], "Callers get their own copy")
+
+	stagflation, err := LibraryScenario("1970s-stagflation", 0)
+	require.NoError(t, err)
+	require.Len(t, stagflation.Returns, 120)
+	require.Greater(t, *stagflation.Inflation, 0.0)
+	for _, name := range ScenarioNames() {
+		s, err := LibraryScenario(name, 0)
+		require.NoError(t, err)
+		require.Len(t, s.Bonds, len(s.Returns), name)
+		require.Len(t, s.Cash, len(s.Returns), name)
+	}
+
+	_, err = LibraryScenario("alien-invasion", 0)
+	require.ErrorContains(t, err, "unknown scenario")
+	require.Contains(t, ScenarioNames(), "dot-com-bust")
+}
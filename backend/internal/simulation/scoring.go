@@ -0,0 +1,134 @@
+package simulation
+
+import (
+	"errors"
+	"math"
+)
+
+// Scoring configures outcome scores that weigh how badly paths fall short, not just
+// whether they do. Consumption each month is measured as a fraction of the planned
+// withdrawal: 1 when fully funded, less during and after depletion.
+type Scoring struct {
+	RiskAversion float64 // Relative risk aversion of the CRRA utility; 0 is risk neutral, 1 is log utility.
+	Floor        float64 // Consumption floor as a fraction of planned spending (e.g., other income); required when RiskAversion >= 1.
+}
+
+// ScoreStats summarises consumption across paths.
+type ScoreStats struct {
+	ExpectedUtility           float64 // Mean per-path utility, averaging the utility of each month's consumption.
+	CertaintyEquivalent       float64 // Guaranteed consumption, as a fraction of planned spending, with the same expected utility.
+	AverageShortfall          float64 // Mean share of planned spending left unfunded.
+	WorstShortfall            float64 // Largest share of planned spending left unfunded on any path.
+	AverageYearsUnfunded      float64 // Mean years with spending not fully funded, across all paths.
+	YearsUnfundedWhenDepleted float64 // Mean years with spending not fully funded, among paths that were.
+	WorstYearsUnfunded        float64 // Most years with spending not fully funded on any path.
+}
+
+// validate checks the utility parameters.
+func (s *Scoring) validate(params Params) error {
+	if params.WithdrawalRate <= 0 {
+		return errors.New("simulation: outcome scores need a withdrawal rate")
+	}
+	if s.RiskAversion < 0 || s.RiskAversion > 20 {
+		return errors.New("simulation: risk aversion must be between 0 and 20")
+	}
+	if s.Floor < 0 || s.Floor > 1 {
+		return errors.New("simulation: consumption floor must be between 0 and 1")
+	}
+	if s.RiskAversion >= 1 && s.Floor == 0 {
+		return errors.New("simulation: a consumption floor above 0 is needed for risk aversion of 1 or more")
+	}
+	return nil
+}
+
+// utility is the CRRA utility of consumption c.
+func (s *Scoring) utility(c float64) float64 {
+	c = math.Max(c, s.Floor)
+	if s.RiskAversion == 1 {
+		return math.Log(c)
+	}
+	return math.Pow(c, 1-s.RiskAversion) / (1 - s.RiskAversion)
+}
+
+// inverse returns the consumption whose utility is u.
+func (s *Scoring) inverse(u float64) float64 {
+	if s.RiskAversion == 1 {
+		return math.Exp(u)
+	}
+	return math.Pow(u*(1-s.RiskAversion), 1/(1-s.RiskAversion))
+}
+
+// scoreTracker rebuilds each path's consumption from its depletion and accumulates scores.
+// After depletion only annuity income, if any, is still paid.
+type scoreTracker struct {
+	scoring     *Scoring
+	annuity     *Annuity
+	withdrawals []float64
+	periods     int
+
+	paths          int
+	depleted       int
+	utilitySum     float64
+	shortfallSum   float64
+	worstShortfall float64
+	unfundedMonths int
+	worstUnfunded  int
+}
+
+func (s *scoreTracker) record(outcome pathOutcome) {
+	planned, unfunded, utility := 0.0, 0.0, 0.0
+	months := 0
+	for t := 1; t <= s.periods; t++ {
+		want := s.withdrawals[t]
+		got := want
+		switch d := outcome.depletedAt; {
+		case d == 0 || t < d:
+		case t == d:
+			got = want - outcome.shortfall
+		default:
+			got = 0
+			if s.annuity != nil {
+				got = math.Min(s.annuity.payment(outcome.premium, t), want)
+			}
+		}
+		planned += want
+		unfunded += want - got
+		if got < want*(1-1e-9) {
+			months++
+		}
+		utility += s.scoring.utility(got / want)
+	}
+
+	shortfall := 0.0
+	if planned > 0 {
+		shortfall = unfunded / planned
+	}
+	s.paths++
+	if months > 0 {
+		s.depleted++
+	}
+	s.utilitySum += utility / float64(s.periods)
+	s.shortfallSum += shortfall
+	s.worstShortfall = math.Max(s.worstShortfall, shortfall)
+	s.unfundedMonths += months
+	s.worstUnfunded = max(s.worstUnfunded, months)
+}
+
+func (s *scoreTracker) stats() *ScoreStats {
+	if s.paths == 0 {
+		return &ScoreStats{}
+	}
+	n := float64(s.paths)
+	stats := &ScoreStats{
+		ExpectedUtility:      s.utilitySum / n,
+		AverageShortfall:     s.shortfallSum / n,
+		WorstShortfall:       s.worstShortfall,
+		AverageYearsUnfunded: float64(s.unfundedMonths) / 12 / n,
+		WorstYearsUnfunded:   float64(s.worstUnfunded) / 12,
+	}
+	stats.CertaintyEquivalent = s.scoring.inverse(stats.ExpectedUtility)
+	if s.depleted > 0 {
+		stats.YearsUnfundedWhenDepleted = float64(s.unfundedMonths) / 12 / float64(s.depleted)
+	}
+	return stats
+}
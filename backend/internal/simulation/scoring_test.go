@@ -0,0 +1,87 @@
+package simulation
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// scoringParams spends 1 a month from 24.5 with flat returns, so the 25th withdrawal is
+// half funded and the last 11 months of the three years are not funded at all.
+func scoringParams(scoring Scoring) Params {
+	return Params{
+		InitialValue:   24.5,
+		WithdrawalRate: 12 / 24.5,
+		Returns:        []float64{0},
+		Periods:        36,
+		Simulations:    2,
+		Scoring:        &scoring,
+	}
+}
+
+func TestSimulateBootstrap_ScoresRiskNeutral(t *testing.T) {
+	result, err := SimulateBootstrap(scoringParams(Scoring{}))
+	require.NoError(t, err)
+	require.NotNil(t, result.Scores)
+
+	scores := result.Scores
+	consumed := (24 + 0.5) / 36
+	require.InDelta(t, consumed, scores.ExpectedUtility, 1e-9)
+	require.InDelta(t, consumed, scores.CertaintyEquivalent, 1e-9)
+	require.InDelta(t, 1-consumed, scores.AverageShortfall, 1e-9)
+	require.InDelta(t, 1-consumed, scores.WorstShortfall, 1e-9)
+	require.InDelta(t, 1.0, scores.AverageYearsUnfunded, 1e-9)
+	require.InDelta(t, 1.0, scores.YearsUnfundedWhenDepleted, 1e-9)
+	require.InDelta(t, 1.0, scores.WorstYearsUnfunded, 1e-9)
+	require.Equal(t, scores.CertaintyEquivalent, outcomeOf(result).CertaintyEquivalent)
+}
+
+func TestSimulateBootstrap_ScoresLogUtility(t *testing.T) {
+	result, err := SimulateBootstrap(scoringParams(Scoring{RiskAversion: 1, Floor: 0.1}))
+	require.NoError(t, err)
+
+	// Funded months have utility log(1) = 0; unfunded ones sit on the floor.
+	utility := (math.Log(0.5) + 11*math.Log(0.1)) / 36
+	require.InDelta(t, utility, result.Scores.ExpectedUtility, 1e-9)
+	require.InDelta(t, math.Exp(utility), result.Scores.CertaintyEquivalent, 1e-9)
+}
+
+func TestSimulateBootstrap_ScoresRiskAversionLowersCertaintyEquivalent(t *testing.T) {
+	params := Params{
+		InitialValue:   1000000,
+		WithdrawalRate: 0.06,
+		Returns:        []float64{0.03, -0.04, 0.02, -0.01, 0.015, 0.0, -0.06, 0.05},
+		Periods:        30 * 12,
+		Simulations:    200,
+		Seed:           4,
+	}
+	var equivalents []float64
+	for _, gamma := range []float64{0, 2, 5} {
+		params.Scoring = &Scoring{RiskAversion: gamma, Floor: 0.2}
+		result, err := SimulateBootstrap(params)
+		require.NoError(t, err)
+		require.Greater(t, result.Scores.AverageShortfall, 0.0)
+		equivalents = append(equivalents, result.Scores.CertaintyEquivalent)
+	}
+	require.Greater(t, equivalents[0], equivalents[1])
+	require.Greater(t, equivalents[1], equivalents[2])
+}
+
+func TestSimulateBootstrap_ScoresFullyFunded(t *testing.T) {
+	params := scoringParams(Scoring{RiskAversion: 3, Floor: 0.5})
+	params.InitialValue, params.WithdrawalRate = 100, 0.12
+	result, err := SimulateBootstrap(params)
+	require.NoError(t, err)
+	require.InDelta(t, 1.0, result.Scores.CertaintyEquivalent, 1e-9)
+	require.Zero(t, result.Scores.WorstShortfall)
+	require.Zero(t, result.Scores.YearsUnfundedWhenDepleted)
+}
+
+func TestScoring_Validate(t *testing.T) {
+	params := Params{WithdrawalRate: 0.04}
+	require.NoError(t, (&Scoring{RiskAversion: 0.5}).validate(params))
+	require.ErrorContains(t, (&Scoring{RiskAversion: 2}).validate(params), "consumption floor above 0")
+	require.ErrorContains(t, (&Scoring{RiskAversion: -1}).validate(params), "risk aversion")
+	require.ErrorContains(t, (&Scoring{}).validate(Params{}), "need a withdrawal rate")
+}
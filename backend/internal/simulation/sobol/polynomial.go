@@ -0,0 +1,106 @@
+package sobol
+
+// maxDegree bounds the polynomial degree; it comfortably covers millions of dimensions.
+const maxDegree = 31
+
+// polynomialSource enumerates primitive polynomials over GF(2) in order of
+// increasing degree, and within a degree in increasing order of their interior
+// coefficients.
+type polynomialSource struct {
+	degree int    // Degree currently being enumerated.
+	coeffs uint32 // Next interior coefficient pattern to test.
+}
+
+// next returns the degree and interior coefficients of the next primitive polynomial.
+func (p *polynomialSource) next() (int, uint32) {
+	if p.degree == 0 {
+		p.degree = 1
+	}
+	for p.degree <= maxDegree {
+		limit := uint32(1) << (p.degree - 1)
+		for p.coeffs < limit {
+			c := p.coeffs
+			p.coeffs++
+			poly := uint64(1)<<p.degree | uint64(c)<<1 | 1
+			if isPrimitive(poly, p.degree) {
+				return p.degree, c
+			}
+		}
+		p.degree++
+		p.coeffs = 0
+	}
+	panic("sobol: exhausted primitive polynomials")
+}
+
+// isPrimitive reports whether poly (of the given degree, with a non-zero constant
+// term) is primitive, i.e. x has multiplicative order exactly 2^degree - 1 modulo poly.
+// Reducible polynomials cannot reach that order, so no separate irreducibility test is needed.
+func isPrimitive(poly uint64, degree int) bool {
+	order := uint64(1)<<degree - 1
+	if polyPowMod(2, order, poly, degree) != 1 {
+		return false
+	}
+	for _, q := range primeFactors(order) {
+		if polyPowMod(2, order/q, poly, degree) == 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// polyPowMod computes base^exp modulo poly in GF(2)[x], with polynomials encoded as bit sets.
+func polyPowMod(base, exp, poly uint64, degree int) uint64 {
+	result := uint64(1)
+	base = polyMod(base, poly, degree)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result = polyMulMod(result, base, poly, degree)
+		}
+		base = polyMulMod(base, base, poly, degree)
+		exp >>= 1
+	}
+	return result
+}
+
+// polyMulMod multiplies a and b modulo poly in GF(2)[x]. Both operands must already be reduced.
+func polyMulMod(a, b, poly uint64, degree int) uint64 {
+	var result uint64
+	for b > 0 {
+		if b&1 == 1 {
+			result ^= a
+		}
+		b >>= 1
+		a <<= 1
+		if a>>degree&1 == 1 {
+			a ^= poly
+		}
+	}
+	return result
+}
+
+// polyMod reduces a modulo poly in GF(2)[x].
+func polyMod(a, poly uint64, degree int) uint64 {
+	for i := 63; i >= degree; i-- {
+		if a>>i&1 == 1 {
+			a ^= poly << (i - degree)
+		}
+	}
+	return a
+}
+
+// primeFactors returns the distinct prime factors of n by trial division.
+func primeFactors(n uint64) []uint64 {
+	var factors []uint64
+	for p := uint64(2); p*p <= n; p++ {
+		if n%p == 0 {
+			factors = append(factors, p)
+			for n%p == 0 {
+				n /= p
+			}
+		}
+	}
+	if n > 1 {
+		factors = append(factors, n)
+	}
+	return factors
+}
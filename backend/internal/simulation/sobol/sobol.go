@@ -0,0 +1,188 @@
+// Package sobol implements a self-contained Sobol low-discrepancy sequence
+// generator with optional Matoušek linear scrambling and a random digital shift.
+//
+// Direction numbers are derived from primitive polynomials over GF(2), which are
+// enumerated on demand in order of increasing degree, so any dimension can be
+// requested without an external table. The free initial direction numbers of each
+// dimension are drawn from a fixed-seed generator, which keeps the unscrambled
+// sequence reproducible across runs.
+package sobol
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+)
+
+// bits is the number of bits of precision used for each coordinate.
+const bits = 32
+
+// initSeed seeds the generator used for the free initial direction numbers.
+const initSeed = 0x50b01
+
+// Sequence generates successive points of a (possibly scrambled) Sobol sequence.
+// A Sequence is not safe for concurrent use.
+type Sequence struct {
+	dim       int
+	direction [][bits]uint32 // Direction numbers per dimension, most significant bit first.
+	shift     []uint32       // Digital shift per dimension; zero when unscrambled.
+	state     []uint32       // Current point as integers.
+	index     uint64         // Number of points generated so far.
+}
+
+// New creates an unscrambled Sobol sequence of the given dimension.
+// The first point returned by Next is the second point of the raw sequence;
+// the all-zero origin is skipped because it maps to the edge of the unit cube.
+func New(dim int) (*Sequence, error) {
+	if dim <= 0 {
+		return nil, errors.New("sobol: dimension must be positive")
+	}
+	directions := directionNumbers(dim)
+	s := &Sequence{
+		dim:       dim,
+		direction: make([][bits]uint32, dim),
+		shift:     make([]uint32, dim),
+		state:     make([]uint32, dim),
+	}
+	copy(s.direction, directions)
+	return s, nil
+}
+
+// NewScrambled creates a Sobol sequence of the given dimension randomised with
+// Matoušek's linear matrix scrambling followed by a random digital shift.
+// Scrambling preserves the low-discrepancy structure while making each
+// randomisation an unbiased estimator, so independent seeds can be used to
+// estimate the error of a quasi-Monte Carlo estimate.
+func NewScrambled(dim int, rng *rand.Rand) (*Sequence, error) {
+	if rng == nil {
+		return nil, errors.New("sobol: random source must not be nil")
+	}
+	s, err := New(dim)
+	if err != nil {
+		return nil, err
+	}
+	for d := 0; d < dim; d++ {
+		scramble(&s.direction[d], rng)
+		s.shift[d] = rng.Uint32()
+		s.state[d] = s.shift[d]
+	}
+	return s, nil
+}
+
+// Dim returns the dimension of the sequence.
+func (s *Sequence) Dim() int {
+	return s.dim
+}
+
+// Next writes the next point of the sequence into point, which must have length Dim.
+// Coordinates lie strictly inside (0, 1).
+func (s *Sequence) Next(point []float64) {
+	if len(point) != s.dim {
+		panic("sobol: point length does not match sequence dimension")
+	}
+	// Gray-code ordering: flip the direction number of the lowest zero bit of the index.
+	c := lowestZeroBit(s.index)
+	if c >= bits {
+		// The sequence is exhausted after 2^32 points; wrap around to the start.
+		c = bits - 1
+	}
+	s.index++
+	for d := 0; d < s.dim; d++ {
+		s.state[d] ^= s.direction[d][c]
+		// Centre the point within its 2^-32 cell so no coordinate is exactly 0.
+		point[d] = (float64(s.state[d]) + 0.5) / (1 << bits)
+	}
+}
+
+// lowestZeroBit returns the position of the least significant zero bit of n.
+func lowestZeroBit(n uint64) int {
+	c := 0
+	for n&1 == 1 {
+		n >>= 1
+		c++
+	}
+	return c
+}
+
+// scramble applies a random lower-triangular binary matrix with unit diagonal to
+// each direction number. Row i of the matrix produces output bit i (counted from
+// the most significant bit) as the parity of the masked input bits.
+func scramble(v *[bits]uint32, rng *rand.Rand) {
+	var rows [bits]uint32
+	for i := 0; i < bits; i++ {
+		diag := uint32(1) << (bits - 1 - i)
+		// Allow only bits at or above the diagonal (more significant) to contribute.
+		upper := ^(diag - 1)
+		rows[i] = (rng.Uint32() & upper) | diag
+	}
+	for k := range v {
+		var out uint32
+		for i := 0; i < bits; i++ {
+			if parity(rows[i]&v[k]) == 1 {
+				out |= 1 << (bits - 1 - i)
+			}
+		}
+		v[k] = out
+	}
+}
+
+// parity returns 1 if x has an odd number of set bits and 0 otherwise.
+func parity(x uint32) uint32 {
+	x ^= x >> 16
+	x ^= x >> 8
+	x ^= x >> 4
+	x ^= x >> 2
+	x ^= x >> 1
+	return x & 1
+}
+
+var (
+	directionsMu sync.Mutex
+	directions   [][bits]uint32 // Cached direction numbers, grown on demand.
+	polys        polynomialSource
+	initRand     = rand.New(rand.NewSource(initSeed))
+)
+
+// directionNumbers returns the direction numbers for the first dim dimensions.
+// The returned slice shares storage with the cache and must not be modified.
+func directionNumbers(dim int) [][bits]uint32 {
+	directionsMu.Lock()
+	defer directionsMu.Unlock()
+
+	for len(directions) < dim {
+		if len(directions) == 0 {
+			// The first dimension is the van der Corput sequence in base 2.
+			var v [bits]uint32
+			for k := 0; k < bits; k++ {
+				v[k] = 1 << (bits - 1 - k)
+			}
+			directions = append(directions, v)
+			continue
+		}
+		degree, coeffs := polys.next()
+		directions = append(directions, deriveDirections(degree, coeffs, initRand))
+	}
+	return directions[:dim]
+}
+
+// deriveDirections builds the direction numbers for a primitive polynomial of the
+// given degree. coeffs holds the interior coefficients a_1..a_{s-1}, with a_1 in
+// the most significant of the degree-1 bits.
+func deriveDirections(degree int, coeffs uint32, rng *rand.Rand) [bits]uint32 {
+	var v [bits]uint32
+	for k := 0; k < degree && k < bits; k++ {
+		// m_k must be odd and less than 2^(k+1).
+		m := uint32(rng.Int63n(1<<k))*2 + 1
+		v[k] = m << (bits - 1 - k)
+	}
+	for k := degree; k < bits; k++ {
+		next := v[k-degree] ^ (v[k-degree] >> degree)
+		for j := 1; j < degree; j++ {
+			if (coeffs>>(degree-1-j))&1 == 1 {
+				next ^= v[k-j]
+			}
+		}
+		v[k] = next
+	}
+	return v
+}
@@ -0,0 +1,94 @@
+package sobol
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_InvalidDimension(t *testing.T) {
+	_, err := New(0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "dimension must be positive")
+}
+
+func TestNewScrambled_NilRand(t *testing.T) {
+	_, err := NewScrambled(2, nil)
+	require.Error(t, err)
+}
+
+func TestSequence_FirstDimensionIsVanDerCorput(t *testing.T) {
+	seq, err := New(1)
+	require.NoError(t, err)
+
+	const halfCell = 0.5 / (1 << bits)
+	expected := []float64{0.5, 0.75, 0.25, 0.375, 0.875, 0.625, 0.125}
+	point := make([]float64, 1)
+	for i, exp := range expected {
+		seq.Next(point)
+		require.InDelta(t, exp+halfCell, point[0], 1e-15, "point %d", i)
+	}
+}
+
+func TestPolynomialSource_CountsPerDegree(t *testing.T) {
+	// Number of primitive polynomials of degree 1..8 over GF(2).
+	expected := map[int]int{1: 1, 2: 1, 3: 2, 4: 2, 5: 6, 6: 6, 7: 18, 8: 16}
+
+	var src polynomialSource
+	counts := make(map[int]int)
+	for {
+		degree, _ := src.next()
+		if degree > 8 {
+			break
+		}
+		counts[degree]++
+	}
+	require.Equal(t, expected, counts)
+}
+
+func TestSequence_StratifiesEachDimension(t *testing.T) {
+	const dim = 64
+	const m = 8
+	const n = 1<<m - 1
+
+	plain, err := New(dim)
+	require.NoError(t, err)
+	scrambled, err := NewScrambled(dim, rand.New(rand.NewSource(42)))
+	require.NoError(t, err)
+
+	for name, seq := range map[string]*Sequence{"plain": plain, "scrambled": scrambled} {
+		t.Run(name, func(t *testing.T) {
+			strata := make([]map[int]bool, dim)
+			for d := range strata {
+				strata[d] = make(map[int]bool)
+			}
+			point := make([]float64, dim)
+			for i := 0; i < n; i++ {
+				seq.Next(point)
+				for d, u := range point {
+					require.Greater(t, u, 0.0)
+					require.Less(t, u, 1.0)
+					strata[d][int(u*(1<<m))] = true
+				}
+			}
+			// Together with the skipped origin, the first 2^m points form a (0,m,1)-net
+			// in every one-dimensional projection, so each point occupies its own stratum.
+			for d := range strata {
+				require.Len(t, strata[d], n, "dimension %d", d)
+			}
+		})
+	}
+}
+
+func TestNewScrambled_SeedsDiffer(t *testing.T) {
+	a, err := NewScrambled(3, rand.New(rand.NewSource(1)))
+	require.NoError(t, err)
+	b, err := NewScrambled(3, rand.New(rand.NewSource(2)))
+	require.NoError(t, err)
+
+	pa, pb := make([]float64, 3), make([]float64, 3)
+	a.Next(pa)
+	b.Next(pb)
+	require.NotEqual(t, pa, pb)
+}
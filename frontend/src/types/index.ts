@@ -4,6 +4,31 @@
 export type PortfolioItem = {
     ticker: string;
     weight: number;
+    expenseRatio?: number; // Annual fund costs, e.g. 0.0003
+    assetClass?: "equity" | "bond" | "cash"; // Returns earned in stress scenarios
+    synthetic?: SyntheticAsset; // Generated returns instead of a fetched ticker
+    assumptions?: CapitalMarketAssumptions; // Forward-looking mean and volatility
+    backfill?: Backfill; // Proxies spliced in before the ticker's inception
+};
+
+// Proxy tickers extending a short history, each filling the months before the last
+export type Backfill = {
+    proxies: string[];
+    scaleVolatility?: boolean; // Match each proxy's volatility to the history over their overlap
+};
+
+// Overrides the historical mean and volatility of a fetched ticker
+export type CapitalMarketAssumptions = {
+    expectedReturn?: number; // Expected annual return, e.g. 0.05
+    volatility?: number; // Annual volatility, e.g. 0.16
+};
+
+// Returns of an asset without price history: either constant or parametric
+export type SyntheticAsset = {
+    monthlyReturn?: number; // Constant monthly return, e.g. 0.0025
+    mean?: number; // Expected annual return, e.g. 0.04
+    volatility?: number; // Annual volatility, e.g. 0.06
+    correlations?: Record<string, number>; // Correlation with other tickers
 };
 
 // Parameters sent to the backend simulation API
@@ -13,8 +38,110 @@ export type Params = {
     periods: number;
     simulations: number;
     method: "normal" | "bootstrap";
+    sampler?: "pseudo" | "sobol"; // Quasi-random sampling is only supported by "normal"
     withdrawal: number;
     inflation: number;
+    targetStdError?: number; // Adaptive runs: success-rate standard error target
+    maxSimulations?: number; // Adaptive runs: cap on total paths
+    lifespan?: LifespanParams;
+    annuity?: AnnuityParams;
+    bucket?: BucketParams;
+    glidePath?: GlidePath;
+    advisoryFee?: AdvisoryFee;
+    leverage?: LeverageParams;
+    accounts?: Account[]; // Replaces portfolio and initialValue
+    withdrawalOrder?: "conventional" | "deferred-first" | "proportional";
+    tax?: { ordinaryRate: number; capitalGainsRate: number };
+    rmd?: RMDParams;
+    scenarios?: ScenarioParams[];
+    scoring?: { riskAversion: number; consumptionFloor?: number };
+    alignment?: "intersect" | "union"; // How assets' calendar months are matched
+    startDate?: string; // First month of history, e.g. "1990-01"
+    endDate?: string; // Last month of history, e.g. "2020-12"
+    exclude?: { start: string; end: string }[]; // Month ranges left out of the history
+    cache?: "use" | "refresh" | "bypass"; // How the server's price cache is used
+    seed?: number; // Reproduce a previous run
+};
+
+// Stress scenario: a built-in name, or custom monthly returns
+export type ScenarioParams = {
+    name: string; // e.g. "2008-crash", "1970s-stagflation", "dot-com-bust", "1987-crash"
+    start?: number; // Period after which the stress begins
+    returns?: number[]; // Equity returns
+    bondReturns?: number[]; // Bonds are held flat without them
+    cashReturns?: number[]; // Cash is held flat without them
+    inflation?: number; // Annual inflation during custom returns; 0 is honoured
+};
+
+// Single-premium immediate annuity bought during the simulation
+export type AnnuityParams = {
+    period: number; // Month of purchase, 0 = at the start
+    fraction?: number; // Share of the portfolio used as premium
+    amount?: number; // Or a fixed premium
+    payoutRate: number;
+    cola?: number;
+};
+
+// Stochastic lifespan: built-in table by sex, or a custom table
+export type LifespanParams = {
+    age: number;
+    sex?: "male" | "female";
+    table?: { age: number; qx: number }[];
+    tableCsv?: string;
+};
+
+// Mortality-weighted outcomes, present when lifespan was requested
+export type LifespanStats = {
+    probabilityOfRuin: number;
+    expectedBequest: number;
+    expectedShortfallYears: number;
+    shortfallYearsWhenDepleted: number;
+    expectedLifespanYears: number;
+};
+
+// Cash-bucket strategy: years of spending held in cash and when to refill it
+export type BucketParams = {
+    years: number;
+    yield?: number;
+    refill?: "monthly" | "annual" | "up-years";
+};
+
+// Time-varying allocation; portfolio weights apply at period 0 unless a point is given there
+export type GlidePath = {
+    interpolation?: "linear" | "step";
+    points: { period: number; weights: Record<string, number> }[];
+};
+
+// Borrowing against the portfolio: a fixed rate, or a spread over monthly cash rates
+export type LeverageParams = {
+    ratio: number;
+    borrowRate?: number;
+    cashRates?: number[];
+    spread?: number;
+    maintenanceRatio?: number; // Default 0.25
+};
+
+// Account with its own allocation and tax treatment
+export type Account = {
+    name: string;
+    type: "taxable" | "tax-deferred" | "roth";
+    balance: number;
+    costBasis?: number; // Taxable accounts; defaults to the balance
+    portfolio: PortfolioItem[];
+};
+
+// Required minimum distributions from tax-deferred accounts
+export type RMDParams = {
+    age: number;
+    startAge?: number; // Default 73
+    divisors?: { age: number; divisor: number }[]; // Default: IRS Uniform Lifetime Table
+    divisorsCsv?: string;
+};
+
+// Advisory fee on assets under management: a flat rate or a tiered schedule
+export type AdvisoryFee = {
+    rate?: number;
+    tiers?: { above: number; rate: number }[];
 };
 
 // Statistics returned after simulation
@@ -25,10 +152,178 @@ export type SummaryStats = {
     max: number;
 };
 
+// Success-rate estimate after a batch of simulated paths
+export type ConvergencePoint = {
+    simulations: number;
+    successRate: number;
+    stdError: number;
+    ciLower: number; // 95% confidence interval
+    ciUpper: number;
+};
+
+// One strategy's results in a side-by-side comparison
+export type Outcome = {
+    successRate: number;
+    medianTerminal: number;
+    meanTerminal: number;
+    expectedBequest: number;
+    certaintyEquivalent?: number; // Present when scoring is requested
+};
+
+// Consumption scores, as fractions of planned spending
+export type ScoreStats = {
+    expectedUtility: number;
+    certaintyEquivalent: number;
+    averageShortfall: number;
+    worstShortfall: number;
+    averageYearsUnfunded: number;
+    yearsUnfundedWhenDepleted: number;
+    worstYearsUnfunded: number;
+};
+
+export type AnnuityComparison = {
+    purchaseRate: number;
+    averagePremium: number;
+    averageMonthlyIncome: number;
+    withAnnuity: Outcome;
+    withoutAnnuity: Outcome;
+};
+
+export type BucketComparison = {
+    emptiedRate: number;
+    averageEmptyMonths: number;
+    withBucket: Outcome;
+    withoutBucket: Outcome;
+};
+
+export type FeeStats = {
+    averageFeesPaid: number;
+    medianFeesPaid: number;
+    meanTerminalDifference: number; // Terminal wealth lost to fees
+    medianTerminalDifference: number;
+    withFees: Outcome;
+    withoutFees: Outcome;
+};
+
+export type LeverageStats = {
+    marginCallRate: number;
+    averageMarginCalls: number;
+    leveragedTerminal: SummaryStats;
+    unleveragedTerminal: SummaryStats;
+    withLeverage: Outcome;
+    withoutLeverage: Outcome;
+};
+
+// Baseline compared with each stress scenario on the same paths
+export type StressStats = {
+    baseline: Outcome;
+    scenarios: { name: string; start: number; outcome: Outcome; finalStats: SummaryStats }[];
+};
+
+// After-tax results of a multi-account run
+export type AccountStats = {
+    afterTaxSpending: SummaryStats;
+    taxesPaid: SummaryStats;
+    afterTaxTerminal: SummaryStats;
+    excessRmd: SummaryStats; // RMDs beyond spending, reinvested in taxable
+    spendingPerPath: number[];
+    taxesPerPath: number[];
+    finalBalances: { name: string; type: string; balance: number }[];
+};
+
+// Allocation at each period, columns in ticker order
+export type AllocationCurve = {
+    tickers: string[];
+    weights: number[][];
+};
+
 // Full response from the backend simulation API
 export type SimulationResponse = {
     paths: number[][];
     finalStats: SummaryStats;
     successRate: number; // between 0 and 1
     simulatedCAGR: number; // Compound Annual Growth Rate of the simulated paths, including withdrawals
+    convergence: ConvergencePoint[];
+    converged: boolean;
+    lifespan?: LifespanStats;
+    annuity?: AnnuityComparison;
+    bucket?: BucketComparison;
+    allocations?: AllocationCurve;
+    fees?: FeeStats;
+    accounts?: AccountStats;
+    leverage?: LeverageStats;
+    stress?: StressStats;
+    scores?: ScoreStats;
+    history?: HistoryRange;
+    providers?: TickerProvider[];
+    seed: number;
+};
+
+// Price provider that served a ticker
+export type TickerProvider = {
+    ticker: string;
+    provider: string; // e.g. "tiingo" or "csv"
+    fetchedAt?: string; // RFC 3339
+    cached: boolean; // Served from the price cache
+};
+
+// Calendar months the assets' returns were aligned on
+export type HistoryRange = {
+    start: string; // e.g. "2004-11"
+    end: string;
+    months: number;
+    alignment: "intersect" | "union";
+    excludedMonths: number; // Months within start and end left out by the request
+    sources?: AssetSources[]; // Present for backfilled assets
+};
+
+// Tickers a backfilled asset's months came from, earliest first
+export type AssetSources = {
+    ticker: string;
+    sources: {
+        ticker: string;
+        start: string;
+        end: string;
+        months: number;
+        volatilityScale: number;
+    }[];
+};
+
+// Input varied by the sensitivity endpoint
+export type SensitivityInput = {
+    name: "withdrawalRate" | "inflation" | "equityWeight" | "advisoryFee" | "expectedReturn";
+    ticker?: string; // For equityWeight and expectedReturn
+    low: number;
+    high: number;
+};
+
+export type SensitivityParams = {
+    base: Params;
+    inputs: SensitivityInput[];
+    gridSteps?: number;
+};
+
+// Outcome of one sensitivity run and its change from the baseline
+export type SensitivityPoint = {
+    successRate: number;
+    medianTerminal: number;
+    successRateChange: number;
+    medianTerminalChange: number;
+};
+
+// Response from the sensitivity endpoint; inputs are ordered for a tornado chart
+export type SensitivityResponse = {
+    seed: number;
+    baseline: SensitivityPoint;
+    inputs: {
+        name: string;
+        ticker?: string;
+        lowValue: number;
+        highValue: number;
+        low: SensitivityPoint;
+        high: SensitivityPoint;
+        successRateSwing: number;
+        medianTerminalSwing: number;
+    }[];
+    grid?: (SensitivityPoint & { values: Record<string, number> })[];
 };